@@ -1,11 +1,16 @@
 package ast
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/pgavlin/yomlette/token"
 	"golang.org/x/xerrors"
@@ -71,6 +76,22 @@ const (
 	WithType
 	// TemplateInvokeType type identifier for template invoke node
 	TemplateInvokeType
+	// DefineType type identifier for define node
+	DefineType
+	// TimestampType type identifier for timestamp node
+	TimestampType
+	// BinaryType type identifier for binary node
+	BinaryType
+	// TemplateListType type identifier for template list node
+	TemplateListType
+	// InterpolatedStringType type identifier for interpolated string node
+	InterpolatedStringType
+	// TemplateCommentType type identifier for template comment node
+	TemplateCommentType
+	// BreakType type identifier for break node
+	BreakType
+	// ContinueType type identifier for continue node
+	ContinueType
 )
 
 // String node type identifier to text
@@ -126,6 +147,22 @@ func (t NodeType) String() string {
 		return "With"
 	case TemplateInvokeType:
 		return "TemplateInvoke"
+	case DefineType:
+		return "Define"
+	case TimestampType:
+		return "Timestamp"
+	case BinaryType:
+		return "Binary"
+	case TemplateListType:
+		return "TemplateList"
+	case InterpolatedStringType:
+		return "InterpolatedString"
+	case TemplateCommentType:
+		return "TemplateComment"
+	case BreakType:
+		return "Break"
+	case ContinueType:
+		return "Continue"
 	}
 	return ""
 }
@@ -216,7 +253,16 @@ func Null(tk *token.Token) Node {
 
 // Bool create node for boolean value
 func Bool(tk *token.Token) Node {
-	b, _ := strconv.ParseBool(tk.Value)
+	var b bool
+	switch tk.Value {
+	case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON":
+		// YAML 1.1 boolean
+		b = true
+	case "n", "N", "no", "No", "NO", "off", "Off", "OFF":
+		// YAML 1.1 boolean
+	default:
+		b, _ = strconv.ParseBool(tk.Value)
+	}
 	return &BoolNode{
 		BaseNode: &BaseNode{},
 		Token:    tk,
@@ -224,108 +270,115 @@ func Bool(tk *token.Token) Node {
 	}
 }
 
-// Integer create node for integer value
-func Integer(tk *token.Token) Node {
+// Integer create node for integer value. Binary, octal, and hexadecimal forms are supported, as are underscores
+// between digits. Values that do not fit in an int64 (if signed) or uint64 are represented exactly as a *big.Int. An
+// error is returned if the token has no digits, e.g. the YAML 1.1 integer 0x_.
+func Integer(tk *token.Token) (*IntegerNode, error) {
 	value := removeUnderScoreFromNumber(tk.Value)
+	negative, signed := value[0] == '-', value[0] == '-' || value[0] == '+'
+	digits := strings.TrimLeft(value, "+-")
+
+	base := 10
 	switch tk.Type {
 	case token.BinaryIntegerType:
-		// skip two characters because binary token starts with '0b'
-		skipCharacterNum := 2
-		negativePrefix := ""
-		if value[0] == '-' {
-			skipCharacterNum++
-			negativePrefix = "-"
-		}
-		if len(negativePrefix) > 0 {
-			i, _ := strconv.ParseInt(negativePrefix+value[skipCharacterNum:], 2, 64)
-			return &IntegerNode{
-				BaseNode: &BaseNode{},
-				Token:    tk,
-				Value:    i,
-			}
-		}
-		i, _ := strconv.ParseUint(negativePrefix+value[skipCharacterNum:], 2, 64)
-		return &IntegerNode{
-			BaseNode: &BaseNode{},
-			Token:    tk,
-			Value:    i,
-		}
+		// binary token starts with '0b' or '-0b'
+		base, digits = 2, digits[2:]
 	case token.OctetIntegerType:
 		// octet token starts with '0o' or '-0o' or '0' or '-0'
-		skipCharacterNum := 1
-		negativePrefix := ""
-		if value[0] == '-' {
-			skipCharacterNum++
-			if value[2] == 'o' {
-				skipCharacterNum++
-			}
-			negativePrefix = "-"
-		} else {
-			if value[1] == 'o' {
-				skipCharacterNum++
-			}
-		}
-		if len(negativePrefix) > 0 {
-			i, _ := strconv.ParseInt(negativePrefix+value[skipCharacterNum:], 8, 64)
-			return &IntegerNode{
-				BaseNode: &BaseNode{},
-				Token:    tk,
-				Value:    i,
-			}
-		}
-		i, _ := strconv.ParseUint(value[skipCharacterNum:], 8, 64)
-		return &IntegerNode{
-			BaseNode: &BaseNode{},
-			Token:    tk,
-			Value:    i,
+		base, digits = 8, strings.TrimPrefix(digits[1:], "o")
+		if digits == "" && !strings.HasSuffix(value, "o") {
+			// YAML 1.1 octal integer that consists of a leading zero and underscores, e.g. 0_
+			digits = "0"
 		}
 	case token.HexIntegerType:
 		// hex token starts with '0x' or '-0x'
-		skipCharacterNum := 2
-		negativePrefix := ""
-		if value[0] == '-' {
-			skipCharacterNum++
-			negativePrefix = "-"
-		}
-		if len(negativePrefix) > 0 {
-			i, _ := strconv.ParseInt(negativePrefix+value[skipCharacterNum:], 16, 64)
-			return &IntegerNode{
-				BaseNode: &BaseNode{},
-				Token:    tk,
-				Value:    i,
-			}
-		}
-		i, _ := strconv.ParseUint(value[skipCharacterNum:], 16, 64)
-		return &IntegerNode{
-			BaseNode: &BaseNode{},
-			Token:    tk,
-			Value:    i,
+		base, digits = 16, digits[2:]
+	default:
+		if strings.Contains(digits, ":") {
+			// YAML 1.1 base 60 integer, e.g. 190:20:30
+			digits = sexagesimalInteger(digits)
 		}
 	}
-	if value[0] == '-' || value[0] == '+' {
-		i, _ := strconv.ParseInt(value, 10, 64)
-		return &IntegerNode{
-			BaseNode: &BaseNode{},
-			Token:    tk,
-			Value:    i,
-		}
+	if base != 10 {
+		// only negative binary, octet, and hex values are signed
+		signed = negative
+	}
+
+	i, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, xerrors.Errorf("invalid integer %s", tk.Value)
 	}
-	i, _ := strconv.ParseUint(value, 10, 64)
 	return &IntegerNode{
 		BaseNode: &BaseNode{},
 		Token:    tk,
-		Value:    i,
+		Value:    integerValue(i, negative, signed),
+	}, nil
+}
+
+func sexagesimalInteger(digits string) string {
+	i, sixty := new(big.Int), big.NewInt(60)
+	for _, part := range strings.Split(digits, ":") {
+		p, ok := new(big.Int).SetString(part, 10)
+		if !ok {
+			return ""
+		}
+		i.Mul(i, sixty).Add(i, p)
 	}
+	return i.String()
 }
 
-// Float create node for float value
+func integerValue(i *big.Int, negative, signed bool) interface{} {
+	if negative {
+		i.Neg(i)
+	}
+	switch {
+	case signed && i.IsInt64():
+		return i.Int64()
+	case !signed && i.IsUint64():
+		return i.Uint64()
+	}
+	return i
+}
+
+// Float create node for float value. Values that are out of the range of a float64 are represented as a *big.Float in
+// BigValue.
 func Float(tk *token.Token) Node {
-	f, _ := strconv.ParseFloat(removeUnderScoreFromNumber(tk.Value), 64)
-	return &FloatNode{
+	value := removeUnderScoreFromNumber(tk.Value)
+	if strings.Contains(value, ":") {
+		// YAML 1.1 base 60 float, e.g. 190:20:30.15
+		value = sexagesimalFloat(value)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	node := &FloatNode{
 		BaseNode: &BaseNode{},
 		Token:    tk,
 		Value:    f,
 	}
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		// use enough precision to represent each of the literal's digits
+		prec := uint(len(value)) * 4
+		if prec < 64 {
+			prec = 64
+		}
+		node.BigValue, _, _ = big.ParseFloat(value, 10, prec, big.ToNearestEven)
+	}
+	return node
+}
+
+func sexagesimalFloat(value string) string {
+	negative := value[0] == '-'
+	f := 0.0
+	for _, part := range strings.Split(strings.TrimLeft(value, "+-"), ":") {
+		p, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return ""
+		}
+		f = f*60 + p
+	}
+	if negative {
+		f = -f
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
 }
 
 // Infinity create node for .inf or -.inf value
@@ -335,7 +388,7 @@ func Infinity(tk *token.Token) *InfinityNode {
 		Token:    tk,
 	}
 	switch tk.Value {
-	case ".inf", ".Inf", ".INF":
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
 		node.Value = math.Inf(0)
 	case "-.inf", "-.Inf", "-.INF":
 		node.Value = math.Inf(-1)
@@ -351,6 +404,92 @@ func Nan(tk *token.Token) *NanNode {
 	}
 }
 
+var timestampPattern = regexp.MustCompile(`^([0-9]{4})-([0-9]{1,2})-([0-9]{1,2})` +
+	`(?:(?:[Tt]|[ \t]+)([0-9]{1,2}):([0-9]{2}):([0-9]{2})(?:\.([0-9]*))?` +
+	`(?:[ \t]*(Z|([-+])([0-9]{1,2})(?::([0-9]{2}))?))?)?$`)
+
+// ParseTimestamp parses a YAML timestamp. All of the formats described by the YAML timestamp type are accepted, e.g.
+// 2001-12-14, 2001-12-14t21:59:43.10-05:00, and 2001-12-14 21:59:43.10 -5. Timestamps without a time zone are UTC.
+func ParseTimestamp(value string) (time.Time, bool) {
+	m := timestampPattern.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, false
+	}
+	atoi := func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	}
+
+	year, month, day := atoi(m[1]), atoi(m[2]), atoi(m[3])
+	hour, min, sec, nsec := atoi(m[4]), atoi(m[5]), atoi(m[6]), 0
+	if fraction := m[7]; fraction != "" {
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		nsec = atoi(fraction + strings.Repeat("0", 9-len(fraction)))
+	}
+
+	loc := time.UTC
+	if m[9] != "" {
+		offset := atoi(m[10])*60*60 + atoi(m[11])*60
+		if m[9] == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, min, sec, nsec, loc)
+	if t.Year() != year || t.Month() != time.Month(month) || t.Day() != day || t.Hour() != hour || t.Minute() != min ||
+		t.Second() != sec {
+		// out-of-range fields were normalized
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Timestamp create node for timestamp value
+func Timestamp(tk *token.Token) (*TimestampNode, error) {
+	t, ok := ParseTimestamp(tk.Value)
+	if !ok {
+		return nil, xerrors.Errorf("invalid timestamp %q", tk.Value)
+	}
+	return &TimestampNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+		Value:    t,
+	}, nil
+}
+
+// Binary create node for binary value. The text must be a StringNode or LiteralNode that holds the base64 encoding of
+// the value. Whitespace in the encoding is ignored.
+func Binary(text Node) (*BinaryNode, error) {
+	var encoded string
+	switch text := text.(type) {
+	case *StringNode:
+		encoded = text.Value
+	case *LiteralNode:
+		encoded = text.Value.Value
+	default:
+		return nil, xerrors.Errorf("invalid binary value %s", text.Type())
+	}
+	encoded = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, encoded)
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid binary value: %v", err)
+	}
+	return &BinaryNode{
+		BaseNode: &BaseNode{},
+		Text:     text,
+		Value:    b,
+	}, nil
+}
+
 // String create node for string value
 func String(tk *token.Token) *StringNode {
 	return &StringNode{
@@ -587,7 +726,7 @@ func (n *NullNode) String() string {
 type IntegerNode struct {
 	*BaseNode
 	Token *token.Token
-	Value interface{} // int64 or uint64 value
+	Value interface{} // int64, uint64, or *big.Int value
 }
 
 // Read implements (io.Reader).Read
@@ -608,7 +747,7 @@ func (n *IntegerNode) AddColumn(col int) {
 	n.Token.AddColumn(col)
 }
 
-// GetValue returns int64 value
+// GetValue returns int64, uint64, or *big.Int value
 func (n *IntegerNode) GetValue() interface{} {
 	return n.Value
 }
@@ -624,6 +763,7 @@ type FloatNode struct {
 	Token     *token.Token
 	Precision int
 	Value     float64
+	BigValue  *big.Float // the value if it is out of the range of a float64
 }
 
 // Read implements (io.Reader).Read
@@ -644,8 +784,11 @@ func (n *FloatNode) AddColumn(col int) {
 	n.Token.AddColumn(col)
 }
 
-// GetValue returns float64 value
+// GetValue returns float64 value, or *big.Float value if the value is out of the range of a float64
 func (n *FloatNode) GetValue() interface{} {
+	if n.BigValue != nil {
+		return n.BigValue
+	}
 	return n.Value
 }
 
@@ -750,7 +893,8 @@ func (n *LiteralNode) String() string {
 	return fmt.Sprintf("%s\n%s", n.Start.Value, strings.TrimRight(strings.TrimRight(origin, " "), "\n"))
 }
 
-// MergeKeyNode type of merge key node
+// MergeKeyNode type of merge key node. Merge keys are applied by parser.ExpandMerges, which lives in the parser package
+// so that it can report positioned syntax errors.
 type MergeKeyNode struct {
 	*BaseNode
 	Token *token.Token
@@ -888,6 +1032,76 @@ func (n *NanNode) String() string {
 	return n.Token.Value
 }
 
+// TimestampNode type of timestamp node
+type TimestampNode struct {
+	*BaseNode
+	Token *token.Token
+	Value time.Time
+}
+
+// Read implements (io.Reader).Read
+func (n *TimestampNode) Read(p []byte) (int, error) {
+	return readNode(p, n)
+}
+
+// Type returns TimestampType
+func (n *TimestampNode) Type() NodeType { return TimestampType }
+
+// GetToken returns token instance
+func (n *TimestampNode) GetToken() *token.Token {
+	return n.Token
+}
+
+// AddColumn add column number to child nodes recursively
+func (n *TimestampNode) AddColumn(col int) {
+	n.Token.AddColumn(col)
+}
+
+// GetValue returns time.Time value
+func (n *TimestampNode) GetValue() interface{} {
+	return n.Value
+}
+
+// String timestamp to text
+func (n *TimestampNode) String() string {
+	return n.Token.Value
+}
+
+// BinaryNode type of binary node
+type BinaryNode struct {
+	*BaseNode
+	Text  Node // the StringNode or LiteralNode that holds the base64 encoding of the value
+	Value []byte
+}
+
+// Read implements (io.Reader).Read
+func (n *BinaryNode) Read(p []byte) (int, error) {
+	return readNode(p, n)
+}
+
+// Type returns BinaryType
+func (n *BinaryNode) Type() NodeType { return BinaryType }
+
+// GetToken returns token instance
+func (n *BinaryNode) GetToken() *token.Token {
+	return n.Text.GetToken()
+}
+
+// AddColumn add column number to child nodes recursively
+func (n *BinaryNode) AddColumn(col int) {
+	n.Text.AddColumn(col)
+}
+
+// GetValue returns []byte value
+func (n *BinaryNode) GetValue() interface{} {
+	return n.Value
+}
+
+// String binary to text
+func (n *BinaryNode) String() string {
+	return n.Text.String()
+}
+
 // MapNode interface of MappingValueNode / MappingNode
 type MapNode interface {
 	MapRange() *MapNodeIter
@@ -937,7 +1151,7 @@ func (n *MappingNode) startPos() *token.Position {
 	return n.Values[0].Key.GetToken().Position
 }
 
-// Merge merge key/value of map.
+// Merge merge key/value of map. To apply the merge keys (<<) of a document, use parser.ExpandMerges.
 func (n *MappingNode) Merge(target *MappingNode) {
 	keyToMapValueMap := map[string]*MappingValueNode{}
 	for _, value := range n.Values {
@@ -994,7 +1208,12 @@ func (n *MappingNode) flowStyleString() string {
 	for _, value := range n.Values {
 		values = append(values, strings.TrimLeft(value.String(), " "))
 	}
-	return fmt.Sprintf("{%s}", strings.Join(values, ", "))
+	body := strings.Join(values, ", ")
+	if strings.HasPrefix(body, "{") || strings.HasSuffix(body, "}") {
+		// keep the braces of the mapping apart from the delimiters of templates
+		body = " " + body + " "
+	}
+	return fmt.Sprintf("{%s}", body)
 }
 
 func (n *MappingNode) blockStyleString() string {
@@ -1112,11 +1331,16 @@ func (n *MappingValueNode) SetIsFlowStyle(isFlow bool) {
 
 // String mapping value to text
 func (n *MappingValueNode) String() string {
+	if n.Template != nil {
+		return n.Template.String()
+	}
 	space := strings.Repeat(" ", n.Key.GetToken().Position.Column-1)
 	keyIndentLevel := n.Key.GetToken().Position.IndentLevel
 	valueIndentLevel := n.Value.GetToken().Position.IndentLevel
 	if _, ok := n.Value.(ScalarNode); ok {
 		return fmt.Sprintf("%s%s: %s", space, n.Key.String(), n.Value.String())
+	} else if isInlineTemplate(n.Value) {
+		return fmt.Sprintf("%s%s: %s", space, n.Key.String(), n.Value.String())
 	} else if keyIndentLevel < valueIndentLevel {
 		return fmt.Sprintf("%s%s:\n%s", space, n.Key.String(), n.Value.String())
 	} else if m, ok := n.Value.(*MappingNode); ok && (m.IsFlowStyle || len(m.Values) == 0) {
@@ -1345,8 +1569,9 @@ func (n *AnchorNode) String() string {
 // AliasNode type of alias node
 type AliasNode struct {
 	*BaseNode
-	Start *token.Token
-	Value Node
+	Start  *token.Token
+	Value  Node
+	Anchor *AnchorNode // the anchor this alias refers to; set by parser.ResolveAliases
 }
 
 func (n *AliasNode) SetName(name string) error {
@@ -1392,6 +1617,11 @@ type DirectiveNode struct {
 	*BaseNode
 	Start *token.Token
 	Value Node
+	Name  string // the directive's name, e.g. YAML or TAG
+
+	Version string // the version of a YAML directive
+	Handle  string // the handle of a TAG directive, e.g. !e!
+	Prefix  string // the prefix of a TAG directive, e.g. tag:example.com,2000:app/
 }
 
 // Read implements (io.Reader).Read
@@ -1424,6 +1654,7 @@ type TagNode struct {
 	*BaseNode
 	Start *token.Token
 	Value Node
+	URI   string // the resolved tag, e.g. tag:yaml.org,2002:str for !!str
 }
 
 // Read implements (io.Reader).Read
@@ -1507,6 +1738,9 @@ func Walk(v Visitor, node Node) {
 	case *BoolNode:
 	case *InfinityNode:
 	case *NanNode:
+	case *TimestampNode:
+	case *BinaryNode:
+		Walk(v, n.Text)
 	case *LiteralNode:
 		Walk(v, n.Value)
 	case *DirectiveNode:
@@ -1578,6 +1812,18 @@ func Walk(v Visitor, node Node) {
 		if tv, ok := v.(TemplateVisitor); ok {
 			WalkTemplate(tv, n.Pipe)
 		}
+	case *DefineNode:
+		for _, n := range n.List.Nodes {
+			Walk(v, n)
+		}
+	case *TemplateListNode:
+		for _, n := range n.List.Nodes {
+			Walk(v, n)
+		}
+	case *InterpolatedStringNode:
+		for _, n := range n.Parts.Nodes {
+			Walk(v, n)
+		}
 	}
 }
 
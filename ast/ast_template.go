@@ -613,6 +613,16 @@ func (a *ActionNode) WriteTo(sb *strings.Builder) {
 	sb.WriteString("}}")
 }
 
+// isInlineTemplate returns true if the node is a template that is written on the same line as the key of the
+// mapping value that holds it.
+func isInlineTemplate(n Node) bool {
+	switch n.(type) {
+	case *ActionNode, *TemplateInvokeNode, *InterpolatedStringNode, *TemplateCommentNode:
+		return true
+	}
+	return false
+}
+
 // BranchNode is the common representation of if, range, and with.
 type BranchNode struct {
 	*BaseNode
@@ -654,6 +664,11 @@ func (b *BranchNode) String() string {
 }
 
 func (b *BranchNode) WriteTo(sb *strings.Builder) {
+	b.writeTo(sb, (*NodeList).WriteTo)
+}
+
+// writeTo writes the branch, using writeList to write its lists.
+func (b *BranchNode) writeTo(sb *strings.Builder, writeList func(*NodeList, *strings.Builder)) {
 	name := ""
 	switch b.typ {
 	case IfType:
@@ -670,10 +685,10 @@ func (b *BranchNode) WriteTo(sb *strings.Builder) {
 	sb.WriteByte(' ')
 	b.Pipe.WriteTo(sb)
 	sb.WriteString("}}")
-	b.List.WriteTo(sb)
+	writeList(b.List, sb)
 	if b.ElseList != nil {
 		sb.WriteString("{{else}}")
-		b.ElseList.WriteTo(sb)
+		writeList(b.ElseList, sb)
 	}
 	sb.WriteString("{{end}}")
 }
@@ -734,3 +749,312 @@ func (t *TemplateInvokeNode) WriteTo(sb *strings.Builder) {
 	}
 	sb.WriteString("}}")
 }
+
+func Break(tk *token.Token) *BreakNode {
+	return &BreakNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+	}
+}
+
+// BreakNode represents a {{break}} action.
+type BreakNode struct {
+	*BaseNode
+	Token *token.Token
+}
+
+func (b *BreakNode) Read(p []byte) (int, error) {
+	return readNode(p, b)
+}
+
+// GetToken returns token instance
+func (b *BreakNode) GetToken() *token.Token {
+	return b.Token
+}
+
+func (b *BreakNode) Type() NodeType {
+	return BreakType
+}
+
+// AddColumn add column number to child nodes recursively
+func (b *BreakNode) AddColumn(col int) {
+	b.Token.AddColumn(col)
+}
+
+func (b *BreakNode) String() string {
+	return "{{break}}"
+}
+
+func (b *BreakNode) WriteTo(sb *strings.Builder) {
+	sb.WriteString(b.String())
+}
+
+func Continue(tk *token.Token) *ContinueNode {
+	return &ContinueNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+	}
+}
+
+// ContinueNode represents a {{continue}} action.
+type ContinueNode struct {
+	*BaseNode
+	Token *token.Token
+}
+
+func (c *ContinueNode) Read(p []byte) (int, error) {
+	return readNode(p, c)
+}
+
+// GetToken returns token instance
+func (c *ContinueNode) GetToken() *token.Token {
+	return c.Token
+}
+
+func (c *ContinueNode) Type() NodeType {
+	return ContinueType
+}
+
+// AddColumn add column number to child nodes recursively
+func (c *ContinueNode) AddColumn(col int) {
+	c.Token.AddColumn(col)
+}
+
+func (c *ContinueNode) String() string {
+	return "{{continue}}"
+}
+
+func (c *ContinueNode) WriteTo(sb *strings.Builder) {
+	sb.WriteString(c.String())
+}
+
+func TemplateComment(tk *token.Token, text string) *TemplateCommentNode {
+	return &TemplateCommentNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+		Text:     text,
+	}
+}
+
+// TemplateCommentNode holds a template comment ({{/* ... */}}). Comments produce no output when the template is
+// executed, but are kept in the tree so that it can be printed faithfully and so that tools can read them.
+type TemplateCommentNode struct {
+	*BaseNode
+	Token *token.Token
+	Text  string // The comment text, including the /* and */ markers.
+}
+
+func (c *TemplateCommentNode) Read(p []byte) (int, error) {
+	return readNode(p, c)
+}
+
+// GetToken returns token instance
+func (c *TemplateCommentNode) GetToken() *token.Token {
+	return c.Token
+}
+
+func (c *TemplateCommentNode) Type() NodeType {
+	return TemplateCommentType
+}
+
+// AddColumn add column number to child nodes recursively
+func (c *TemplateCommentNode) AddColumn(col int) {
+	c.Token.AddColumn(col)
+}
+
+func (c *TemplateCommentNode) String() string {
+	var sb strings.Builder
+	c.WriteTo(&sb)
+	return sb.String()
+}
+
+func (c *TemplateCommentNode) WriteTo(sb *strings.Builder) {
+	sb.WriteString("{{")
+	sb.WriteString(c.Text)
+	sb.WriteString("}}")
+}
+
+func Define(tk *token.Token, name string, list *NodeList) *DefineNode {
+	return &DefineNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+		Name:     name,
+		List:     list,
+	}
+}
+
+// DefineNode represents a {{define}} action and the body of the template it defines.
+type DefineNode struct {
+	*BaseNode
+	Token *token.Token
+	Name  string    // The name of the template (unquoted).
+	List  *NodeList // The body of the template.
+}
+
+func (d *DefineNode) Read(p []byte) (int, error) {
+	return readNode(p, d)
+}
+
+// GetToken returns token instance
+func (d *DefineNode) GetToken() *token.Token {
+	return d.Token
+}
+
+func (d *DefineNode) Type() NodeType {
+	return DefineType
+}
+
+// AddColumn add column number to child nodes recursively
+func (d *DefineNode) AddColumn(col int) {
+	d.Token.AddColumn(col)
+	d.List.AddColumn(col)
+}
+
+func (d *DefineNode) String() string {
+	var sb strings.Builder
+	d.WriteTo(&sb)
+	return sb.String()
+}
+
+func (d *DefineNode) WriteTo(sb *strings.Builder) {
+	sb.WriteString("{{define ")
+	sb.WriteString(strconv.Quote(d.Name))
+	sb.WriteString("}}")
+	d.List.WriteTo(sb)
+	sb.WriteString("{{end}}")
+}
+
+func TemplateList(tk *token.Token, list *NodeList) *TemplateListNode {
+	return &TemplateListNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+		List:     list,
+	}
+}
+
+// TemplateListNode holds the nodes of a top-level template that spans several YAML nodes, e.g. a {{range}} that
+// produces a document per element followed by further actions. The nodes are evaluated in order.
+type TemplateListNode struct {
+	*BaseNode
+	Token *token.Token
+	List  *NodeList // The nodes of the template.
+}
+
+func (l *TemplateListNode) Read(p []byte) (int, error) {
+	return readNode(p, l)
+}
+
+// GetToken returns token instance
+func (l *TemplateListNode) GetToken() *token.Token {
+	return l.Token
+}
+
+func (l *TemplateListNode) Type() NodeType {
+	return TemplateListType
+}
+
+// AddColumn add column number to child nodes recursively
+func (l *TemplateListNode) AddColumn(col int) {
+	l.Token.AddColumn(col)
+	l.List.AddColumn(col)
+}
+
+func (l *TemplateListNode) String() string {
+	var sb strings.Builder
+	l.WriteTo(&sb)
+	return sb.String()
+}
+
+func (l *TemplateListNode) WriteTo(sb *strings.Builder) {
+	l.List.WriteTo(sb)
+}
+
+func InterpolatedString(tk *token.Token, style token.Type, parts *NodeList) *InterpolatedStringNode {
+	return &InterpolatedStringNode{
+		BaseNode: &BaseNode{},
+		Token:    tk,
+		Style:    style,
+		Parts:    parts,
+	}
+}
+
+// InterpolatedStringNode holds a scalar with embedded template actions, e.g. `app-{{ .env }}`. The parts of the
+// scalar are literal text, held as string nodes, and the template nodes that produce the text in between.
+type InterpolatedStringNode struct {
+	*BaseNode
+	Token *token.Token // The first token of the scalar. For block scalars, the block header.
+	Style token.Type   // The style of the scalar: StringType, SingleQuoteType, DoubleQuoteType, LiteralType, or FoldedType.
+	Parts *NodeList    // The parts of the scalar.
+}
+
+func (s *InterpolatedStringNode) Read(p []byte) (int, error) {
+	return readNode(p, s)
+}
+
+// GetToken returns token instance
+func (s *InterpolatedStringNode) GetToken() *token.Token {
+	return s.Token
+}
+
+func (s *InterpolatedStringNode) Type() NodeType {
+	return InterpolatedStringType
+}
+
+// AddColumn add column number to child nodes recursively
+func (s *InterpolatedStringNode) AddColumn(col int) {
+	s.Token.AddColumn(col)
+	s.Parts.AddColumn(col)
+}
+
+func (s *InterpolatedStringNode) String() string {
+	var sb strings.Builder
+	s.WriteTo(&sb)
+	return sb.String()
+}
+
+func (s *InterpolatedStringNode) WriteTo(sb *strings.Builder) {
+	var text strings.Builder
+	writeText(s.Parts, &text)
+
+	switch s.Style {
+	case token.SingleQuoteType:
+		sb.WriteString("'" + strings.Replace(text.String(), "'", "''", -1) + "'")
+	case token.DoubleQuoteType:
+		sb.WriteString(strconv.Quote(text.String()))
+	case token.LiteralType, token.FoldedType:
+		// The parts of a block scalar hold its text without the block's indentation, and the first part starts at
+		// the block's indentation.
+		space := ""
+		if len(s.Parts.Nodes) != 0 {
+			space = strings.Repeat(" ", s.Parts.Nodes[0].GetToken().Position.Column-1)
+		}
+		sb.WriteString(s.Token.Value)
+		for _, line := range strings.Split(strings.TrimSuffix(text.String(), "\n"), "\n") {
+			if line == "" {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n" + space + line)
+			}
+		}
+	default:
+		sb.WriteString(text.String())
+	}
+}
+
+// writeText writes the parts of an interpolated string. String nodes hold literal text.
+func writeText(l *NodeList, sb *strings.Builder) {
+	for _, n := range l.Nodes {
+		switch n := n.(type) {
+		case *StringNode:
+			sb.WriteString(n.Value)
+		case *IfNode:
+			n.writeTo(sb, writeText)
+		case *RangeNode:
+			n.writeTo(sb, writeText)
+		case *WithNode:
+			n.writeTo(sb, writeText)
+		default:
+			sb.WriteString(n.String())
+		}
+	}
+}
@@ -1,208 +1,292 @@
 package ast
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pgavlin/yomlette/token"
 )
 
-func dumpf(w io.Writer, indentLevel int, typ fmt.Stringer, properties ...string) error {
+// property is a named property of a node. Values are strings, booleans, numbers, or lists of strings.
+type property struct {
+	name  string
+	value interface{}
+}
+
+// text returns the text of a property's value. Lists are written as [a,b].
+func (p property) text() string {
+	if l, ok := p.value.([]string); ok {
+		return "[" + strings.Join(l, ",") + "]"
+	}
+	return fmt.Sprintf("%v", p.value)
+}
+
+// jsonValue returns the JSON representation of a property's value. Numbers that JSON cannot represent, i.e. infinities,
+// NaNs, and complex numbers, are represented by their text.
+func (p property) jsonValue() interface{} {
+	switch v := p.value.(type) {
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return p.text()
+		}
+	case complex128:
+		return p.text()
+	}
+	return p.value
+}
+
+func dumpf(w io.Writer, indentLevel int, typ fmt.Stringer, properties []property) error {
 	indent := strings.Repeat("    ", indentLevel)
 	if _, err := fmt.Fprintf(w, "%s- *%s*\n", indent, typ); err != nil {
 		return err
 	}
-	for i := 0; i < len(properties); i += 2 {
-		key, value := properties[i], ""
-		if i+1 < len(properties) {
-			value = properties[i+1]
-		}
-		value = strconv.Quote(value)
+	for _, p := range properties {
+		value := strconv.Quote(p.text())
 		value = value[1 : len(value)-1]
-		if _, err := fmt.Fprintf(w, "%s    - %s: `%s`\n", indent, key, value); err != nil {
+		if _, err := fmt.Fprintf(w, "%s    - %s: `%s`\n", indent, p.name, value); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func dump(w io.Writer, indentLevel int, n interface{}) error {
-	if n == nil {
-		return nil
+// child is a named child field of a node. List fields hold any number of nodes; other fields hold at most one.
+type child struct {
+	name  string
+	nodes []interface{}
+	list  bool
+}
+
+func one(name string, node interface{}) child {
+	return child{name: name, nodes: []interface{}{node}}
+}
+
+func list(name string, nodes []interface{}) child {
+	return child{name: name, nodes: nodes, list: true}
+}
+
+func nodeList(name string, l *NodeList) child {
+	c := child{name: name, list: true}
+	if l != nil {
+		for _, n := range l.Nodes {
+			c.nodes = append(c.nodes, n)
+		}
 	}
+	return c
+}
 
-	var typ fmt.Stringer
-	var properties []string
+// describe returns the type, type-specific properties, and children of a YAML or template node.
+func describe(n interface{}) (typ fmt.Stringer, properties []property, children []child) {
 	if node, ok := n.(Node); ok {
 		typ = node.Type()
-		if c := node.GetComment(); c != nil {
-			properties = append(properties, "Comment", c.Value)
-		}
-		if t := node.GetToken(); t != nil {
-			properties = append(properties, "Token", t.Value)
-			properties = append(properties, "Position", t.Position.String())
-		}
 	} else {
 		typ = n.(TemplateNode).Type()
 	}
 
-	var children []interface{}
 	switch n := n.(type) {
 	case *CommentNode:
 	case *NullNode:
 	case *IntegerNode:
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value))
+		properties = append(properties, property{"Value", n.Value})
 	case *FloatNode:
-		properties = append(properties, "Precision", fmt.Sprintf("%v", n.Precision))
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value))
+		properties = append(properties, property{"Precision", n.Precision})
+		properties = append(properties, property{"Value", n.GetValue()})
 	case *StringNode:
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value))
+		properties = append(properties, property{"Value", n.Value})
 	case *MergeKeyNode:
 	case *BoolNode:
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value))
+		properties = append(properties, property{"Value", n.Value})
 	case *InfinityNode:
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value))
+		properties = append(properties, property{"Value", n.Value})
 	case *NanNode:
+	case *TimestampNode:
+		properties = append(properties, property{"Value", n.Value.Format(time.RFC3339Nano)})
+	case *BinaryNode:
+		properties = append(properties, property{"Value", base64.StdEncoding.EncodeToString(n.Value)})
+		children = []child{one("Text", n.Text)}
 	case *LiteralNode:
-		properties = append(properties, "Value", fmt.Sprintf("%v", n.Value.Value))
+		properties = append(properties, property{"Value", n.Value.Value})
 	case *DirectiveNode:
-		properties = append(properties, "Start", n.Start.Value)
-		children = []interface{}{n.Value}
+		properties = append(properties, property{"Start", n.Start.Value})
+		if n.Name != "" {
+			properties = append(properties, property{"Name", n.Name})
+		}
+		switch n.Name {
+		case "YAML":
+			properties = append(properties, property{"Version", n.Version})
+		case "TAG":
+			properties = append(properties, property{"Handle", n.Handle}, property{"Prefix", n.Prefix})
+		}
+		children = []child{one("Value", n.Value)}
 	case *TagNode:
-		properties = append(properties, "Start", n.Start.Value)
-		children = []interface{}{n.Value}
+		properties = append(properties, property{"Start", n.Start.Value})
+		if n.URI != "" {
+			properties = append(properties, property{"URI", n.URI})
+		}
+		children = []child{one("Value", n.Value)}
 	case *DocumentNode:
 		if n.Start != nil {
-			properties = append(properties, "Start", n.Start.Value)
+			properties = append(properties, property{"Start", n.Start.Value})
 		}
 		if n.End != nil {
-			properties = append(properties, "End", n.End.Value)
+			properties = append(properties, property{"End", n.End.Value})
 		}
-		children = []interface{}{n.Body}
+		children = []child{one("Body", n.Body)}
 	case *MappingNode:
 		if n.Start != nil {
-			properties = append(properties, "Start", n.Start.Value)
+			properties = append(properties, property{"Start", n.Start.Value})
 		}
 		if n.End != nil {
-			properties = append(properties, "End", n.End.Value)
+			properties = append(properties, property{"End", n.End.Value})
 		}
-		properties = append(properties, "IsFlowStyle", fmt.Sprintf("%v", n.IsFlowStyle))
-		for _, value := range n.Values {
-			children = append(children, value)
+		properties = append(properties, property{"IsFlowStyle", n.IsFlowStyle})
+		values := make([]interface{}, len(n.Values))
+		for i, v := range n.Values {
+			values[i] = v
 		}
+		children = []child{list("Values", values)}
 	case *MappingKeyNode:
 		if n.Start != nil {
-			properties = append(properties, "Start", n.Start.Value)
+			properties = append(properties, property{"Start", n.Start.Value})
 		}
-		children = []interface{}{n.Value}
+		children = []child{one("Value", n.Value)}
 	case *MappingValueNode:
 		if n.Start != nil {
-			properties = append(properties, "Start", n.Start.Value)
+			properties = append(properties, property{"Start", n.Start.Value})
 		}
-		children = []interface{}{n.Template, n.Key, n.Value}
+		children = []child{one("Template", n.Template), one("Key", n.Key), one("Value", n.Value)}
 	case *SequenceNode:
 		if n.Start != nil {
-			properties = append(properties, "Start", n.Start.Value)
+			properties = append(properties, property{"Start", n.Start.Value})
 		}
 		if n.End != nil {
-			properties = append(properties, "End", n.End.Value)
+			properties = append(properties, property{"End", n.End.Value})
 		}
-		properties = append(properties, "IsFlowStyle", fmt.Sprintf("%v", n.IsFlowStyle))
-		for _, v := range n.Values {
-			children = append(children, v)
+		properties = append(properties, property{"IsFlowStyle", n.IsFlowStyle})
+		values := make([]interface{}, len(n.Values))
+		for i, v := range n.Values {
+			values[i] = v
 		}
+		children = []child{list("Values", values)}
 	case *AnchorNode:
-		properties = append(properties, "Start", n.Start.Value)
-		children = []interface{}{n.Name, n.Value}
+		properties = append(properties, property{"Start", n.Start.Value})
+		children = []child{one("Name", n.Name), one("Value", n.Value)}
 	case *AliasNode:
-		properties = append(properties, "Start", n.Start.Value)
-		children = []interface{}{n.Value}
+		properties = append(properties, property{"Start", n.Start.Value})
+		children = []child{one("Value", n.Value)}
 	case *ActionNode:
-		children = []interface{}{n.Pipe}
+		children = []child{one("Pipe", n.Pipe)}
 	case *IfNode:
-		children = append(children, n.Pipe)
-		for _, n := range n.List.Nodes {
-			children = append(children, n)
-		}
-		if n.ElseList != nil {
-			for _, n := range n.ElseList.Nodes {
-				children = append(children, n)
-			}
-		}
+		children = []child{one("Pipe", n.Pipe), nodeList("List", n.List), nodeList("ElseList", n.ElseList)}
 	case *RangeNode:
-		children = append(children, n.Pipe)
-		for _, n := range n.List.Nodes {
-			children = append(children, n)
-		}
-		if n.ElseList != nil {
-			for _, n := range n.ElseList.Nodes {
-				children = append(children, n)
-			}
-		}
+		children = []child{one("Pipe", n.Pipe), nodeList("List", n.List), nodeList("ElseList", n.ElseList)}
 	case *WithNode:
-		children = append(children, n.Pipe)
-		for _, n := range n.List.Nodes {
-			children = append(children, n)
-		}
-		if n.ElseList != nil {
-			for _, n := range n.ElseList.Nodes {
-				children = append(children, n)
-			}
-		}
+		children = []child{one("Pipe", n.Pipe), nodeList("List", n.List), nodeList("ElseList", n.ElseList)}
 	case *TemplateInvokeNode:
-		properties = append(properties, "Name", n.Name)
-		children = append(children, n.Pipe)
+		properties = append(properties, property{"Name", n.Name})
+		children = []child{one("Pipe", n.Pipe)}
+	case *TemplateCommentNode:
+		properties = append(properties, property{"Text", n.Text})
+	case *DefineNode:
+		properties = append(properties, property{"Name", n.Name})
+		children = []child{nodeList("List", n.List)}
+	case *TemplateListNode:
+		children = []child{nodeList("List", n.List)}
+	case *InterpolatedStringNode:
+		properties = append(properties, property{"Style", n.Style.String()})
+		children = []child{nodeList("Parts", n.Parts)}
 	case *DotNode:
 	case *FieldNode:
-		properties = []string{"Ident", "[" + strings.Join(n.Ident, ",") + "]"}
+		properties = []property{{"Ident", n.Ident}}
 	case *IdentifierNode:
-		properties = []string{"Ident", n.Ident}
+		properties = []property{{"Ident", n.Ident}}
 	case *NilNode:
 	case *TemplateBoolNode:
-		properties = []string{"True", fmt.Sprintf("%v", n.True)}
+		properties = []property{{"True", n.True}}
 	case *TemplateNumberNode:
-		properties = append(properties, "IsInt", fmt.Sprintf("%v", n.IsInt))
-		properties = append(properties, "IsUint", fmt.Sprintf("%v", n.IsUint))
-		properties = append(properties, "IsFloat", fmt.Sprintf("%v", n.IsFloat))
-		properties = append(properties, "IsComplex", fmt.Sprintf("%v", n.IsComplex))
-		properties = append(properties, "Int64", fmt.Sprintf("%v", n.Int64))
-		properties = append(properties, "Uint64", fmt.Sprintf("%v", n.Uint64))
-		properties = append(properties, "Float64", fmt.Sprintf("%v", n.Float64))
-		properties = append(properties, "Complex128", fmt.Sprintf("%v", n.Complex128))
-		properties = append(properties, "Text", n.Text)
+		properties = append(properties, property{"IsInt", n.IsInt})
+		properties = append(properties, property{"IsUint", n.IsUint})
+		properties = append(properties, property{"IsFloat", n.IsFloat})
+		properties = append(properties, property{"IsComplex", n.IsComplex})
+		properties = append(properties, property{"Int64", n.Int64})
+		properties = append(properties, property{"Uint64", n.Uint64})
+		properties = append(properties, property{"Float64", n.Float64})
+		properties = append(properties, property{"Complex128", n.Complex128})
+		properties = append(properties, property{"Text", n.Text})
 	case *TemplateStringNode:
-		properties = append(properties, "Quoted", n.Quoted)
-		properties = append(properties, "Text", n.Text)
+		properties = append(properties, property{"Quoted", n.Quoted})
+		properties = append(properties, property{"Text", n.Text})
 	case *VariableNode:
-		properties = []string{"Ident", "[" + strings.Join(n.Ident, ",") + "]"}
+		properties = []property{{"Ident", n.Ident}}
 	case *ChainNode:
-		properties = []string{"Field", "[" + strings.Join(n.Field, ",") + "]"}
-		children = []interface{}{n.Node}
+		properties = []property{{"Field", n.Field}}
+		children = []child{one("Node", n.Node)}
 	case *CommandNode:
-		for _, arg := range n.Args {
-			children = append(children, arg)
+		args := make([]interface{}, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = arg
 		}
+		children = []child{list("Args", args)}
 	case *PipeNode:
-		properties = append(properties, "IsAssign", fmt.Sprintf("%v", n.IsAssign))
+		properties = append(properties, property{"IsAssign", n.IsAssign})
 
 		decl := make([]string, len(n.Decl))
 		for i, v := range n.Decl {
 			decl[i] = v.Ident[0]
 		}
-		properties = append(properties, "Decl", "["+strings.Join(decl, ",")+"]")
+		properties = append(properties, property{"Decl", decl})
 
-		for _, cmd := range n.Cmds {
-			children = append(children, cmd)
+		cmds := make([]interface{}, len(n.Cmds))
+		for i, cmd := range n.Cmds {
+			cmds[i] = cmd
 		}
+		children = []child{list("Cmds", cmds)}
+	}
+
+	return typ, properties, children
+}
+
+// isNil returns true if a child is nil, including typed nils.
+func isNil(n interface{}) bool {
+	if n == nil {
+		return true
 	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
 
-	if err := dumpf(w, indentLevel, typ, properties...); err != nil {
+func dump(w io.Writer, indentLevel int, n interface{}) error {
+	if isNil(n) {
+		return nil
+	}
+
+	var properties []property
+	if node, ok := n.(Node); ok {
+		if c := node.GetComment(); c != nil {
+			properties = append(properties, property{"Comment", c.Value})
+		}
+		if t := node.GetToken(); t != nil {
+			properties = append(properties, property{"Token", t.Value})
+			properties = append(properties, property{"Position", t.Position.String()})
+		}
+	}
+	typ, props, children := describe(n)
+	if err := dumpf(w, indentLevel, typ, append(properties, props...)); err != nil {
 		return err
 	}
 
 	for _, c := range children {
-		if err := dump(w, indentLevel+1, c); err != nil {
-			return err
+		for _, n := range c.nodes {
+			if err := dump(w, indentLevel+1, n); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -217,3 +301,157 @@ func Dump(w io.Writer, n Node) error {
 func DumpTemplate(w io.Writer, n TemplateNode) error {
 	return dump(w, 0, n)
 }
+
+// jsonToken is the JSON representation of a token.
+type jsonToken struct {
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// jsonNode is the JSON representation of a node. Kind is "yaml" for YAML nodes and "template" for the nodes inside
+// template actions. Each child field is either a node or a list of nodes; fields that hold no node are omitted.
+type jsonNode struct {
+	Kind       string                 `json:"kind"`
+	Type       string                 `json:"type"`
+	Token      *jsonToken             `json:"token,omitempty"`
+	Comment    *jsonToken             `json:"comment,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Children   map[string]interface{} `json:"children,omitempty"`
+}
+
+func toJSONToken(tk *token.Token) *jsonToken {
+	if tk == nil {
+		return nil
+	}
+	return &jsonToken{
+		Type:   tk.Type.String(),
+		Value:  tk.Value,
+		Line:   tk.Position.Line,
+		Column: tk.Position.Column,
+		Offset: tk.Position.Offset,
+	}
+}
+
+func toJSONNode(n interface{}) *jsonNode {
+	if isNil(n) {
+		return nil
+	}
+
+	typ, properties, children := describe(n)
+	j := &jsonNode{Kind: "template", Type: typ.String()}
+	if node, ok := n.(Node); ok {
+		j.Kind, j.Token, j.Comment = "yaml", toJSONToken(node.GetToken()), toJSONToken(node.GetComment())
+	}
+	if len(properties) != 0 {
+		j.Properties = map[string]interface{}{}
+		for _, p := range properties {
+			j.Properties[p.name] = p.jsonValue()
+		}
+	}
+	for _, c := range children {
+		var value interface{}
+		if c.list {
+			nodes := make([]*jsonNode, 0, len(c.nodes))
+			for _, n := range c.nodes {
+				if n := toJSONNode(n); n != nil {
+					nodes = append(nodes, n)
+				}
+			}
+			value = nodes
+		} else if n := toJSONNode(c.nodes[0]); n != nil {
+			value = n
+		} else {
+			continue
+		}
+		if j.Children == nil {
+			j.Children = map[string]interface{}{}
+		}
+		j.Children[c.name] = value
+	}
+	return j
+}
+
+// DumpJSON prints the tree rooted at n to the given writer as indented JSON. Each node is an object with the fields
+// "kind" ("yaml" or "template"), "type", "token" and "comment" (objects with "type", "value", "line", "column", and
+// "offset"), "properties" (an object whose fields are strings, booleans, numbers, or lists of strings), and "children"
+// (an object whose fields are nodes or lists of nodes). Empty fields are omitted.
+func DumpJSON(w io.Writer, n Node) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toJSONNode(n))
+}
+
+func dumpSExpr(w io.Writer, indentLevel int, n interface{}) error {
+	typ, properties, children := describe(n)
+
+	var b strings.Builder
+	b.WriteString("(" + typ.String())
+	if node, ok := n.(Node); ok {
+		if t := node.GetToken(); t != nil {
+			fmt.Fprintf(&b, " @%d:%d %s", t.Position.Line, t.Position.Column, strconv.Quote(t.Value))
+		}
+		if c := node.GetComment(); c != nil {
+			fmt.Fprintf(&b, " :Comment %s", strconv.Quote(c.Value))
+		}
+	}
+	for _, p := range properties {
+		fmt.Fprintf(&b, " :%s %s", p.name, strconv.Quote(p.text()))
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	indent := strings.Repeat("  ", indentLevel+1)
+	for _, c := range children {
+		if !c.list && isNil(c.nodes[0]) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "\n%s:%s", indent, c.name); err != nil {
+			return err
+		}
+		if !c.list {
+			if _, err := io.WriteString(w, " "); err != nil {
+				return err
+			}
+			if err := dumpSExpr(w, indentLevel+1, c.nodes[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, n := range c.nodes {
+			if isNil(n) {
+				continue
+			}
+			if _, err := io.WriteString(w, "\n"+indent+"  "); err != nil {
+				return err
+			}
+			if err := dumpSExpr(w, indentLevel+2, n); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, ")")
+	return err
+}
+
+// DumpSExpr prints the tree rooted at n to the given writer as an S-expression. Each node is written as
+//
+//	(Type @line:column "token" :Property "value" ...
+//	  :Field (Child ...)
+//	  :ListField
+//	    (Child ...))
+func DumpSExpr(w io.Writer, n Node) error {
+	if isNil(n) {
+		_, err := io.WriteString(w, "()\n")
+		return err
+	}
+	if err := dumpSExpr(w, 0, n); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,165 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func TestDumpSExpr(t *testing.T) {
+	f, err := parser.ParseBytes([]byte("a: [1, {{ .x | quote }}]\n"), parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ast.DumpSExpr(&buf, f.Docs[0].Body); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := `(Mapping @1:1 "a" :Start "a" :IsFlowStyle "false"
+  :Values
+    (MappingValue @1:1 "a" :Start "a"
+      :Key (String @1:1 "a" :Value "a")
+      :Value (Sequence @1:4 "[" :Start "[" :End "]" :IsFlowStyle "true"
+        :Values
+          (Integer @1:5 "1" :Value "1")
+          (Action @1:8 "{{ .x | quote }}"
+            :Pipe (Pipe :IsAssign "false" :Decl "[]"
+              :Cmds
+                (Command
+                  :Args
+                    (Field :Ident "[x]"))
+                (Command
+                  :Args
+                    (Identifier :Ident "quote")))))))
+`
+	if actual := buf.String(); actual != expect {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expect, actual)
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	f, err := parser.ParseBytes([]byte("# head\na: {{ .x }}\n"), parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ast.DumpJSON(&buf, f.Docs[0].Body); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var actual interface{}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	token := func(typ, value string, line, column, offset int) map[string]interface{} {
+		return map[string]interface{}{
+			"type":   typ,
+			"value":  value,
+			"line":   float64(line),
+			"column": float64(column),
+			"offset": float64(offset),
+		}
+	}
+	value := map[string]interface{}{
+		"kind":       "yaml",
+		"type":       "MappingValue",
+		"token":      token("String", "a", 2, 1, 7),
+		"properties": map[string]interface{}{"Start": "a"},
+		"children": map[string]interface{}{
+			"Key": map[string]interface{}{
+				"kind":       "yaml",
+				"type":       "String",
+				"token":      token("String", "a", 2, 1, 7),
+				"properties": map[string]interface{}{"Value": "a"},
+			},
+			"Value": map[string]interface{}{
+				"kind":  "yaml",
+				"type":  "Action",
+				"token": token("Template", "{{ .x }}", 2, 4, 10),
+				"children": map[string]interface{}{
+					"Pipe": map[string]interface{}{
+						"kind":       "template",
+						"type":       "Pipe",
+						"properties": map[string]interface{}{"IsAssign": false, "Decl": []interface{}{}},
+						"children": map[string]interface{}{
+							"Cmds": []interface{}{
+								map[string]interface{}{
+									"kind": "template",
+									"type": "Command",
+									"children": map[string]interface{}{
+										"Args": []interface{}{
+											map[string]interface{}{
+												"kind":       "template",
+												"type":       "Field",
+												"properties": map[string]interface{}{"Ident": []interface{}{"x"}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	expect := map[string]interface{}{
+		"kind":       "yaml",
+		"type":       "Mapping",
+		"token":      token("String", "a", 2, 1, 7),
+		"comment":    token("Comment", "# head\n", 1, 1, 0),
+		"properties": map[string]interface{}{"Start": "a", "IsFlowStyle": false},
+		"children":   map[string]interface{}{"Values": []interface{}{value}},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("unexpected JSON:\n%s", buf.String())
+	}
+}
+
+func TestDumpJSONProperties(t *testing.T) {
+	f, err := parser.ParseBytes([]byte("[1, true, .inf, {{ $a := .x }}]"), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ast.DumpJSON(&buf, f.Docs[0].Body); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var actual struct {
+		Children struct {
+			Values []struct {
+				Properties map[string]interface{} `json:"properties"`
+				Children   struct {
+					Pipe struct {
+						Properties map[string]interface{} `json:"properties"`
+					} `json:"Pipe"`
+				} `json:"children"`
+			} `json:"Values"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	values := actual.Children.Values
+	if len(values) != 4 {
+		t.Fatalf("unexpected JSON:\n%s", buf.String())
+	}
+	expect := []interface{}{float64(1), true, "+Inf"}
+	for i, v := range expect {
+		if values[i].Properties["Value"] != v {
+			t.Fatalf("expected value %d to be %#v, got %#v", i, v, values[i].Properties["Value"])
+		}
+	}
+	pipe := map[string]interface{}{"IsAssign": false, "Decl": []interface{}{"$a"}}
+	if !reflect.DeepEqual(values[3].Children.Pipe.Properties, pipe) {
+		t.Fatalf("expected pipe properties %#v, got %#v", pipe, values[3].Children.Pipe.Properties)
+	}
+}
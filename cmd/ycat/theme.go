@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	yerrors "github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// colorMode is the set of colors supported by the output terminal.
+type colorMode int
+
+const (
+	colors16 colorMode = iota
+	colors256
+	colorsTrue
+)
+
+// rgb is a 24-bit color.
+type rgb [3]int
+
+// palette holds the standard xterm values of the 16 basic colors.
+var palette = [16]rgb{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// cubeLevels holds the intensities of the 6x6x6 color cube in the 256-color palette.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// indexRGB returns the 24-bit value of a color in the 256-color palette.
+func indexRGB(index int) rgb {
+	switch {
+	case index < 16:
+		return palette[index]
+	case index < 232:
+		index -= 16
+		return rgb{cubeLevels[index/36], cubeLevels[index/6%6], cubeLevels[index%6]}
+	default:
+		gray := 8 + 10*(index-232)
+		return rgb{gray, gray, gray}
+	}
+}
+
+// nearest returns the index in [min, max) of the palette color that is closest to c.
+func nearest(c rgb, min, max int) int {
+	best, bestDistance := min, -1
+	for i := min; i < max; i++ {
+		p := indexRGB(i)
+		dr, dg, db := p[0]-c[0], p[1]-c[1], p[2]-c[2]
+		if d := dr*dr + dg*dg + db*db; bestDistance < 0 || d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
+
+// color is a foreground color given by name, by index into the 256-color palette, or as a 24-bit value. Index is -1
+// for 24-bit colors.
+type color struct {
+	index int
+	value rgb
+}
+
+func (c color) sgr(mode colorMode) string {
+	switch mode {
+	case colors16:
+		index := c.index
+		if index < 0 || index >= 16 {
+			index = nearest(c.value, 0, 16)
+		}
+		if index < 8 {
+			return strconv.Itoa(30 + index)
+		}
+		return strconv.Itoa(90 + index - 8)
+	case colors256:
+		index := c.index
+		if index < 0 {
+			index = nearest(c.value, 16, 256)
+		}
+		return fmt.Sprintf("38;5;%d", index)
+	default:
+		return fmt.Sprintf("38;2;%d;%d;%d", c.value[0], c.value[1], c.value[2])
+	}
+}
+
+// style is the presentation of a class of tokens.
+type style struct {
+	color      *color
+	attributes []string
+}
+
+var attributeCodes = map[string]string{
+	"bold":      "1",
+	"faint":     "2",
+	"italic":    "3",
+	"underline": "4",
+}
+
+// parseStyle parses a style specification. A specification is a space-separated list of attributes (bold, faint,
+// italic, and underline) and at most one color. A color is a name (e.g. cyan or bright-cyan), an index into the
+// 256-color palette, or a hex value (e.g. #66d9ef).
+func parseStyle(spec string) (style, error) {
+	var s style
+	for _, word := range strings.Fields(spec) {
+		if _, ok := attributeCodes[word]; ok {
+			s.attributes = append(s.attributes, word)
+			continue
+		}
+		if s.color != nil {
+			return style{}, fmt.Errorf("style %q has more than one color", spec)
+		}
+		c, err := parseColor(word)
+		if err != nil {
+			return style{}, err
+		}
+		s.color = &c
+	}
+	return s, nil
+}
+
+func parseColor(word string) (color, error) {
+	name, index := word, 0
+	if strings.HasPrefix(name, "bright-") {
+		name, index = name[len("bright-"):], 8
+	}
+	for i, n := range colorNames {
+		if n == name {
+			return color{index: index + i, value: palette[index+i]}, nil
+		}
+	}
+
+	if strings.HasPrefix(word, "#") {
+		if v, err := strconv.ParseUint(word[1:], 16, 32); err == nil && len(word) == 7 {
+			return color{index: -1, value: rgb{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}}, nil
+		}
+	} else if i, err := strconv.Atoi(word); err == nil && i >= 0 && i < 256 {
+		return color{index: i, value: indexRGB(i)}, nil
+	}
+	return color{}, fmt.Errorf("invalid color %q", word)
+}
+
+// ansi returns the SGR escape sequence that selects the style.
+func (s style) ansi(mode colorMode) string {
+	var codes []string
+	for _, a := range s.attributes {
+		codes = append(codes, attributeCodes[a])
+	}
+	if s.color != nil {
+		codes = append(codes, s.color.sgr(mode))
+	}
+	if len(codes) == 0 {
+		return ""
+	}
+	return escape + "[" + strings.Join(codes, ";") + "m"
+}
+
+// css returns the CSS declarations for the style.
+func (s style) css() string {
+	var decls []string
+	if s.color != nil {
+		decls = append(decls, fmt.Sprintf("color: #%02x%02x%02x", s.color.value[0], s.color.value[1], s.color.value[2]))
+	}
+	for _, a := range s.attributes {
+		switch a {
+		case "bold":
+			decls = append(decls, "font-weight: bold")
+		case "faint":
+			decls = append(decls, "opacity: 0.6")
+		case "italic":
+			decls = append(decls, "font-style: italic")
+		case "underline":
+			decls = append(decls, "text-decoration: underline")
+		}
+	}
+	return strings.Join(decls, "; ")
+}
+
+// A theme maps the classes of tokens to their styles. The classes are line-number, key, anchor, alias, bool, string,
+// number, null, comment, tag, template, directive, and document. The items inside template actions are styled by the
+// template-* classes; items without a class use the template style.
+type theme map[string]style
+
+var defaultTheme = theme{
+	"line-number": {color: &color{index: 15, value: palette[15]}, attributes: []string{"bold"}},
+	"key":         {color: &color{index: 14, value: palette[14]}},
+	"anchor":      {color: &color{index: 11, value: palette[11]}},
+	"alias":       {color: &color{index: 11, value: palette[11]}},
+	"bool":        {color: &color{index: 13, value: palette[13]}},
+	"string":      {color: &color{index: 10, value: palette[10]}},
+	"number":      {color: &color{index: 13, value: palette[13]}},
+	"comment":     {color: &color{index: 8, value: palette[8]}},
+	"tag":         {color: &color{index: 12, value: palette[12]}},
+	"template":    {color: &color{index: 9, value: palette[9]}},
+	"directive":   {color: &color{index: 12, value: palette[12]}, attributes: []string{"bold"}},
+	"document":    {attributes: []string{"bold"}},
+	"null":        {color: &color{index: 13, value: palette[13]}},
+
+	"template-delim":    {color: &color{index: 9, value: palette[9]}},
+	"template-keyword":  {color: &color{index: 9, value: palette[9]}, attributes: []string{"bold"}},
+	"template-field":    {color: &color{index: 14, value: palette[14]}},
+	"template-variable": {color: &color{index: 11, value: palette[11]}},
+	"template-function": {color: &color{index: 12, value: palette[12]}},
+	"template-string":   {color: &color{index: 10, value: palette[10]}},
+	"template-number":   {color: &color{index: 13, value: palette[13]}},
+	"template-constant": {color: &color{index: 13, value: palette[13]}},
+	"template-comment":  {color: &color{index: 8, value: palette[8]}},
+}
+
+// templateClasses maps the kinds of template items to their token classes.
+var templateClasses = map[printer.TemplateItemKind]string{
+	printer.TemplateDelim:    "template-delim",
+	printer.TemplateKeyword:  "template-keyword",
+	printer.TemplateField:    "template-field",
+	printer.TemplateVariable: "template-variable",
+	printer.TemplateFunction: "template-function",
+	printer.TemplateString:   "template-string",
+	printer.TemplateNumber:   "template-number",
+	printer.TemplateConstant: "template-constant",
+	printer.TemplateComment:  "template-comment",
+}
+
+// readTheme reads a theme from a YAML file. The file is a mapping from token classes to style specifications. Classes
+// that are not present in the file use the default theme's styles.
+func readTheme(filename string) (theme, error) {
+	f, err := parser.ParseFile(filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := theme{}
+	for class, s := range defaultTheme {
+		t[class] = s
+	}
+	if len(f.Docs) == 0 || f.Docs[0].Body == nil {
+		return t, nil
+	}
+
+	var values []*ast.MappingValueNode
+	switch body := f.Docs[0].Body.(type) {
+	case *ast.MappingNode:
+		values = body.Values
+	case *ast.MappingValueNode:
+		values = []*ast.MappingValueNode{body}
+	default:
+		return nil, yerrors.ErrSyntax("a theme must be a mapping", body.GetToken())
+	}
+	for _, v := range values {
+		class := v.Key.GetToken().Value
+		if _, ok := defaultTheme[class]; !ok {
+			return nil, yerrors.ErrSyntax(fmt.Sprintf("unknown token class %s", class), v.Key.GetToken())
+		}
+		spec, ok := v.Value.(ast.ScalarNode)
+		if !ok {
+			return nil, yerrors.ErrSyntax("a style must be a scalar", v.Value.GetToken())
+		}
+		s, err := parseStyle(spec.GetToken().Value)
+		if err != nil {
+			return nil, yerrors.ErrSyntax(err.Error(), spec.GetToken())
+		}
+		t[class] = s
+	}
+	return t, nil
+}
+
+// setProperties sets the properties of the printer for each token class using the given function.
+func (t theme) setProperties(p *printer.Printer, property func(class string, s style) *printer.Property) {
+	fn := func(class string) printer.PrintFunc {
+		prop := property(class, t[class])
+		return func() *printer.Property { return prop }
+	}
+	p.MapKey = fn("key")
+	p.Anchor = fn("anchor")
+	p.Alias = fn("alias")
+	p.Bool = fn("bool")
+	p.String = fn("string")
+	p.Number = fn("number")
+	p.Comment = fn("comment")
+	p.Tag = fn("tag")
+	p.Template = fn("template")
+	p.Directive = fn("directive")
+	p.Document = fn("document")
+	p.Styles = map[token.Type]printer.PrintFunc{token.NullType: fn("null")}
+
+	p.TemplateStyles = map[printer.TemplateItemKind]printer.PrintFunc{}
+	for kind, class := range templateClasses {
+		p.TemplateStyles[kind] = fn(class)
+	}
+	p.LexTemplate = parser.LexTemplate
+}
+
+// ansiPrinter configures a printer to style tokens with ANSI escape sequences.
+func (t theme) ansiPrinter(p *printer.Printer, mode colorMode) {
+	t.setProperties(p, func(_ string, s style) *printer.Property {
+		if prefix := s.ansi(mode); prefix != "" {
+			return &printer.Property{Prefix: prefix, Suffix: escape + "[0m"}
+		}
+		return &printer.Property{}
+	})
+	if p.LineNumber {
+		prefix := t["line-number"].ansi(mode)
+		p.LineNumberFormat = func(num int) string {
+			return fmt.Sprintf("%s%2d | %s[0m", prefix, num, escape)
+		}
+	}
+}
+
+// htmlPrinter configures a printer to wrap tokens in spans with a CSS class per token class.
+func (t theme) htmlPrinter(p *printer.Printer) {
+	t.setProperties(p, func(class string, _ style) *printer.Property {
+		return &printer.Property{Prefix: `<span class="yaml-` + class + `">`, Suffix: "</span>"}
+	})
+	if p.LineNumber {
+		p.LineNumberFormat = func(num int) string {
+			return fmt.Sprintf(`<span class="yaml-line-number">%2d | </span>`, num)
+		}
+	}
+}
+
+// stylesheet returns CSS rules for the classes used by htmlPrinter.
+func (t theme) stylesheet() string {
+	classes := make([]string, 0, len(t))
+	for class := range t {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	var b strings.Builder
+	for _, class := range classes {
+		if css := t[class].css(); css != "" {
+			fmt.Fprintf(&b, ".yaml-%s { %s; }\n", class, css)
+		}
+	}
+	return b.String()
+}
@@ -1,78 +1,193 @@
 package main
 
 import (
-	"errors"
+	"bytes"
+	"flag"
 	"fmt"
+	"html"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/json"
 	"github.com/pgavlin/yomlette/lexer"
 	"github.com/pgavlin/yomlette/parser"
 	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
 )
 
 const escape = "\x1b"
 
-func format(attr color.Attribute) string {
-	return fmt.Sprintf("%s[%dm", escape, attr)
+// printJSON prints a YAML file as JSON.
+func printJSON(w io.Writer, filename string, bytes []byte) error {
+	config := parser.Config{Mode: parser.ParseBigNumbers, Filename: filename}
+	f, err := config.ParseBytes(bytes)
+	if err != nil {
+		return err
+	}
+	if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+		return err
+	}
+	if err := parser.ExpandMerges(f); err != nil {
+		return err
+	}
+	return (&json.Encoder{Indent: "  "}).EncodeFile(w, f)
 }
 
-func _main(args []string) error {
-	if len(args) < 2 {
-		return errors.New("ycat: usage: ycat file.yml")
+// plainStrings converts double-quoted strings to plain strings so that they are only quoted when necessary.
+type plainStrings struct{}
+
+func (v plainStrings) Visit(node ast.Node) ast.Visitor {
+	if s, ok := node.(*ast.StringNode); ok && s.Token.Type == token.DoubleQuoteType {
+		s.Token.Type = token.StringType
 	}
-	filename := args[1]
-	bytes, err := ioutil.ReadFile(filename)
+	return v
+}
+
+// jsonToYAML converts a JSON file to block-style YAML.
+func jsonToYAML(source []byte) ([]byte, error) {
+	f, err := json.ParseBytes(source)
 	if err != nil {
+		return nil, err
+	}
+	for _, doc := range f.Docs {
+		switch body := doc.Body.(type) {
+		case *ast.MappingNode:
+			body.SetIsFlowStyle(false)
+		case *ast.SequenceNode:
+			body.SetIsFlowStyle(false)
+		}
+		ast.Walk(plainStrings{}, doc.Body)
+	}
+	var buf bytes.Buffer
+	if _, err := (&executor.Executor{}).Render(&buf, f, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// defaultColorMode infers the colors supported by the terminal from the environment.
+func defaultColorMode() string {
+	switch {
+	case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit":
+		return "truecolor"
+	case strings.Contains(os.Getenv("TERM"), "256color"):
+		return "256"
+	}
+	return "16"
+}
+
+// readInput reads the named file, or stdin if the name is "-".
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func _main(args []string) error {
+	flags := flag.NewFlagSet("ycat", flag.ContinueOnError)
+	output := flags.String("o", "yaml", "the output format (yaml, json, or html)")
+	input := flags.String("i", "", "the input format (yaml or json); inferred from the file extension by default")
+	noColor := flags.Bool("no-color", false, "disable syntax highlighting")
+	noLineNumbers := flags.Bool("no-line-numbers", false, "do not print line numbers")
+	themeFile := flags.String("theme", "", "a YAML file that maps token classes to styles")
+	colors := flags.String("colors", defaultColorMode(), "the colors supported by the terminal (16, 256, or truecolor)")
+	if err := flags.Parse(args[1:]); err != nil {
 		return err
 	}
-	tokens := lexer.Tokenize(string(bytes))
-	var p printer.Printer
-	p.LineNumber = true
-	p.LineNumberFormat = func(num int) string {
-		fn := color.New(color.Bold, color.FgHiWhite).SprintFunc()
-		return fn(fmt.Sprintf("%2d | ", num))
+	filenames := flags.Args()
+	if len(filenames) == 0 {
+		filenames = []string{"-"}
 	}
-	p.Bool = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiMagenta),
-			Suffix: format(color.Reset),
+
+	t := defaultTheme
+	if *themeFile != "" {
+		var err error
+		if t, err = readTheme(*themeFile); err != nil {
+			return err
 		}
 	}
-	p.Number = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiMagenta),
-			Suffix: format(color.Reset),
+
+	p := &printer.Printer{LineNumber: !*noLineNumbers}
+	var w io.Writer = os.Stdout
+	switch *output {
+	case "yaml":
+		if !*noColor {
+			var mode colorMode
+			switch *colors {
+			case "16":
+				mode = colors16
+			case "256":
+				mode = colors256
+			case "truecolor":
+				mode = colorsTrue
+			default:
+				return fmt.Errorf("ycat: unknown color mode %q", *colors)
+			}
+			t.ansiPrinter(p, mode)
+			w = colorable.NewColorableStdout()
 		}
-	}
-	p.MapKey = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiCyan),
-			Suffix: format(color.Reset),
+	case "html":
+		p.Escape = html.EscapeString
+		if !*noColor {
+			fmt.Fprintf(w, "<style>\n%s</style>\n", t.stylesheet())
 		}
+		t.htmlPrinter(p)
+	case "json":
+	default:
+		return fmt.Errorf("ycat: unknown output format %q", *output)
 	}
-	p.Anchor = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiYellow),
-			Suffix: format(color.Reset),
+
+	for _, filename := range filenames {
+		format := *input
+		switch {
+		case format == "" && strings.EqualFold(filepath.Ext(filename), ".json"):
+			format = "json"
+		case format == "":
+			format = "yaml"
+		case format != "yaml" && format != "json":
+			return fmt.Errorf("ycat: unknown input format %q", format)
 		}
-	}
-	p.Alias = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiYellow),
-			Suffix: format(color.Reset),
+
+		bytes, err := readInput(filename)
+		if err != nil {
+			return err
 		}
-	}
-	p.String = func() *printer.Property {
-		return &printer.Property{
-			Prefix: format(color.FgHiGreen),
-			Suffix: format(color.Reset),
+
+		if *output == "json" {
+			if format == "yaml" {
+				err = printJSON(w, filename, bytes)
+			} else {
+				var f *ast.File
+				if f, err = json.ParseBytes(bytes); err == nil {
+					err = (&json.Encoder{Indent: "  "}).EncodeFile(w, f)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if format == "json" {
+			if bytes, err = jsonToYAML(bytes); err != nil {
+				return err
+			}
+		}
+		text := p.PrintTokens(lexer.Tokenize(string(bytes)))
+		if *output == "html" {
+			text = `<pre class="yaml">` + text + "</pre>"
+		}
+		if _, err := fmt.Fprintln(w, text); err != nil {
+			return err
 		}
 	}
-	writer := colorable.NewColorableStdout()
-	writer.Write([]byte(p.PrintTokens(tokens) + "\n"))
 	return nil
 }
 
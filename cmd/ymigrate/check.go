@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/lexer"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/token"
+)
+
+var documentSeparator = regexp.MustCompile(`^---(\s|$)`)
+
+// check returns the constructs in a template that are rejected by the parser or that are not structurally aligned
+// with their YAML, ordered by position. Documents are checked separately, so each document reports at most one
+// error from the parser.
+func check(s *source, trees map[string]*parse.Tree) []error {
+	type issue struct {
+		offset int
+		err    error
+	}
+	var issues []issue
+
+	var tokens token.Tokens
+	report := func(offset int, format string, args ...interface{}) {
+		if tokens == nil {
+			tokens = lexer.Tokenize(s.text)
+		}
+		issues = append(issues, issue{offset: offset, err: errors.ErrSyntax(fmt.Sprintf(format, args...), tokenAt(s, tokens, offset))})
+	}
+
+	for _, doc := range s.documents() {
+		if _, err := parser.ParseBytes([]byte(doc.text), 0); err != nil {
+			issues = append(issues, issue{offset: doc.start, err: err})
+		}
+	}
+
+	for _, t := range trees {
+		if t.Root == nil {
+			continue
+		}
+		walkActions(t.Root, func(n *parse.ActionNode) {
+			fn, _ := reindent(n.Pipe)
+			switch _, include := includeValue(n.Pipe); {
+			case fn == "":
+			case include:
+				report(int(n.Pos), "an included template cannot add entries to the mapping or sequence that holds it: "+
+					"include the template as the value of a key or a sequence entry")
+			default:
+				report(int(n.Pos), "the result of %s is text, not YAML: the action must produce a YAML node", fn)
+			}
+		})
+	}
+
+	for _, b := range s.blocks {
+		if i, _ := s.straddle(b); i >= 0 {
+			report(b.open.start, "the body of this {{%s}} leaves the mapping or sequence in which it starts at line %d",
+				b.open.keyword, i+1)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].offset < issues[j].offset })
+	errs := make([]error, len(issues))
+	for i, issue := range issues {
+		errs[i] = issue.err
+	}
+	return errs
+}
+
+// A document is the text of a document in a template.
+type document struct {
+	start int    // the offset of the document in the template
+	text  string // the template's text with the lines of the other documents blanked
+}
+
+// documents returns the documents in a template. The lines of the other documents are blanked so that positions in
+// a document's text are positions in the template.
+func (s *source) documents() []document {
+	var docs []document
+	start := 0
+	for i, l := range s.lines {
+		last := i == len(s.lines)-1
+		if !last && (i == 0 || !documentSeparator.MatchString(s.text[l.start:l.end])) {
+			continue
+		}
+		end := l.start
+		if last {
+			end = len(s.text)
+		}
+		if strings.TrimSpace(s.text[start:end]) != "" {
+			text := strings.Repeat("\n", strings.Count(s.text[:start], "\n")) + s.text[start:end]
+			docs = append(docs, document{start: start, text: text})
+		}
+		start = end
+	}
+	return docs
+}
+
+// tokenAt returns the last token that starts at or before an offset of a template.
+func tokenAt(s *source, tokens token.Tokens, offset int) *token.Token {
+	line, column := s.position(offset)
+	var at *token.Token
+	for _, tk := range tokens {
+		if tk.Position.Line > line || tk.Position.Line == line && tk.Position.Column > column {
+			break
+		}
+		at = tk
+	}
+	if at == nil && len(tokens) != 0 {
+		at = tokens[0]
+	}
+	return at
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines that surround each hunk of a diff.
+const contextLines = 3
+
+// diff returns a unified diff between two versions of a file.
+func diff(path, before, after string) string {
+	a, b := splitLines(before), splitLines(after)
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Each op is a line of the diff: an unchanged line, a deleted line, or an inserted line.
+	type op struct {
+		kind byte
+		text string
+		i, j int // the indices of the line in a and b
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i, j})
+			i, j = i+1, j+1
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+
+		// Extend the hunk until it is followed by more than twice the context of unchanged lines.
+		start, end := k-contextLines, k
+		if start < 0 {
+			start = 0
+		}
+		for unchanged := 0; end < len(ops) && unchanged <= 2*contextLines; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > k && ops[end-1].kind == ' ' {
+			end--
+		}
+		if end += contextLines; end > len(ops) {
+			end = len(ops)
+		}
+
+		if sb.Len() == 0 {
+			name := strings.TrimPrefix(filepath.ToSlash(path), "/")
+			fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		aLines, bLines := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				aLines++
+			}
+			if o.kind != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", ops[start].i+1, aLines, ops[start].j+1, bLines)
+		for _, o := range ops[start:end] {
+			fmt.Fprintf(&sb, "%c%s\n", o.kind, o.text)
+		}
+		k = end
+	}
+	return sb.String()
+}
+
+// splitLines splits a text into lines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// An edit replaces the text between two offsets of a template.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies a list of non-overlapping edits to a text.
+func applyEdits(text string, edits []edit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var sb strings.Builder
+	last := 0
+	for _, e := range edits {
+		sb.WriteString(text[last:e.start])
+		sb.WriteString(e.text)
+		last = e.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// parseTrees parses a template with text/template/parse. Functions are not checked.
+func parseTrees(name, text string) (map[string]*parse.Tree, error) {
+	trees := map[string]*parse.Tree{}
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck | parse.ParseComments
+	if _, err := t.Parse(text, "", "", trees); err != nil {
+		return nil, err
+	}
+	return trees, nil
+}
+
+// walkActions calls fn for each action in a list of parse nodes, including the actions in nested lists.
+func walkActions(list *parse.ListNode, fn func(n *parse.ActionNode)) {
+	if list == nil {
+		return
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.ActionNode:
+			fn(n)
+		case *parse.IfNode:
+			walkActions(n.List, fn)
+			walkActions(n.ElseList, fn)
+		case *parse.RangeNode:
+			walkActions(n.List, fn)
+			walkActions(n.ElseList, fn)
+		case *parse.WithNode:
+			walkActions(n.List, fn)
+			walkActions(n.ElseList, fn)
+		}
+	}
+}
+
+// reindent returns the name of the function that re-indents the result of a pipeline, i.e. "indent" or "nindent",
+// and the number of spaces it indents by. It returns the empty string if the pipeline's result is not re-indented.
+func reindent(pipe *parse.PipeNode) (string, int) {
+	if len(pipe.Cmds) < 2 {
+		return "", 0
+	}
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if len(last.Args) != 2 {
+		return "", 0
+	}
+	fn, ok := last.Args[0].(*parse.IdentifierNode)
+	if !ok || fn.Ident != "indent" && fn.Ident != "nindent" {
+		return "", 0
+	}
+	n, ok := last.Args[1].(*parse.NumberNode)
+	if !ok || !n.IsInt {
+		return "", 0
+	}
+	return fn.Ident, int(n.Int64)
+}
+
+// toYamlValue returns the text of the value that a pipeline renders with toYaml before re-indenting the result, as in
+// "toYaml .Values.resources | nindent 12" or ".Values.resources | toYaml | nindent 12".
+func toYamlValue(pipe *parse.PipeNode) (string, bool) {
+	if len(pipe.Decl) != 0 || len(pipe.Cmds) < 2 {
+		return "", false
+	}
+	isToYaml := func(n parse.Node) bool {
+		id, ok := n.(*parse.IdentifierNode)
+		return ok && id.Ident == "toYaml"
+	}
+
+	switch cmds := pipe.Cmds[:len(pipe.Cmds)-1]; {
+	case len(cmds) == 1 && len(cmds[0].Args) == 2 && isToYaml(cmds[0].Args[0]):
+		return cmds[0].Args[1].String(), true
+	case len(cmds) == 2 && len(cmds[1].Args) == 1 && isToYaml(cmds[1].Args[0]):
+		return cmds[0].String(), true
+	}
+	return "", false
+}
+
+// includeValue returns the text of the include call whose result a pipeline re-indents, as in
+// "include "chart.labels" . | nindent 4".
+func includeValue(pipe *parse.PipeNode) (string, bool) {
+	if len(pipe.Decl) != 0 || len(pipe.Cmds) != 2 || len(pipe.Cmds[0].Args) == 0 {
+		return "", false
+	}
+	id, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+	if !ok || id.Ident != "include" {
+		return "", false
+	}
+	return pipe.Cmds[0].String(), true
+}
+
+// rewriteToYaml rewrites actions that render a value with toYaml and re-indent the result, e.g.
+//
+//	resources:
+//	  {{- toYaml .Values.resources | nindent 12 }}
+//
+// into actions that produce the value itself:
+//
+//	resources: {{ .Values.resources }}
+//
+// Actions that add entries to the mapping or sequence that holds them are rewritten into {{range}} actions over the
+// entries.
+//
+// Actions that re-indent the result of include are rewritten in the same way, as include produces a YAML node, e.g.
+// "labels: {{ include "chart.labels" . }}". An included template cannot add entries to the collection that holds it,
+// so such actions are left for check to report.
+func rewriteToYaml(s *source, trees map[string]*parse.Tree) string {
+	var edits []edit
+	for _, t := range trees {
+		if t.Root == nil {
+			continue
+		}
+		walkActions(t.Root, func(n *parse.ActionNode) {
+			fn, indent := reindent(n.Pipe)
+			value, ok := toYamlValue(n.Pipe)
+			entries := ok
+			if !ok {
+				value, ok = includeValue(n.Pipe)
+			}
+			a := s.actionAt(int(n.Pos))
+			if fn == "" || !ok || a == nil {
+				return
+			}
+			if e, ok := s.rewriteToYamlAction(a, value, fn, indent, entries); ok {
+				edits = append(edits, e)
+			}
+		})
+	}
+	return applyEdits(s.text, edits)
+}
+
+// rewriteToYamlAction rewrites an action that re-indents a value. If entries is false, an action that adds entries to
+// the collection that holds it is not rewritten.
+func (s *source) rewriteToYamlAction(a *action, value, fn string, indent int, entries bool) (edit, bool) {
+	i := s.lineOf(a.start)
+	l := s.lines[i]
+	if strings.TrimSpace(s.text[a.end:l.end]) != "" {
+		return edit{}, false
+	}
+	valueAction := "{{ " + value + " }}"
+
+	// If the action follows a key or a sequence entry indicator on its line, it produces the key's value or the
+	// entry.
+	prefix := s.text[l.start:a.start]
+	if p := strings.TrimRight(prefix, " \t"); p != "" {
+		if !strings.HasSuffix(p, ":") && !strings.HasSuffix(strings.TrimSpace(p), "-") {
+			return edit{}, false
+		}
+		return edit{start: l.start + len(p), end: a.end, text: " " + valueAction}, true
+	}
+
+	prev := s.previousLine(i, -1)
+	switch {
+	case prev != nil && !prev.control && strings.HasSuffix(s.content(prev), ":"):
+		// The action is on the line after a key: move it onto the key's line.
+		key := strings.TrimRight(s.text[prev.start:prev.end], " \t")
+		return edit{start: prev.start + len(key), end: l.end, text: " " + valueAction}, true
+	case prev != nil && prev.control:
+		// The action is the body of a control action.
+		return edit{start: a.start, end: a.end, text: valueAction}, true
+	}
+
+	// The action adds entries to the collection that holds it. The entries start at the indentation of the re-indented
+	// text.
+	if !entries {
+		return edit{}, false
+	}
+	if fn == "indent" {
+		indent += len(prefix)
+	}
+	pad := strings.Repeat(" ", indent)
+	open := "{{ "
+	if a.trimLeft {
+		open = "{{- "
+	}
+	var text string
+	if sibling := s.previousLine(i, indent); sibling != nil && strings.HasPrefix(s.content(sibling)+" ", "- ") {
+		text = pad + open + "range " + value + " }}\n" +
+			pad + "- {{ . }}\n" +
+			pad + open + "end }}"
+	} else {
+		text = pad + open + "range $key, $value := " + value + " }}\n" +
+			pad + "{{ $key }}: {{ $value }}\n" +
+			pad + open + "end }}"
+	}
+	return edit{start: l.start, end: l.end, text: text}, true
+}
+
+// previousLine returns the closest non-blank line before the line at index i. If indent is not negative, it returns
+// the closest line at that indentation instead, skipping more indented and control lines, and stopping at less
+// indented lines.
+func (s *source) previousLine(i, indent int) *line {
+	for i--; i >= 0; i-- {
+		l := s.lines[i]
+		switch {
+		case l.blank:
+			continue
+		case indent < 0:
+			return l
+		case l.control || l.indent > indent:
+			continue
+		case l.indent == indent:
+			return l
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// straddle returns the index of the first line of a block's body that belongs to a different mapping or sequence than
+// the body's first line, i.e. the first line that is less indented than the body's first line. It returns -1 if there
+// is no such line. nested is true if the line is nested in another block.
+func (s *source) straddle(b *block) (idx int, nested bool) {
+	first, last := s.lineOf(b.open.end), s.lineOf(b.end.start)
+	base, depth := -1, 0
+	for i := first + 1; i < last; i++ {
+		l := s.lines[i]
+		if !l.blank && !l.control {
+			switch {
+			case base < 0:
+				base = l.indent
+			case l.indent < base:
+				return i, depth != 0
+			}
+		}
+		for _, a := range s.actions {
+			if a.start >= l.start && a.start < l.end {
+				switch {
+				case blockKeywords[a.keyword]:
+					depth++
+				case a.keyword == "end":
+					depth--
+				}
+			}
+		}
+	}
+	return -1, false
+}
+
+// splittable returns true if a straddling block can be split into one block per collection without changing its
+// output. Only {{with}} and {{if}} blocks without {{else}} branches can be split.
+func splittable(b *block) bool {
+	return (b.open.keyword == "with" || b.open.keyword == "if") && len(b.els) == 0
+}
+
+// splitStraddlingBlocks splits {{with}} and {{if}} blocks whose bodies leave the mapping or sequence in which they
+// start, e.g.
+//
+//	metadata:
+//	  {{- with .Values.annotations }}
+//	  annotations: {{ . }}
+//	spec: {{ $.Values.spec }}
+//	{{- end }}
+//
+// into one block per collection:
+//
+//	metadata:
+//	  {{- with .Values.annotations }}
+//	  annotations: {{ . }}
+//	  {{- end }}
+//	{{- with .Values.annotations }}
+//	spec: {{ $.Values.spec }}
+//	{{- end }}
+func splitStraddlingBlocks(text string) string {
+	for {
+		s := newSource(text)
+		split := false
+		for _, b := range s.blocks {
+			i, nested := s.straddle(b)
+			if i < 0 || nested || !splittable(b) {
+				continue
+			}
+
+			l, body := s.lines[i], s.lines[s.lineOf(b.open.end)+1]
+			for j := s.lineOf(b.open.end) + 1; j < i; j++ {
+				if body = s.lines[j]; !body.blank && !body.control {
+					break
+				}
+			}
+			end := strings.Repeat(" ", body.indent) + s.text[b.end.start:b.end.end] + "\n"
+			open := strings.Repeat(" ", l.indent) + s.text[b.open.start:b.open.end] + "\n"
+			text = applyEdits(text, []edit{{start: l.start, end: l.start, text: end + open}})
+			split = true
+			break
+		}
+		if !split {
+			return text
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// An action is a template action in the text of a template.
+type action struct {
+	start, end int    // the offsets of the action's delimiters
+	trimLeft   bool   // true if the action starts with a left trim marker, as in "{{- "
+	text       string // the text between the action's delimiters and trim markers
+	keyword    string // the action's keyword, e.g. "if" or "end", "comment" for comments, or empty
+}
+
+// A line is a line of the text of a template.
+type line struct {
+	start, end int  // the offsets of the line's text, excluding its line break
+	indent     int  // the number of spaces that precede the line's text
+	blank      bool // true if the line holds nothing but whitespace
+	control    bool // true if the line holds nothing but control actions and whitespace
+}
+
+// A block is an {{if}}, {{range}}, {{with}}, {{define}}, or {{block}} action and the actions that continue it.
+type block struct {
+	open *action   // the action that starts the block
+	els  []*action // the block's {{else}} actions, if any
+	end  *action   // the block's {{end}} action
+}
+
+// A source holds the text of a template and the actions, lines, and blocks in it.
+type source struct {
+	text    string
+	actions []*action
+	lines   []*line
+	blocks  []*block
+}
+
+// The keywords that start blocks.
+var blockKeywords = map[string]bool{"if": true, "range": true, "with": true, "define": true, "block": true}
+
+// The keywords of control actions, which produce no YAML of their own.
+var controlKeywords = map[string]bool{
+	"if": true, "range": true, "with": true, "define": true, "block": true,
+	"else": true, "end": true, "break": true, "continue": true, "comment": true,
+}
+
+func newSource(text string) *source {
+	s := &source{text: text, actions: scanActions(text)}
+
+	for start := 0; start <= len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		content := text[start:end]
+		s.lines = append(s.lines, &line{
+			start:  start,
+			end:    end,
+			indent: len(content) - len(strings.TrimLeft(content, " ")),
+			blank:  strings.TrimSpace(content) == "",
+		})
+		start = end + 1
+	}
+
+	// A line is a control line if it holds control actions and removing them leaves it blank.
+	lineActions := make([][]*action, len(s.lines))
+	for _, a := range s.actions {
+		i := s.lineOf(a.start)
+		lineActions[i] = append(lineActions[i], a)
+	}
+	for i, actions := range lineActions {
+		l := s.lines[i]
+		if len(actions) == 0 || actions[len(actions)-1].end > l.end {
+			continue
+		}
+		var content strings.Builder
+		last := l.start
+		for _, a := range actions {
+			content.WriteString(text[last:a.start])
+			if !controlKeywords[a.keyword] {
+				content.WriteString(text[a.start:a.end])
+			}
+			last = a.end
+		}
+		content.WriteString(text[last:l.end])
+		l.control = strings.TrimSpace(content.String()) == ""
+	}
+
+	var stack []*block
+	for _, a := range s.actions {
+		switch {
+		case blockKeywords[a.keyword]:
+			stack = append(stack, &block{open: a})
+		case a.keyword == "else" && len(stack) != 0:
+			top := stack[len(stack)-1]
+			top.els = append(top.els, a)
+		case a.keyword == "end" && len(stack) != 0:
+			top := stack[len(stack)-1]
+			top.end, stack = a, stack[:len(stack)-1]
+			s.blocks = append(s.blocks, top)
+		}
+	}
+	sort.Slice(s.blocks, func(i, j int) bool { return s.blocks[i].open.start < s.blocks[j].open.start })
+	return s
+}
+
+// lineOf returns the index of the line that holds the given offset.
+func (s *source) lineOf(offset int) int {
+	return sort.Search(len(s.lines), func(i int) bool { return s.lines[i].end >= offset })
+}
+
+// content returns the text of a line without its indentation and trailing whitespace.
+func (s *source) content(l *line) string {
+	return strings.TrimSpace(s.text[l.start:l.end])
+}
+
+// actionAt returns the action that holds the given offset, or nil if there is none.
+func (s *source) actionAt(offset int) *action {
+	i := sort.Search(len(s.actions), func(i int) bool { return s.actions[i].end > offset })
+	if i < len(s.actions) && s.actions[i].start <= offset {
+		return s.actions[i]
+	}
+	return nil
+}
+
+// position returns the one-based line and column of an offset.
+func (s *source) position(offset int) (int, int) {
+	i := s.lineOf(offset)
+	return i + 1, offset - s.lines[i].start + 1
+}
+
+// scanActions returns the template actions in a text.
+func scanActions(text string) []*action {
+	var actions []*action
+	for i := 0; ; {
+		j := strings.Index(text[i:], "{{")
+		if j < 0 {
+			return actions
+		}
+		start := i + j
+		end := actionEnd(text, start+2)
+		if end < 0 {
+			return actions
+		}
+
+		a := &action{start: start, end: end}
+		body := text[start+2 : end-2]
+		if len(body) > 1 && body[0] == '-' && isSpace(body[1]) {
+			a.trimLeft, body = true, body[2:]
+		}
+		if n := len(body); n > 1 && body[n-1] == '-' && isSpace(body[n-2]) {
+			body = body[:n-2]
+		}
+		a.text = strings.TrimSpace(body)
+		a.keyword = keyword(a.text)
+		actions = append(actions, a)
+		i = end
+	}
+}
+
+// actionEnd returns the offset that follows the right delimiter of an action whose text starts at offset i, or -1 if
+// the action is not terminated.
+func actionEnd(text string, i int) int {
+	for i < len(text) {
+		switch c := text[i]; {
+		case strings.HasPrefix(text[i:], "}}"):
+			return i + 2
+		case strings.HasPrefix(text[i:], "/*"):
+			k := strings.Index(text[i+2:], "*/")
+			if k < 0 {
+				return -1
+			}
+			i += k + 4
+		case c == '"' || c == '\'':
+			for i++; i < len(text) && text[i] != c; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		case c == '`':
+			k := strings.IndexByte(text[i+1:], '`')
+			if k < 0 {
+				return -1
+			}
+			i += k + 2
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// keyword returns the keyword of an action's text, if any.
+func keyword(text string) string {
+	if strings.HasPrefix(text, "/*") {
+		return "comment"
+	}
+	word := text
+	if i := strings.IndexFunc(text, func(r rune) bool { return r < 0x80 && isSpace(byte(r)) }); i >= 0 {
+		word = text[:i]
+	}
+	if controlKeywords[word] {
+		return word
+	}
+	return ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pgavlin/yomlette/parser"
+)
+
+// findTemplates returns the template files named by the arguments. Directories are searched recursively for files
+// with the extension .yml, .yaml, or .tpl.
+func findTemplates(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !info.IsDir() && (ext == ".yml" || ext == ".yaml" || ext == ".tpl") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// migrate rewrites the constructs of a text-based template that this library's parser rejects or misreads into
+// structurally valid equivalents. It returns the rewritten template and the constructs that could not be rewritten.
+func migrate(path, text string) (string, []error, error) {
+	trees, err := parseTrees(path, text)
+	if err != nil {
+		return "", nil, err
+	}
+	text = rewriteToYaml(newSource(text), trees)
+	text = splitStraddlingBlocks(text)
+
+	if trees, err = parseTrees(path, text); err != nil {
+		return "", nil, fmt.Errorf("%s: migration produced an invalid template: %v", path, err)
+	}
+	return text, check(newSource(text), trees), nil
+}
+
+func _main(args []string) error {
+	flags := flag.NewFlagSet("ymigrate", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write migrated templates back to their files instead of printing a diff")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("ymigrate: usage: ymigrate [-w] template.yaml|dir...")
+	}
+
+	paths, err := findTemplates(flags.Args())
+	if err != nil {
+		return err
+	}
+	issues := 0
+	for _, path := range paths {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		migrated, errs, err := migrate(path, string(source))
+		if err != nil {
+			return err
+		}
+
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, parser.FormatError(err, isTerminal(os.Stderr), true))
+		}
+		issues += len(errs)
+
+		if migrated == string(source) {
+			continue
+		}
+		if *write {
+			if err := ioutil.WriteFile(path, []byte(migrated), 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Print(diff(path, string(source), migrated))
+	}
+	switch issues {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("ymigrate: 1 construct could not be migrated")
+	default:
+		return fmt.Errorf("ymigrate: %d constructs could not be migrated", issues)
+	}
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func main() {
+	if err := _main(os.Args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", parser.FormatError(err, isTerminal(os.Stderr), true))
+		os.Exit(1)
+	}
+}
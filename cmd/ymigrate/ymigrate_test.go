@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+		issues []string
+	}{
+		{
+			source: "resources:\n  {{- toYaml .Values.resources | nindent 2 }}\n",
+			expect: "resources: {{ .Values.resources }}\n",
+		},
+		{
+			source: "metadata:\n  labels:\n    {{- include \"chart.labels\" . | nindent 4 }}\n",
+			expect: "metadata:\n  labels: {{ include \"chart.labels\" . }}\n",
+		},
+		{
+			source: "metadata:\n  labels: {{- include \"chart.labels\" . | indent 4 }}\n",
+			expect: "metadata:\n  labels: {{ include \"chart.labels\" . }}\n",
+		},
+		{
+			source: "containers:\n  - {{- include \"chart.container\" . | nindent 4 }}\n",
+			expect: "containers:\n  - {{ include \"chart.container\" . }}\n",
+		},
+		{
+			// Quoted actions with nested quotes are valid as they are.
+			source: "image: \"{{ .Values.image.repository | default \"nginx\" }}:{{ .Values.image.tag }}\"\n" +
+				"name: {{ .Values.name | default \"app\" | quote }}\n",
+			expect: "image: \"{{ .Values.image.repository | default \"nginx\" }}:{{ .Values.image.tag }}\"\n" +
+				"name: {{ .Values.name | default \"app\" | quote }}\n",
+		},
+		{
+			source: "labels:\n  a: b\n  {{- include \"chart.labels\" . | nindent 2 }}\n",
+			expect: "labels:\n  a: b\n  {{- include \"chart.labels\" . | nindent 2 }}\n",
+			issues: []string{"[3:3] an included template cannot add entries to the mapping or sequence that holds it"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			actual, errs, err := migrate("template.yaml", tt.source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expect {
+				t.Fatalf("expected:\n%s\nactual:\n%s", tt.expect, actual)
+			}
+			if len(errs) != len(tt.issues) {
+				t.Fatalf("expected %d issues, got %v", len(tt.issues), errs)
+			}
+			for i, err := range errs {
+				if !strings.HasPrefix(err.Error(), tt.issues[i]) {
+					t.Fatalf("expected an issue starting with %q, got %v", tt.issues[i], err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+)
+
+// selectPath returns the node at the given path relative to node. A path is a sequence of selectors: .key selects the
+// value of a mapping key, ["key"] selects a key that contains special characters, and [n] selects the nth element of a
+// sequence. Anchors, tags, and mapping keys are looked through when a selector is applied to them.
+func selectPath(node ast.Node, path string) (ast.Node, error) {
+	for rest := path; rest != ""; {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key, rest = rest[1:end+1], rest[end+1:]
+		case strings.HasPrefix(rest, `["`):
+			end := strings.Index(rest, `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated key", path)
+			}
+			unquoted, err := strconv.Unquote(rest[1 : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			key, rest = unquoted, rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unterminated index", path)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid path %q: invalid index %q", path, rest[1:end])
+			}
+			index, rest = i, rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q: expected '.' or '['", path)
+		}
+
+		selected := selectChild(unwrap(node), key, index)
+		if selected == nil {
+			return nil, fmt.Errorf("path %q: no node at %q", path, path[:len(path)-len(rest)])
+		}
+		node = selected
+	}
+	return node, nil
+}
+
+// unwrap returns the node annotated by any anchors, tags, or mapping keys.
+func unwrap(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.TagNode:
+			node = n.Value
+		case *ast.MappingKeyNode:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+// selectChild returns the value of the given key of a mapping if index is negative, or the element at index of a
+// sequence otherwise.
+func selectChild(node ast.Node, key string, index int) ast.Node {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if index < 0 {
+			for _, v := range n.Values {
+				if mapKey(v) == key {
+					return v.Value
+				}
+			}
+		}
+	case *ast.MappingValueNode:
+		if index < 0 && mapKey(n) == key {
+			return n.Value
+		}
+	case *ast.SequenceNode:
+		if index >= 0 && index < len(n.Values) {
+			return n.Values[index]
+		}
+	}
+	return nil
+}
+
+func mapKey(v *ast.MappingValueNode) string {
+	if v.Key == nil {
+		return ""
+	}
+	if s, ok := unwrap(v.Key).(*ast.StringNode); ok {
+		return s.Value
+	}
+	return unwrap(v.Key).GetToken().Value
+}
@@ -2,30 +2,85 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/pgavlin/yomlette/ast"
 	"github.com/pgavlin/yomlette/parser"
 )
 
-func _main(args []string) error {
-	if len(args) < 2 {
-		return errors.New("yparse: usage: yparse file.yml")
+func _main(w io.Writer, args []string) error {
+	flags := flag.NewFlagSet("yparse", flag.ContinueOnError)
+	format := flags.String("format", "dump", "the output format (dump, json, or sexpr)")
+	doc := flags.Int("doc", -1, "print only the document with the given index")
+	path := flags.String("path", "", "print only the subtree at the given path, e.g. .spec.containers[0] "+
+		"(requires --doc if the file has more than one document)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("yparse: usage: yparse [--format dump|json|sexpr] [--doc n] [--path path] file.yml")
+	}
+
+	var print func(w io.Writer, n ast.Node) error
+	switch *format {
+	case "dump":
+		print = ast.Dump
+	case "json":
+		print = ast.DumpJSON
+	case "sexpr":
+		print = ast.DumpSExpr
+	default:
+		return fmt.Errorf("yparse: unknown format %q", *format)
 	}
-	filename := args[1]
+
+	filename := flags.Arg(0)
 	file, err := parser.ParseFile(filename, parser.ParseComments)
 	if err != nil {
 		return err
 	}
-	for _, doc := range file.Docs {
-		ast.Dump(os.Stdout, doc)
+
+	if *doc < 0 && *path != "" {
+		if len(file.Docs) != 1 {
+			return fmt.Errorf("yparse: --path requires --doc: %s has %d documents", filename, len(file.Docs))
+		}
+		*doc = 0
+	}
+	if *doc < 0 {
+		for _, doc := range file.Docs {
+			if err := print(w, doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if *doc >= len(file.Docs) {
+		return fmt.Errorf("yparse: %s has %d documents", filename, len(file.Docs))
+	}
+	var node ast.Node = file.Docs[*doc]
+	if *path != "" {
+		if node, err = selectPath(file.Docs[*doc].Body, *path); err != nil {
+			return err
+		}
 	}
-	return nil
+	return print(w, node)
+}
+
+// isTerminal returns true if the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func main() {
-	if err := _main(os.Args); err != nil {
-		fmt.Printf("%v\n", parser.FormatError(err, true, true))
+	if err := _main(os.Stdout, os.Args); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "%v\n", parser.FormatError(err, isTerminal(os.Stderr), true))
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestYParse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yparse")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	one, two := filepath.Join(dir, "one.yml"), filepath.Join(dir, "two.yml")
+	if err := ioutil.WriteFile(one, []byte("spec:\n  ports: [80]\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := ioutil.WriteFile(two, []byte("a: 1\n---\nb: 2\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := []struct {
+		args   []string
+		expect string
+		err    string
+	}{
+		{args: []string{"--format", "sexpr", "--path", ".spec.ports[0]", one}, expect: "(Integer @2:11 \"80\" :Value \"80\")\n"},
+		{args: []string{"--format", "sexpr", "--doc", "1", "--path", ".b", two}, expect: "(Integer @3:4 \"2\" :Value \"2\")\n"},
+		{args: []string{"--path", ".a", two}, err: "yparse: --path requires --doc: " + two + " has 2 documents"},
+	}
+	for _, test := range tests {
+		t.Run(test.args[len(test.args)-2], func(t *testing.T) {
+			var buf bytes.Buffer
+			err := _main(&buf, append([]string{"yparse"}, test.args...))
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("expected: [%s] but got [%v]", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if actual := buf.String(); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := _main(&buf, []string{"yparse", "-h"}); err != flag.ErrHelp {
+		t.Fatalf("expected flag.ErrHelp, got %v", err)
+	}
+}
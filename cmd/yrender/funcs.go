@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pgavlin/yomlette/funclib"
+)
+
+// funcSets names the functions of the function library that may be made available to templates with the --funcs flag.
+// The helm set holds every function in the library.
+var funcSets = map[string][]string{
+	"strings": {
+		"upper", "lower", "title", "trim", "trimAll", "trimPrefix", "trimSuffix", "replace", "contains", "hasPrefix",
+		"hasSuffix", "repeat", "substr", "trunc", "nospace", "quote", "squote", "cat", "indent", "nindent", "splitList",
+		"join",
+	},
+	"encoding": {"b64enc", "b64dec", "toYaml", "fromYaml", "toJson", "fromJson"},
+	"helm":     nil,
+}
+
+// loadFuncs returns the union of the named function sets.
+func loadFuncs(names []string) (map[string]interface{}, error) {
+	lib, funcs := funclib.Map(funclib.Options{}), map[string]interface{}{}
+	for _, name := range names {
+		set, ok := funcSets[name]
+		if !ok {
+			known := make([]string, 0, len(funcSets))
+			for name := range funcSets {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("yrender: unknown function set %q (known sets: %s)", name, strings.Join(known, ", "))
+		}
+		if set == nil {
+			for fn := range lib {
+				set = append(set, fn)
+			}
+		}
+		for _, fn := range set {
+			funcs[fn] = lib[fn]
+		}
+	}
+	return funcs, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pgavlin/yomlette/decode"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+// readValues reads the data for a template from a YAML file. The file is parsed as plain YAML, so strings that look
+// like template actions are read verbatim.
+func readValues(filename string) (interface{}, error) {
+	file, err := parser.ParseFile(filename, parser.ParseBigNumbers|parser.ParseNoTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.ResolveAliases(file, parser.AliasLimits{}); err != nil {
+		return nil, err
+	}
+	if err := parser.ExpandMerges(file); err != nil {
+		return nil, err
+	}
+	if len(file.Docs) == 0 {
+		return nil, nil
+	}
+	return decode.Value(file.Docs[0].Body)
+}
+
+// mergeValues merges src into dst and returns the result. Mappings are merged key by key; any other value in src
+// replaces the corresponding value in dst.
+func mergeValues(dst, src interface{}) interface{} {
+	d, ok := dst.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	s, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+	for k, v := range s {
+		d[k] = mergeValues(d[k], v)
+	}
+	return d
+}
+
+// maxIndex is the largest list index that an override may set. Setting an index extends the list with nulls as
+// needed, so the limit bounds the memory that a single override may allocate.
+const maxIndex = 65536
+
+// A pathElement is a mapping key or, if index is not negative, a list index in the path of an override.
+type pathElement struct {
+	key   string
+	index int
+}
+
+// parsePath parses the path of an override, e.g. a.b[0].c.
+func parsePath(path string) ([]pathElement, error) {
+	var elements []pathElement
+	for _, part := range strings.Split(path, ".") {
+		key, indices := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, indices = part[:i], part[i:]
+		}
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		elements = append(elements, pathElement{key: key, index: -1})
+
+		for indices != "" {
+			end := strings.IndexByte(indices, ']')
+			if indices[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("malformed index in %q", part)
+			}
+			index, err := strconv.Atoi(indices[1:end])
+			if err != nil || index < 0 || index > maxIndex {
+				return nil, fmt.Errorf("invalid index %q", indices[1:end])
+			}
+			elements = append(elements, pathElement{index: index})
+			indices = indices[end+1:]
+		}
+	}
+	return elements, nil
+}
+
+// setPath sets the value at the given path in a container and returns the container. Mappings and lists are created
+// as needed, and lists are extended with nulls as needed. The value is merged into the value it replaces.
+func setPath(container interface{}, path []pathElement, value interface{}) interface{} {
+	if len(path) == 0 {
+		return mergeValues(container, value)
+	}
+	if p := path[0]; p.index >= 0 {
+		l, _ := container.([]interface{})
+		for len(l) <= p.index {
+			l = append(l, nil)
+		}
+		l[p.index] = setPath(l[p.index], path[1:], value)
+		return l
+	}
+	m, ok := container.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+	}
+	m[path[0].key] = setPath(m[path[0].key], path[1:], value)
+	return m
+}
+
+// setValue applies an override of the form a.b[0].c=value to the given values. The value is parsed as YAML, so
+// --set a=1 sets a to an integer and --set a="1" sets it to a string. Intermediate mappings and lists are created as
+// needed.
+func setValue(values interface{}, override string) (interface{}, error) {
+	eq := strings.IndexByte(override, '=')
+	if eq <= 0 {
+		return nil, fmt.Errorf("yrender: invalid override %q: expected key=value", override)
+	}
+	path, err := parsePath(override[:eq])
+	if err != nil {
+		return nil, fmt.Errorf("yrender: invalid override %q: %v", override, err)
+	}
+	text := override[eq+1:]
+
+	var value interface{} = ""
+	if text != "" {
+		file, err := parser.ParseBytes([]byte(text), parser.ParseBigNumbers|parser.ParseNoTemplates)
+		if err != nil {
+			return nil, fmt.Errorf("yrender: invalid override %q: %v", override, err)
+		}
+		if len(file.Docs) != 0 {
+			if value, err = decode.Value(file.Docs[0].Body); err != nil {
+				return nil, fmt.Errorf("yrender: invalid override %q: %v", override, err)
+			}
+		}
+	}
+	return setPath(values, path, value), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetValue(t *testing.T) {
+	tests := []struct {
+		values    interface{}
+		overrides []string
+		expect    interface{}
+	}{
+		{
+			overrides: []string{"a.b=1", "a.c=x"},
+			expect:    map[string]interface{}{"a": map[string]interface{}{"b": uint64(1), "c": "x"}},
+		},
+		{
+			values:    map[string]interface{}{"ports": []interface{}{uint64(80), uint64(443)}},
+			overrides: []string{"ports[0]=8080"},
+			expect:    map[string]interface{}{"ports": []interface{}{uint64(8080), uint64(443)}},
+		},
+		{
+			overrides: []string{"ports[1]=80"},
+			expect:    map[string]interface{}{"ports": []interface{}{nil, uint64(80)}},
+		},
+		{
+			values: map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "web", "image": "nginx"}},
+			},
+			overrides: []string{"containers[0].image=httpd", "containers[1].args[0][1]=x"},
+			expect: map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "web", "image": "httpd"},
+					map[string]interface{}{"args": []interface{}{[]interface{}{nil, "x"}}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.overrides, " "), func(t *testing.T) {
+			values := test.values
+			for _, override := range test.overrides {
+				var err error
+				if values, err = setValue(values, override); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}
+			if !reflect.DeepEqual(values, test.expect) {
+				t.Fatalf("expected: %#v but got %#v", test.expect, values)
+			}
+		})
+	}
+}
+
+func TestSetValueErrors(t *testing.T) {
+	tests := []struct {
+		override string
+		expect   string
+	}{
+		{"a", `yrender: invalid override "a": expected key=value`},
+		{"a..b=1", `yrender: invalid override "a..b=1": empty key`},
+		{"[0]=1", `yrender: invalid override "[0]=1": empty key`},
+		{"a[x]=1", `yrender: invalid override "a[x]=1": invalid index "x"`},
+		{"a[-1]=1", `yrender: invalid override "a[-1]=1": invalid index "-1"`},
+		{"a[100000]=1", `yrender: invalid override "a[100000]=1": invalid index "100000"`},
+		{"a[0=1", `yrender: invalid override "a[0=1": malformed index in "a[0"`},
+		{"a[0]b=1", `yrender: invalid override "a[0]b=1": malformed index in "a[0]b"`},
+	}
+	for _, test := range tests {
+		t.Run(test.override, func(t *testing.T) {
+			_, err := setValue(nil, test.override)
+			if err == nil || err.Error() != test.expect {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
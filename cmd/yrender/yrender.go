@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/lexer"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/printer"
+)
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// template is a template file to render.
+type template struct {
+	// path is the path of the template file.
+	path string
+	// name is the path of the template relative to the directory argument that contained it, or the base name of the
+	// file if it was named directly. It names the output file in the output directory.
+	name string
+	// helper is true if the file only defines templates for the other files, as do the _helpers.tpl files of Helm
+	// charts. Helpers are not rendered.
+	helper bool
+}
+
+// isHelper returns true if the file at the given path only defines templates for other files: its name starts with an
+// underscore or has the extension .tpl.
+func isHelper(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "_") || filepath.Ext(path) == ".tpl"
+}
+
+// findTemplates returns the template files named by the arguments. Directories are searched recursively for files
+// with the extension .yml, .yaml, or .tpl.
+func findTemplates(args []string) ([]template, error) {
+	var templates []template
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			templates = append(templates, template{path: arg, name: filepath.Base(arg), helper: isHelper(arg)})
+			continue
+		}
+
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); info.IsDir() || ext != ".yml" && ext != ".yaml" && ext != ".tpl" {
+				return nil
+			}
+			name, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, template{path: path, name: name, helper: isHelper(path)})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// options holds the settings for rendering templates.
+type options struct {
+	executor  executor.Executor
+	outputDir string
+	trace     bool
+	traceJSON bool
+	// helpers holds a document that defines the templates of the helper files, or nil if there are none.
+	helpers *ast.DocumentNode
+}
+
+// parse parses a template file.
+func (o *options) parse(t template) ([]byte, *ast.File, error) {
+	source, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	config := parser.Config{Funcs: o.executor.Funcs, Filename: t.path}
+	file, err := config.ParseBytes(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	return source, file, nil
+}
+
+// loadHelpers collects the templates defined by the helper files into a single document that produces no output.
+// The rest of each helper file is ignored.
+func (o *options) loadHelpers(templates []template) error {
+	var defines []ast.Node
+	for _, t := range templates {
+		if !t.helper {
+			continue
+		}
+		_, file, err := o.parse(t)
+		if err != nil {
+			return err
+		}
+		defines = append(defines, ast.FilterFile(ast.DefineType, file)...)
+	}
+	if len(defines) != 0 {
+		o.helpers = ast.Document(nil, ast.TemplateList(defines[0].GetToken(), ast.List(defines...)))
+	}
+	return nil
+}
+
+// render renders a template and returns the rendered YAML. The templates defined by the helper files may be invoked
+// by the template.
+func (o *options) render(t template, data interface{}) ([]byte, error) {
+	source, file, err := o.parse(t)
+	if err != nil {
+		return nil, err
+	}
+	if o.helpers != nil {
+		file.Docs = append([]*ast.DocumentNode{o.helpers}, file.Docs...)
+	}
+
+	result, err := o.executor.Execute(file, data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := result.Render(&buf); err != nil {
+		return nil, err
+	}
+
+	if o.trace {
+		var p printer.Printer
+		fmt.Fprintln(os.Stderr, result.Trace.Annotate(&p, lexer.Tokenize(string(source))))
+	}
+	if o.traceJSON {
+		if err := result.Trace.WriteJSON(os.Stderr); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func _main(args []string) error {
+	var values, overrides stringsFlag
+	flags := flag.NewFlagSet("yrender", flag.ContinueOnError)
+	flags.Var(&values, "f", "a YAML file of values; may be repeated, in which case later files override earlier ones")
+	flags.Var(&values, "values", "same as -f")
+	flags.Var(&overrides, "set", "override a value, e.g. --set a.b=c or --set ports[0]=80; may be repeated")
+	funcs := flags.String("funcs", "", "a comma-separated list of function sets to make available (strings, encoding, helm)")
+	strict := flags.Bool("strict", false, "fail if a template indexes a map with a key that is not present")
+	outputDir := flags.String("output-dir", "", "write each rendered template to a file in this directory")
+	trace := flags.Bool("trace", false, "print an annotated execution trace to stderr")
+	traceJSON := flags.Bool("trace-json", false, "print the execution trace to stderr as JSON")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() < 1 {
+		return errors.New("yrender: usage: yrender [-f values.yml]... [--set key=value]... [--funcs sets] [--strict] " +
+			"[--output-dir dir] [--trace] [--trace-json] template.yml|dir...")
+	}
+
+	var data interface{}
+	for _, filename := range values {
+		v, err := readValues(filename)
+		if err != nil {
+			return err
+		}
+		data = mergeValues(data, v)
+	}
+	for _, override := range overrides {
+		var err error
+		if data, err = setValue(data, override); err != nil {
+			return err
+		}
+	}
+
+	o := options{outputDir: *outputDir, trace: *trace, traceJSON: *traceJSON}
+	o.executor.Trace = *trace || *traceJSON
+	if *strict {
+		o.executor.MissingKey = executor.MissingKeyError
+	}
+	if *funcs != "" {
+		var err error
+		if o.executor.Funcs, err = loadFuncs(strings.Split(*funcs, ",")); err != nil {
+			return err
+		}
+	}
+
+	templates, err := findTemplates(flags.Args())
+	if err != nil {
+		return err
+	}
+	if err := o.loadHelpers(templates); err != nil {
+		return err
+	}
+	first := true
+	for _, t := range templates {
+		if t.helper {
+			continue
+		}
+		rendered, err := o.render(t, data)
+		if err != nil {
+			return err
+		}
+
+		if o.outputDir != "" {
+			path := filepath.Join(o.outputDir, t.name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, rendered, 0644); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(rendered) == 0 {
+			continue
+		}
+		if !first && !bytes.HasPrefix(rendered, []byte("---")) {
+			os.Stdout.WriteString("---\n")
+		}
+		if _, err := os.Stdout.Write(rendered); err != nil {
+			return err
+		}
+		first = false
+	}
+	return nil
+}
+
+func main() {
+	if err := _main(os.Args); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "%v\n", parser.FormatError(err, true, true))
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadFuncs(t *testing.T) {
+	funcs, err := loadFuncs([]string{"strings", "encoding"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, name := range []string{"upper", "quote", "b64enc", "toJson", "toYaml"} {
+		if funcs[name] == nil {
+			t.Fatalf("missing function %q", name)
+		}
+	}
+	if _, ok := funcs["include"]; ok {
+		t.Fatalf("unexpected function include")
+	}
+
+	if _, err := loadFuncs([]string{"text"}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestRenderHelpers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yrender")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"templates/_helpers.tpl":   "{{ define \"labels\" }}\napp: {{ .name }}\n{{ end }}\n",
+		"templates/service.yaml":   "kind: Service\nlabels: {{ include \"labels\" . }}\n",
+		"templates/configmap.yaml": "kind: ConfigMap\nname: {{ .name }}\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	args := []string{"yrender", "--funcs", "helm", "--set", "name=web", "--output-dir", out, filepath.Join(dir, "templates")}
+	if err := _main(args); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expect := map[string]string{
+		"service.yaml":   "kind: Service\nlabels:\n  app: web\n",
+		"configmap.yaml": "kind: ConfigMap\nname: web\n",
+	}
+	rendered, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(rendered) != len(expect) {
+		t.Fatalf("expected %d files, got %d", len(expect), len(rendered))
+	}
+	for name, text := range expect {
+		actual, err := ioutil.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if string(actual) != text {
+			t.Fatalf("%s: expected: [%s] but got [%s]", name, text, actual)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+// Package decode converts YAML syntax trees into Go values.
+package decode
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Value converts a node into a Go value. Mappings are converted to map[string]interface{} values, sequences are
+// converted to []interface{} values, and scalars are converted to the values returned by their GetValue methods.
+// Mapping keys that are not strings are converted to their source text.
+//
+// Merge keys are expanded in place by parser.ExpandMerges before conversion. Aliases must be resolved (see
+// parser.ResolveAliases) and templates must be executed before conversion.
+func Value(node ast.Node) (interface{}, error) {
+	node, err := expandMerges(node)
+	if err != nil {
+		return nil, err
+	}
+	v, err := value(node)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode")
+	}
+	return v, nil
+}
+
+// Decode stores the value of a node in the value pointed to by v. The node is decoded according to the type of the
+// value:
+//
+//   - nulls set the value to its zero value
+//   - booleans, integers, floats, and strings decode into values of the corresponding kinds. Integers also decode
+//     into floats, and any scalar decodes into a string as its source text.
+//   - integers decode into big.Int values, and integers and floats decode into big.Float values, so overflowing
+//     numbers parsed with parser.ParseBigNumbers are decoded exactly
+//   - timestamps decode into time.Time values. Untagged timestamps are only resolved under the YAML 1.1 schema, so
+//     strings that are timestamps also decode into time.Time values.
+//   - binary values decode into byte slices
+//   - sequences decode into slices and arrays
+//   - mappings decode into maps and structs. Struct fields are matched with the names given by their `yaml` tags, or
+//     with their lowercased names if they have no tag. Fields tagged with "-" are ignored, as are keys that do not
+//     match a field.
+//   - any node decodes into an empty interface as by Value
+//
+// Pointers are allocated as necessary. It is an error to decode a node into a value of an incompatible type or to
+// decode a number that overflows the value. Aliases, merge keys, and templates are handled as by Value.
+func Decode(node ast.Node, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot decode into %T: the value must be a non-nil pointer", v)
+	}
+	node, err := expandMerges(node)
+	if err != nil {
+		return err
+	}
+	if err := decode(node, rv.Elem()); err != nil {
+		return errors.Wrapf(err, "failed to decode")
+	}
+	return nil
+}
+
+// expandMerges applies the merge keys in a node. The node is modified in place.
+func expandMerges(node ast.Node) (ast.Node, error) {
+	if mv, ok := node.(*ast.MappingValueNode); ok {
+		node = ast.Mapping(mv.GetToken(), false, mv)
+	}
+	f := &ast.File{Docs: []*ast.DocumentNode{ast.Document(nil, node)}}
+	if err := parser.ExpandMerges(f); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode")
+	}
+	return node, nil
+}
+
+// resolve resolves anchors, aliases, tags, and mapping keys to the nodes they annotate.
+func resolve(node ast.Node) (ast.Node, error) {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.AliasNode:
+			if n.Anchor == nil {
+				return nil, errors.ErrSyntax(fmt.Sprintf("unresolved alias %s", n.String()), n.GetToken())
+			}
+			node = n.Anchor.Value
+		case *ast.TagNode:
+			node = n.Value
+		case *ast.MappingKeyNode:
+			node = n.Value
+		case *ast.ActionNode, *ast.IfNode, *ast.RangeNode, *ast.WithNode, *ast.TemplateInvokeNode, *ast.DefineNode,
+			*ast.TemplateListNode, *ast.InterpolatedStringNode, *ast.TemplateCommentNode, *ast.BreakNode,
+			*ast.ContinueNode:
+			return nil, errors.ErrSyntax("templates must be executed before decoding", n.GetToken())
+		default:
+			return node, nil
+		}
+	}
+}
+
+// entries returns the entries of a mapping node.
+func entries(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}, true
+	}
+	return nil, false
+}
+
+// entry checks that a mapping entry is not a template.
+func entry(mv *ast.MappingValueNode) error {
+	if mv.Template != nil {
+		return errors.ErrSyntax("templates must be executed before decoding", mv.Template.GetToken())
+	}
+	return nil
+}
+
+// text returns the text of a scalar node: the value of a string, or the source text of any other scalar.
+func text(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case nil:
+		return "", true
+	case *ast.StringNode:
+		return n.Value, true
+	case *ast.LiteralNode:
+		return n.Value.Value, true
+	case ast.ScalarNode:
+		return n.GetToken().Value, true
+	}
+	return "", false
+}
+
+func value(node ast.Node) (interface{}, error) {
+	node, err := resolve(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if values, ok := entries(node); ok {
+		m := make(map[string]interface{}, len(values))
+		for _, mv := range values {
+			if err := entry(mv); err != nil {
+				return nil, err
+			}
+			k, err := resolve(mv.Key)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := text(k)
+			if !ok {
+				return nil, errors.ErrSyntax(fmt.Sprintf("cannot decode %s key", k.Type()), k.GetToken())
+			}
+			if m[key], err = value(mv.Value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+
+	switch n := node.(type) {
+	case nil:
+		return nil, nil
+	case *ast.SequenceNode:
+		s := make([]interface{}, len(n.Values))
+		for i, v := range n.Values {
+			if s[i], err = value(v); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case *ast.LiteralNode:
+		return n.Value.Value, nil
+	case ast.ScalarNode:
+		return n.GetValue(), nil
+	}
+	return nil, errors.ErrSyntax(fmt.Sprintf("cannot decode %s", node.Type()), node.GetToken())
+}
+
+func mismatch(node ast.Node, t reflect.Type) error {
+	return errors.ErrSyntax(fmt.Sprintf("cannot decode %s into %s", node.Type(), t), node.GetToken())
+}
+
+func overflow(node ast.Node, t reflect.Type) error {
+	return errors.ErrSyntax(fmt.Sprintf("%s overflows %s", node.GetToken().Value, t), node.GetToken())
+}
+
+// integer returns the value of an integer node as a *big.Int.
+func integer(n *ast.IntegerNode) *big.Int {
+	switch v := n.Value.(type) {
+	case int64:
+		return big.NewInt(v)
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case *big.Int:
+		return v
+	}
+	return new(big.Int)
+}
+
+// float returns the value of a numeric node as a *big.Float. NaN is not representable.
+func float(node ast.Node) (*big.Float, bool) {
+	switch n := node.(type) {
+	case *ast.IntegerNode:
+		return new(big.Float).SetInt(integer(n)), true
+	case *ast.FloatNode:
+		if n.BigValue != nil {
+			return n.BigValue, true
+		}
+		return big.NewFloat(n.Value), true
+	case *ast.InfinityNode:
+		return big.NewFloat(n.Value), true
+	}
+	return nil, false
+}
+
+func decode(node ast.Node, v reflect.Value) error {
+	node, err := resolve(node)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := node.(*ast.NullNode); ok || node == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Type() {
+	case bigIntType:
+		n, ok := node.(*ast.IntegerNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		v.Set(reflect.ValueOf(new(big.Int).Set(integer(n))).Elem())
+		return nil
+	case bigFloatType:
+		f, ok := float(node)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		v.Set(reflect.ValueOf(new(big.Float).Copy(f)).Elem())
+		return nil
+	case timeType:
+		switch n := node.(type) {
+		case *ast.TimestampNode:
+			v.Set(reflect.ValueOf(n.Value))
+			return nil
+		case *ast.StringNode:
+			if t, ok := ast.ParseTimestamp(n.Value); ok {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+			return errors.ErrSyntax(fmt.Sprintf("invalid timestamp %q", n.Value), n.GetToken())
+		}
+		return mismatch(node, v.Type())
+	}
+
+	if n, ok := node.(*ast.BinaryNode); ok && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		b := reflect.MakeSlice(v.Type(), len(n.Value), len(n.Value))
+		reflect.Copy(b, reflect.ValueOf(n.Value))
+		v.Set(b)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decode(node, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return mismatch(node, v.Type())
+		}
+		x, err := value(node)
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Bool:
+		n, ok := node.(*ast.BoolNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		v.SetBool(n.Value)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := node.(*ast.IntegerNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		i := integer(n)
+		if !i.IsInt64() || v.OverflowInt(i.Int64()) {
+			return overflow(node, v.Type())
+		}
+		v.SetInt(i.Int64())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := node.(*ast.IntegerNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		i := integer(n)
+		if !i.IsUint64() || v.OverflowUint(i.Uint64()) {
+			return overflow(node, v.Type())
+		}
+		v.SetUint(i.Uint64())
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if _, ok := node.(*ast.NanNode); ok {
+			v.SetFloat(math.NaN())
+			return nil
+		}
+		f, ok := float(node)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		x, _ := f.Float64()
+		if !f.IsInf() && (math.IsInf(x, 0) || v.OverflowFloat(x)) {
+			return overflow(node, v.Type())
+		}
+		v.SetFloat(x)
+		return nil
+	case reflect.String:
+		s, ok := text(node)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Slice:
+		n, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		s := reflect.MakeSlice(v.Type(), len(n.Values), len(n.Values))
+		for i, e := range n.Values {
+			if err := decode(e, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		n, ok := node.(*ast.SequenceNode)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		if len(n.Values) != v.Len() {
+			return errors.ErrSyntax(fmt.Sprintf("cannot decode a sequence of length %d into %s", len(n.Values), v.Type()), n.GetToken())
+		}
+		for i, e := range n.Values {
+			if err := decode(e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		values, ok := entries(node)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(values))
+		for _, mv := range values {
+			if err := entry(mv); err != nil {
+				return err
+			}
+			key, elem := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			if err := decode(mv.Key, key); err != nil {
+				return err
+			}
+			if err := decode(mv.Value, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		values, ok := entries(node)
+		if !ok {
+			return mismatch(node, v.Type())
+		}
+		fields := structFields(v.Type())
+		for _, mv := range values {
+			if err := entry(mv); err != nil {
+				return err
+			}
+			k, err := resolve(mv.Key)
+			if err != nil {
+				return err
+			}
+			key, ok := text(k)
+			if !ok {
+				return errors.ErrSyntax(fmt.Sprintf("cannot decode %s key into a field of %s", k.Type(), v.Type()), k.GetToken())
+			}
+			if i, ok := fields[key]; ok {
+				if err := decode(mv.Value, v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return mismatch(node, v.Type())
+}
+
+// structFields returns the indices of the exported fields of a struct type by name.
+func structFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.ToLower(f.Name)
+		if tag, ok := f.Tag.Lookup("yaml"); ok {
+			if i := strings.IndexByte(tag, ','); i != -1 {
+				tag = tag[:i]
+			}
+			switch tag {
+			case "-":
+				continue
+			case "":
+			default:
+				name = tag
+			}
+		}
+		fields[name] = i
+	}
+	return fields
+}
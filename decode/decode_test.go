@@ -0,0 +1,204 @@
+package decode_test
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgavlin/yomlette/decode"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+type config struct {
+	Name     string
+	Replicas int               `yaml:"replicas"`
+	Ratio    float64           `yaml:"ratio,omitempty"`
+	Enabled  *bool             `yaml:"enabled"`
+	Ports    []uint16          `yaml:"ports"`
+	Labels   map[string]string `yaml:"labels"`
+	Extra    interface{}       `yaml:"extra"`
+	Ignored  string            `yaml:"-"`
+	Big      *big.Int          `yaml:"big"`
+	Float    *big.Float        `yaml:"float"`
+}
+
+func TestDecode(t *testing.T) {
+	source := `
+name: web
+replicas: 3
+ratio: 0.5
+enabled: true
+ports: [80, 0x1bb]
+labels: &labels {app: web, tier: 1}
+extra: {a: [1, null], b: *labels}
+ignored: x
+unknown: y
+big: 123_456_789_012_345_678_901_234_567_890
+float: 1.5e400
+`
+	f, err := parser.ParseBytes([]byte(source), parser.ParseBigNumbers)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var actual config
+	if err := decode.Decode(f.Docs[0].Body, &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	enabled := true
+	bigInt, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	expect := config{
+		Name:     "web",
+		Replicas: 3,
+		Ratio:    0.5,
+		Enabled:  &enabled,
+		Ports:    []uint16{80, 443},
+		Labels:   map[string]string{"app": "web", "tier": "1"},
+		Extra: map[string]interface{}{
+			"a": []interface{}{uint64(1), nil},
+			"b": map[string]interface{}{"app": "web", "tier": uint64(1)},
+		},
+		Big: bigInt,
+	}
+	if actual.Big == nil || actual.Big.Cmp(expect.Big) != 0 {
+		t.Fatalf("expected big %v but got %v", expect.Big, actual.Big)
+	}
+	if actual.Float == nil || actual.Float.Text('g', -1) != "1.5e+400" {
+		t.Fatalf("expected float 1.5e+400 but got %v", actual.Float)
+	}
+	actual.Float = nil
+	if !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("expected: %#v but got %#v", expect, actual)
+	}
+}
+
+func TestDecodeBigNumbers(t *testing.T) {
+	f, err := parser.ParseBytes([]byte("[0x1_0000_0000_0000_0000, 0o17, -0b101, 3]"), parser.ParseBigNumbers)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var ints []*big.Int
+	if err := decode.Decode(f.Docs[0].Body, &ints); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var actual []string
+	for _, i := range ints {
+		actual = append(actual, i.String())
+	}
+	if expect := []string{"18446744073709551616", "15", "-5", "3"}; !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("expected %v but got %v", expect, actual)
+	}
+
+	var floats []big.Float
+	if err := decode.Decode(f.Docs[0].Body, &floats); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if s := floats[0].Text('g', -1); s != "1.8446744073709551616e+19" {
+		t.Fatalf("unexpected float %s", s)
+	}
+}
+
+func TestDecodeMerges(t *testing.T) {
+	source := "base: &base {name: web, replicas: 1}\nderived:\n  <<: *base\n  replicas: 3\n"
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var actual map[string]config
+	if err := decode.Decode(f.Docs[0].Body, &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := map[string]config{
+		"base":    {Name: "web", Replicas: 1},
+		"derived": {Name: "web", Replicas: 3},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("expected: %#v but got %#v", expect, actual)
+	}
+
+	value, err := decode.Value(f.Docs[0].Body)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if derived := value.(map[string]interface{})["derived"]; !reflect.DeepEqual(derived, map[string]interface{}{"name": "web", "replicas": uint64(3)}) {
+		t.Fatalf("unexpected value %#v", derived)
+	}
+}
+
+func TestDecodeTimestampAndBinary(t *testing.T) {
+	type record struct {
+		Created time.Time  `yaml:"created"`
+		Updated *time.Time `yaml:"updated"`
+		Expires time.Time  `yaml:"expires"`
+		Data    []byte     `yaml:"data"`
+	}
+
+	source := "created: 2001-12-14 21:59:43.10 -5\nupdated: !!timestamp 2001-12-15T02:59:43.1Z\n" +
+		"expires: '2002-12-14'\ndata: !!binary |\n  aGVs\n  bG8=\n"
+	for _, mode := range []parser.Mode{parser.ParseCoreSchema, parser.ParseYAML11Schema} {
+		f, err := parser.ParseBytes([]byte(source), mode)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		var actual record
+		if err := decode.Decode(f.Docs[0].Body, &actual); err != nil {
+			t.Fatalf("%+v", err)
+		}
+
+		times := []string{
+			actual.Created.Format(time.RFC3339Nano),
+			actual.Updated.Format(time.RFC3339Nano),
+			actual.Expires.Format(time.RFC3339Nano),
+		}
+		expect := []string{"2001-12-14T21:59:43.1-05:00", "2001-12-15T02:59:43.1Z", "2002-12-14T00:00:00Z"}
+		if !reflect.DeepEqual(times, expect) {
+			t.Fatalf("expected %v but got %v", expect, times)
+		}
+		if string(actual.Data) != "hello" {
+			t.Fatalf("expected data hello but got %q", actual.Data)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		target interface{}
+		expect string
+	}{
+		{source: "a: x\n", target: &map[string]int{}, expect: "[1:4] cannot decode String into int"},
+		{source: "a: 300\n", target: &map[string]uint8{}, expect: "[1:4] 300 overflows uint8"},
+		{source: "a: 99999999999999999999\n", target: &map[string]int64{}, expect: "[1:4] 99999999999999999999 overflows int64"},
+		{source: "a: 1.0e400\n", target: &map[string]float64{}, expect: "[1:4] 1.0e400 overflows float64"},
+		{source: "a: 1.5\n", target: &map[string]*big.Int{}, expect: "[1:4] cannot decode Float into big.Int"},
+		{source: "a: 2001-13-14\n", target: &map[string]time.Time{}, expect: `[1:4] invalid timestamp "2001-13-14"`},
+		{source: "a: !!binary aGVsbG8=\n", target: &map[string]int{}, expect: "[1:12] cannot decode Binary into int"},
+		{source: "[1, 2]\n", target: &[3]int{}, expect: "[1:1] cannot decode a sequence of length 2 into [3]int"},
+		{source: "a: *b\n", target: new(interface{}), expect: "[1:4] unresolved alias *b"},
+		{source: "a:\n  <<: 1\n", target: new(interface{}), expect: "[2:6] cannot merge Integer into a mapping"},
+		{source: "a: {{ .b }}\n", target: new(interface{}), expect: "[1:4] templates must be executed before decoding"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), parser.ParseBigNumbers)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			err = decode.Decode(f.Docs[0].Body, test.target)
+			if err == nil || !strings.Contains(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,745 @@
+// Package executor evaluates the templates in a YAML AST and renders the resulting YAML.
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// maxExecDepth specifies the maximum stack depth of templates within templates. This limit is only practically reached
+// by accidentally recursive template invocations.
+const maxExecDepth = 100000
+
+// MissingKeyAction controls the behavior of the executor when a map is indexed with a key that is not present in the
+// map.
+type MissingKeyAction int
+
+const (
+	// MissingKeyInvalid produces an invalid value for missing keys. Invalid values render as null.
+	MissingKeyInvalid MissingKeyAction = iota
+	// MissingKeyZero produces the zero value of the map's element type for missing keys.
+	MissingKeyZero
+	// MissingKeyError stops execution with an error.
+	MissingKeyError
+)
+
+// Executor evaluates the templates in a parsed YAML file. The zero value is ready to use.
+type Executor struct {
+	// Funcs holds the functions that may be called by templates in addition to the builtin functions. Each function
+	// must have either a single return value, or two return values of which the second has type error.
+	Funcs map[string]interface{}
+	// MissingKey controls the behavior of the executor when a map is indexed with a key that is not present.
+	MissingKey MissingKeyAction
+	// AllowedFuncs, if non-nil, lists the functions in Funcs that templates may call. Builtin functions may always be
+	// called.
+	AllowedFuncs []string
+	// Limits bounds the resources that an execution may consume.
+	Limits Limits
+	// Trace enables execution tracing. If set, the result of an execution holds a trace of the evaluated actions,
+	// branches, range iterations, variable bindings, and template invocations.
+	Trace bool
+}
+
+// Result holds the output of an execution.
+type Result struct {
+	// File holds the rendered documents. The File contains no template nodes.
+	File *ast.File
+	// Trace holds the execution trace, if tracing was enabled.
+	Trace *Trace
+
+	origins  map[ast.Node]*Origin
+	maxBytes int
+}
+
+// Origin returns the origin of the given node in the rendered file, or nil if the node was not produced by the
+// execution that produced the result.
+func (r *Result) Origin(node ast.Node) *Origin {
+	return r.origins[node]
+}
+
+// Render writes the rendered documents to w as YAML and returns a source map that maps each node in the output to
+// its origin in the template. Nothing is written if the output would exceed the executor's MaxOutputBytes limit.
+func (r *Result) Render(w io.Writer) (*SourceMap, error) {
+	rd := &renderer{result: r, line: 1, column: 1, sourceMap: &SourceMap{File: r.File.Name}, maxBytes: r.maxBytes}
+	rd.file(r.File)
+	if rd.exceeded {
+		return nil, fmt.Errorf("exceeded maximum output size (%v bytes)", r.maxBytes)
+	}
+	if _, err := w.Write(rd.buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return rd.sourceMap, nil
+}
+
+// Execute evaluates the templates in f with data as the initial value of dot and returns the rendered file. The
+// input file is not modified.
+func (e *Executor) Execute(f *ast.File, data interface{}) (*Result, error) {
+	return e.ExecuteContext(context.Background(), f, data)
+}
+
+// ExecuteContext is like Execute, but stops execution with an error if ctx is canceled or expires.
+func (e *Executor) ExecuteContext(ctx context.Context, f *ast.File, data interface{}) (result *Result, err error) {
+	funcs, err := e.funcs()
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := data.(reflect.Value)
+	if !ok {
+		value = reflect.ValueOf(data)
+	}
+	s := &state{
+		ctx:     ctx,
+		e:       e,
+		funcs:   funcs,
+		tmpl:    map[string]*ast.DefineNode{},
+		vars:    []variable{{"$", value}},
+		origins: map[ast.Node]*Origin{},
+		usage:   &usage{},
+	}
+	if e.Trace {
+		s.tracer = &Trace{}
+	}
+
+	defer func() {
+		if err != nil {
+			result, err = nil, errors.Wrapf(err, "failed to execute template")
+		}
+	}()
+	defer s.recover(&err)
+
+	for _, doc := range f.Docs {
+		ast.Walk(s, doc)
+	}
+
+	out := &ast.File{Name: f.Name}
+	for _, doc := range f.Docs {
+		for _, n := range s.walk(value, doc) {
+			out.Docs = append(out.Docs, n.(*ast.DocumentNode))
+		}
+	}
+	if len(out.Docs) == 0 && len(f.Docs) != 0 {
+		// The templates produced no documents at all. Produce a single empty document.
+		out.Docs = append(out.Docs, ast.Document(nil, s.combine(f.Docs[0].GetToken(), nil)))
+	}
+	return &Result{File: out, Trace: s.tracer, origins: s.origins, maxBytes: e.Limits.MaxOutputBytes}, nil
+}
+
+// Render evaluates the templates in f with data as the initial value of dot, writes the rendered documents to w, and
+// returns a source map that maps each node in the output to its origin in the template.
+func (e *Executor) Render(w io.Writer, f *ast.File, data interface{}) (*SourceMap, error) {
+	result, err := e.Execute(f, data)
+	if err != nil {
+		return nil, err
+	}
+	return result.Render(w)
+}
+
+func (e *Executor) funcs() (map[string]reflect.Value, error) {
+	funcs := make(map[string]reflect.Value, len(e.Funcs))
+	for name, fn := range e.Funcs {
+		if !goodName(name) {
+			return nil, fmt.Errorf("function name %q is not a valid identifier", name)
+		}
+		v := reflect.ValueOf(fn)
+		if v.Kind() != reflect.Func {
+			return nil, fmt.Errorf("value for %s is not a function", name)
+		}
+		if err := goodFunc(name, v.Type()); err != nil {
+			return nil, err
+		}
+		funcs[name] = v
+	}
+	return funcs, nil
+}
+
+// state represents the state of an execution.
+type state struct {
+	ctx   context.Context
+	e     *Executor
+	funcs map[string]reflect.Value
+	tmpl  map[string]*ast.DefineNode
+
+	tk     *token.Token     // current action, for errors
+	node   ast.TemplateNode // current template node, for errors
+	name   string           // name of the executing template, for errors
+	vars   []variable       // push-down stack of variable values.
+	frames []Frame          // active template invocations and range iterations.
+	depth  int              // the height of the stack of executing templates.
+	jump   ast.NodeType     // BreakType or ContinueType while a {{break}} or {{continue}} is pending.
+
+	origins map[ast.Node]*Origin
+	tracer  *Trace
+	usage   *usage
+}
+
+// variable holds the dynamic value of a variable such as $, $x etc.
+type variable struct {
+	name  string
+	value reflect.Value
+}
+
+// execError is the panic value used to terminate execution.
+type execError struct {
+	err error
+}
+
+// Visit collects the template definitions in the file.
+func (s *state) Visit(node ast.Node) ast.Visitor {
+	if d, ok := node.(*ast.DefineNode); ok {
+		if prev, ok := s.tmpl[d.Name]; ok {
+			pos := prev.Token.Position
+			s.at(d.Token, nil)
+			s.errorf("template %q is already defined at %d:%d", d.Name, pos.Line, pos.Column)
+		}
+		s.tmpl[d.Name] = d
+	}
+	return s
+}
+
+// push pushes a new variable on the stack.
+func (s *state) push(name string, value reflect.Value) {
+	s.vars = append(s.vars, variable{name, value})
+}
+
+// mark returns the length of the variable stack.
+func (s *state) mark() int {
+	return len(s.vars)
+}
+
+// pop pops the variable stack up to the mark.
+func (s *state) pop(mark int) {
+	s.vars = s.vars[0:mark]
+}
+
+// setVar overwrites the last declared variable with the given name.
+// Used by variable assignments.
+func (s *state) setVar(name string, value reflect.Value) {
+	for i := s.mark() - 1; i >= 0; i-- {
+		if s.vars[i].name == name {
+			s.vars[i].value = value
+			return
+		}
+	}
+	s.errorf("undefined variable: %s", name)
+}
+
+// setTopVar overwrites the top-nth variable on the stack. Used by range iterations.
+func (s *state) setTopVar(n int, value reflect.Value) {
+	s.vars[len(s.vars)-n].value = value
+}
+
+// varValue returns the value of the named variable.
+func (s *state) varValue(name string) reflect.Value {
+	for i := s.mark() - 1; i >= 0; i-- {
+		if s.vars[i].name == name {
+			return s.vars[i].value
+		}
+	}
+	s.errorf("undefined variable: %s", name)
+	return zero
+}
+
+// pushFrame pushes a template invocation or range iteration. The frame stack is never modified in place, so origins
+// may share it.
+func (s *state) pushFrame(f Frame) {
+	s.frames = append(s.frames[:len(s.frames):len(s.frames)], f)
+}
+
+// at marks the state to be on the given action and template node, for error reporting.
+func (s *state) at(tk *token.Token, node ast.TemplateNode) {
+	s.tk, s.node = tk, node
+}
+
+// errorf records an error at the current action and terminates processing.
+func (s *state) errorf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if s.node != nil {
+		msg = fmt.Sprintf("at <%s>: %s", s.node, msg)
+	}
+	if s.name != "" {
+		sep := ":"
+		if s.node != nil {
+			sep = ""
+		}
+		msg = fmt.Sprintf("executing %q%s %s", s.name, sep, msg)
+	}
+	panic(execError{err: errors.ErrSyntax(msg, s.tk)})
+}
+
+// recover is the handler that turns panics into returns from the top level of Execute.
+func (s *state) recover(errp *error) {
+	e := recover()
+	if e != nil {
+		switch err := e.(type) {
+		case runtime.Error:
+			panic(e)
+		case execError:
+			*errp = err.err
+		default:
+			panic(e)
+		}
+	}
+}
+
+// origin records the origin of an output node.
+func (s *state) origin(node ast.Node, tk *token.Token) ast.Node {
+	s.countNode(tk)
+	switch n := node.(type) {
+	case *ast.LiteralNode:
+		s.countBytes(tk, len(n.Value.Value))
+	case ast.ScalarNode:
+		s.countBytes(tk, len(n.GetToken().Value))
+	}
+	s.origins[node] = &Origin{Token: tk, Frames: s.frames}
+	return node
+}
+
+// walk evaluates the given template node and returns the nodes it produces. YAML nodes produce exactly one node;
+// template nodes may produce any number of nodes.
+func (s *state) walk(dot reflect.Value, node ast.Node) []ast.Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *ast.ActionNode:
+		// Do not pop variables so they persist until next end.
+		// Also, if the action declares variables, don't produce the result.
+		s.checkContext(n.Token)
+		s.at(n.Token, nil)
+		val := s.evalPipeline(dot, n.Pipe)
+		if len(n.Pipe.Decl) != 0 {
+			s.traceDecls(n.Token, n.Pipe, val)
+			return nil
+		}
+		s.trace(TraceAction, n.Token, TraceEvent{}, val)
+		return []ast.Node{s.valueNode(n.Token, val)}
+	case *ast.IfNode:
+		return s.walkIfOrWith(dot, &n.BranchNode)
+	case *ast.WithNode:
+		return s.walkIfOrWith(dot, &n.BranchNode)
+	case *ast.RangeNode:
+		return s.walkRange(dot, n)
+	case *ast.TemplateInvokeNode:
+		return s.walkTemplate(dot, n)
+	case *ast.DefineNode, *ast.CommentNode, *ast.TemplateCommentNode:
+		return nil
+	case *ast.BreakNode:
+		s.jump = ast.BreakType
+		return nil
+	case *ast.ContinueNode:
+		s.jump = ast.ContinueType
+		return nil
+	case *ast.DocumentNode:
+		return s.walkDocument(dot, n)
+	case *ast.TemplateListNode:
+		return s.walkList(dot, n.List)
+	case *ast.InterpolatedStringNode:
+		return []ast.Node{s.walkInterpolatedString(dot, n)}
+	case *ast.MappingNode:
+		return []ast.Node{s.walkMapping(dot, n)}
+	case *ast.MappingValueNode:
+		if n.Template != nil {
+			return s.walk(dot, n.Template)
+		}
+		mv := ast.MappingValue(n.Start, s.value(dot, n.Key), s.value(dot, n.Value))
+		return []ast.Node{s.origin(mv, n.Start)}
+	case *ast.MappingKeyNode:
+		key := ast.MappingKey(n.Start)
+		key.Value = s.value(dot, n.Value)
+		return []ast.Node{s.origin(key, n.Start)}
+	case *ast.SequenceNode:
+		seq := ast.Sequence(n.Start, n.IsFlowStyle)
+		seq.End = n.End
+		for _, v := range n.Values {
+			if n.IsFlowStyle {
+				seq.Values = append(seq.Values, s.flowEntries(dot, v)...)
+			} else {
+				seq.Values = append(seq.Values, s.value(dot, v))
+			}
+			if s.jumping() {
+				break
+			}
+		}
+		return []ast.Node{s.origin(seq, n.Start)}
+	case *ast.AnchorNode:
+		anchor := ast.Anchor(n.Start)
+		anchor.Name, anchor.Value = n.Name, s.value(dot, n.Value)
+		return []ast.Node{s.origin(anchor, n.Start)}
+	case *ast.AliasNode:
+		alias := ast.Alias(n.Start)
+		alias.Value = n.Value
+		return []ast.Node{s.origin(alias, n.Start)}
+	case *ast.TagNode:
+		tag := ast.Tag(n.Start)
+		tag.Value, tag.URI = s.value(dot, n.Value), n.URI
+		return []ast.Node{s.origin(tag, n.Start)}
+	default:
+		return []ast.Node{s.origin(copyScalar(node), node.GetToken())}
+	}
+}
+
+// walkDocument evaluates a document. Templates at the top level of the document may produce further documents, e.g.
+// a {{range}} whose body starts with "---". Each produced document starts a new output document; the nodes that
+// follow it belong to its body. An implicit document (one without a "---") whose templates produce nothing of its own
+// is dropped.
+func (s *state) walkDocument(dot reflect.Value, n *ast.DocumentNode) []ast.Node {
+	doc := ast.Document(n.Start, nil)
+	doc.End = n.End
+	if n.Body == nil {
+		return []ast.Node{doc}
+	}
+
+	docs, bodies := []*ast.DocumentNode{doc}, [][]ast.Node{nil}
+	for _, node := range s.walk(dot, n.Body) {
+		if d, ok := node.(*ast.DocumentNode); ok {
+			var body []ast.Node
+			if d.Body != nil {
+				body = []ast.Node{d.Body}
+			}
+			docs, bodies = append(docs, d), append(bodies, body)
+			continue
+		}
+		bodies[len(bodies)-1] = append(bodies[len(bodies)-1], node)
+	}
+
+	var result []ast.Node
+	for i, d := range docs {
+		switch {
+		case i == 0:
+			if n.Start == nil && len(bodies[0]) == 0 {
+				continue
+			}
+			d.Body = s.combine(n.Body.GetToken(), bodies[0])
+		case len(bodies[i]) != 0:
+			d.Body = s.combine(bodies[i][0].GetToken(), bodies[i])
+		}
+		result = append(result, d)
+	}
+	if len(docs) > 1 && n.End != nil {
+		doc.End, docs[len(docs)-1].End = nil, n.End
+	}
+	return result
+}
+
+// walkInterpolatedString evaluates an interpolated string. The result is a string in the style of the template: the
+// literal text of the string and the text of the scalars produced by its actions, in order. Null values produce no
+// text. Block scalars keep their header, so the text of the result is folded and chomped as the template's would be.
+func (s *state) walkInterpolatedString(dot reflect.Value, n *ast.InterpolatedStringNode) ast.Node {
+	var sb strings.Builder
+	for _, node := range s.walkList(dot, n.Parts) {
+		switch node := node.(type) {
+		case *ast.NullNode:
+		case ast.ScalarNode:
+			sb.WriteString(Text(node))
+		default:
+			s.at(node.GetToken(), nil)
+			s.errorf("cannot interpolate %s into a string", node.Type())
+		}
+	}
+	text := sb.String()
+
+	switch n.Style {
+	case token.LiteralType, token.FoldedType:
+		literal := ast.Literal(newToken(n.Token, n.Style, n.Token.Value))
+		literal.Value = ast.String(newToken(n.Token, token.StringType, token.BlockScalarValue(n.Token.Value, text)))
+		literal.Value.Token.Origin = text
+		return s.origin(literal, n.Token)
+	default:
+		return s.origin(ast.String(newToken(n.Token, n.Style, text)), n.Token)
+	}
+}
+
+// walkList evaluates each node in the list and returns the concatenation of the results. A pending {{break}} or
+// {{continue}} ends the list.
+func (s *state) walkList(dot reflect.Value, list *ast.NodeList) []ast.Node {
+	var nodes []ast.Node
+	for _, n := range list.Nodes {
+		nodes = append(nodes, s.walk(dot, n)...)
+		if s.jumping() {
+			break
+		}
+	}
+	return nodes
+}
+
+// jumping returns true if a {{break}} or {{continue}} is pending. The nodes that follow the action up to the end of the
+// enclosing {{range}}'s iteration are skipped, including the remaining entries of any collections that hold it.
+func (s *state) jumping() bool {
+	return s.jump != ast.UnknownNodeType
+}
+
+// value evaluates a node in value position. The result of the evaluation must be a single value.
+func (s *state) value(dot reflect.Value, node ast.Node) ast.Node {
+	if node == nil {
+		return nil
+	}
+	return s.combine(node.GetToken(), s.walk(dot, node))
+}
+
+// combine combines the nodes produced by a template in value position into a single value. No nodes produce null.
+// Mapping entries and mappings are merged into a single mapping, and sequences are concatenated.
+func (s *state) combine(tk *token.Token, nodes []ast.Node) ast.Node {
+	s.checkDocuments(nodes)
+	switch len(nodes) {
+	case 0:
+		return s.origin(ast.Null(newToken(tk, token.NullType, "null")), tk)
+	case 1:
+		if _, ok := nodes[0].(*ast.MappingValueNode); !ok {
+			return nodes[0]
+		}
+	}
+
+	switch nodes[0].(type) {
+	case *ast.MappingNode, *ast.MappingValueNode:
+		m := ast.Mapping(tk, false)
+		s.appendEntries(m, tk, nodes)
+		return s.origin(m, tk)
+	case *ast.SequenceNode:
+		seq := ast.Sequence(tk, false)
+		for _, n := range nodes {
+			elems, ok := n.(*ast.SequenceNode)
+			if !ok {
+				s.at(tk, nil)
+				s.errorf("cannot combine %s with a sequence", n.Type())
+			}
+			seq.Values = append(seq.Values, elems.Values...)
+		}
+		return s.origin(seq, tk)
+	}
+	s.at(tk, nil)
+	s.errorf("expected a single value, but the template produced %d values", len(nodes))
+	return nil
+}
+
+// flowEntries evaluates an entry of a flow sequence. A control action in a flow sequence produces an entry for each
+// node it produces, e.g. `[a, {{ range .xs }}{{ . }}{{ end }}]`; any other node produces a single entry.
+func (s *state) flowEntries(dot reflect.Value, node ast.Node) []ast.Node {
+	switch node.(type) {
+	case *ast.IfNode, *ast.RangeNode, *ast.WithNode, *ast.TemplateInvokeNode:
+		var entries []ast.Node
+		for _, n := range s.walk(dot, node) {
+			entries = append(entries, s.combine(n.GetToken(), []ast.Node{n}))
+		}
+		return entries
+	}
+	return []ast.Node{s.value(dot, node)}
+}
+
+// checkDocuments reports an error if a template outside the top level of a document produced a document.
+func (s *state) checkDocuments(nodes []ast.Node) {
+	for _, n := range nodes {
+		if d, ok := n.(*ast.DocumentNode); ok {
+			s.at(d.GetToken(), nil)
+			s.errorf("documents may only be produced at the top level of a document")
+		}
+	}
+}
+
+// appendEntries appends the mapping entries produced by a template to the given mapping.
+func (s *state) appendEntries(m *ast.MappingNode, tk *token.Token, nodes []ast.Node) {
+	s.checkDocuments(nodes)
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *ast.MappingValueNode:
+			m.Values = append(m.Values, n)
+		case *ast.MappingNode:
+			m.Values = append(m.Values, n.Values...)
+		case *ast.NullNode:
+			// Null values contribute no entries.
+		default:
+			s.at(tk, nil)
+			s.errorf("cannot use %s as a mapping entry", n.Type())
+		}
+	}
+}
+
+func (s *state) walkMapping(dot reflect.Value, n *ast.MappingNode) ast.Node {
+	m := ast.Mapping(n.Start, n.IsFlowStyle)
+	m.End = n.End
+	for _, v := range n.Values {
+		s.appendEntries(m, v.GetToken(), s.walk(dot, v))
+		if s.jumping() {
+			break
+		}
+	}
+	return s.origin(m, n.Start)
+}
+
+// walkIfOrWith walks an 'if' or 'with' node. The two control structures
+// are identical in behavior except that 'with' sets dot.
+func (s *state) walkIfOrWith(dot reflect.Value, n *ast.BranchNode) []ast.Node {
+	defer s.pop(s.mark())
+	s.at(n.Token, nil)
+	val := s.evalPipeline(dot, n.Pipe)
+	s.traceDecls(n.Token, n.Pipe, val)
+	truth, ok := isTrue(indirectInterface(val))
+	if !ok {
+		s.at(n.Token, nil)
+		s.errorf("if/with can't use %v", val)
+	}
+	if truth {
+		s.trace(TraceBranch, n.Token, TraceEvent{Branch: "then"}, val)
+		if n.Type() == ast.WithType {
+			return s.walkList(val, n.List)
+		}
+		return s.walkList(dot, n.List)
+	} else if n.ElseList != nil {
+		s.trace(TraceBranch, n.Token, TraceEvent{Branch: "else"}, val)
+		return s.walkList(dot, n.ElseList)
+	}
+	s.trace(TraceBranch, n.Token, TraceEvent{Branch: "none"}, val)
+	return nil
+}
+
+func (s *state) walkRange(dot reflect.Value, r *ast.RangeNode) []ast.Node {
+	s.at(r.Token, nil)
+	defer s.pop(s.mark())
+	val, _ := indirect(s.evalPipeline(dot, r.Pipe))
+	// mark top of stack before any variables in the body are pushed.
+	mark := s.mark()
+	frames := s.frames
+
+	var nodes []ast.Node
+	// oneIteration returns false if the iteration ended with a {{break}}.
+	oneIteration := func(iteration int, index, elem reflect.Value) bool {
+		s.checkContext(r.Token)
+		s.countIteration(r.Token)
+		s.trace(TraceRange, r.Token, TraceEvent{Index: iteration}, elem)
+		// Set top var (lexically the second if there are two) to the element.
+		if len(r.Pipe.Decl) > 0 {
+			s.setTopVar(1, elem)
+			s.trace(TraceVariable, r.Token, TraceEvent{Name: r.Pipe.Decl[len(r.Pipe.Decl)-1].Ident[0]}, elem)
+		}
+		// Set next var (lexically the first if there are two) to the index.
+		if len(r.Pipe.Decl) > 1 {
+			s.setTopVar(2, index)
+			s.trace(TraceVariable, r.Token, TraceEvent{Name: r.Pipe.Decl[0].Ident[0]}, index)
+		}
+		s.pushFrame(Frame{Kind: RangeFrame, Token: r.Token, Index: iteration})
+		nodes = append(nodes, s.walkList(elem, r.List)...)
+		s.frames = frames
+		s.pop(mark)
+		jump := s.jump
+		s.jump = ast.UnknownNodeType
+		return jump != ast.BreakType
+	}
+	switch val.Kind() {
+	case reflect.Array, reflect.Slice:
+		if val.Len() == 0 {
+			break
+		}
+		for i := 0; i < val.Len(); i++ {
+			if !oneIteration(i, reflect.ValueOf(i), val.Index(i)) {
+				break
+			}
+		}
+		return nodes
+	case reflect.Map:
+		if val.Len() == 0 {
+			break
+		}
+		for i, key := range sortKeys(val.MapKeys()) {
+			if !oneIteration(i, key, val.MapIndex(key)) {
+				break
+			}
+		}
+		return nodes
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// Ranging over an integer n iterates over the values 0 to n-1, which have the integer's type.
+		if len(r.Pipe.Decl) > 1 {
+			s.at(r.Token, nil)
+			s.errorf("can't use %v to iterate over more than one variable", val)
+		}
+		count := rangeCount(val)
+		if count == 0 {
+			break
+		}
+		for i := uint64(0); i < count; i++ {
+			elem := reflect.ValueOf(i).Convert(val.Type())
+			if !oneIteration(int(i), elem, elem) {
+				break
+			}
+		}
+		return nodes
+	case reflect.Chan:
+		if val.IsNil() {
+			break
+		}
+		i := 0
+		for ; ; i++ {
+			elem, ok := val.Recv()
+			if !ok {
+				break
+			}
+			if !oneIteration(i, reflect.ValueOf(i), elem) {
+				i++
+				break
+			}
+		}
+		if i == 0 {
+			break
+		}
+		return nodes
+	case reflect.Invalid:
+		break // An invalid value is likely a nil map, etc. and acts like an empty map.
+	default:
+		s.at(r.Token, nil)
+		s.errorf("range can't iterate over %v", val)
+	}
+	if r.ElseList != nil {
+		nodes = s.walkList(dot, r.ElseList)
+		s.jump = ast.UnknownNodeType
+		return nodes
+	}
+	return nil
+}
+
+// rangeCount returns the number of iterations of a range over an integer. Negative integers produce no iterations.
+func rangeCount(val reflect.Value) uint64 {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := val.Int(); n > 0 {
+			return uint64(n)
+		}
+		return 0
+	default:
+		return val.Uint()
+	}
+}
+
+func (s *state) walkTemplate(dot reflect.Value, t *ast.TemplateInvokeNode) []ast.Node {
+	s.at(t.Token, nil)
+	tmpl, ok := s.tmpl[t.Name]
+	if !ok {
+		s.errorf("template %q not defined", t.Name)
+	}
+	s.checkContext(t.Token)
+	// Variables declared by the pipeline persist.
+	dot = s.evalPipeline(dot, t.Pipe)
+	return s.invoke(t.Token, t.Name, dot).walkList(dot, tmpl.List)
+}
+
+// invoke returns the state of an invocation of the named template by the action tk.
+func (s *state) invoke(tk *token.Token, name string, dot reflect.Value) *state {
+	if max := s.e.Limits.maxDepth(); s.depth >= max {
+		s.at(tk, nil)
+		s.errorf("exceeded maximum template depth (%v)", max)
+	}
+	newState := *s
+	newState.depth++
+	newState.name = name
+	// No dynamic scoping: template invocations inherit no variables.
+	newState.vars = []variable{{"$", dot}}
+	newState.pushFrame(Frame{Kind: TemplateFrame, Token: tk, Name: name})
+	newState.trace(TraceTemplate, tk, TraceEvent{Name: name}, dot)
+	return &newState
+}
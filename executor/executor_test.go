@@ -0,0 +1,580 @@
+package executor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/lexer"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/printer"
+)
+
+var bigInt, _ = new(big.Int).SetString("99999999999999999999", 10)
+
+var data = map[string]interface{}{
+	"name":     "web",
+	"ports":    []int{80, 443},
+	"env":      map[string]string{"b": "2", "a": "1"},
+	"debug":    false,
+	"big":      bigInt,
+	"bigFloat": new(big.Float).SetInt(bigInt),
+	"time":     time.Date(2001, 12, 15, 2, 59, 43, 100000000, time.UTC),
+	"bytes":    []byte("hello"),
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "name: {{ .name }}\nreplicas: 3\n",
+			expect: "name: web\nreplicas: 3\n",
+		},
+		{
+			source: "ports: {{ .ports }}\nenv: {{ .env }}\n",
+			expect: "ports:\n  - 80\n  - 443\nenv:\n  a: '1'\n  b: '2'\n",
+		},
+		{
+			source: "ports:\n  {{ range .ports }}\n  - port: {{ . }}\n  {{ end }}\n",
+			expect: "ports:\n  - port: 80\n  - port: 443\n",
+		},
+		{
+			source: "debug: {{ if .debug }}yes{{ else }}no{{ end }}\n",
+			expect: "debug: no\n",
+		},
+		{
+			source: "env:\n  {{- range $key, $value := .env }}\n  {{ $key }}: {{ $value }}\n  {{- end }}\nports:\n  {{ range $i, $p := .ports }}\n  - {{ $p }}\n  {{ end }}\n",
+			expect: "env:\n  a: '1'\n  b: '2'\nports:\n  - 80\n  - 443\n",
+		},
+		{
+			source: "{{ define \"labels\" }}\napp: {{ .name }}\n{{ end }}\nmetadata:\n  labels:\n    {{ template \"labels\" . }}\n",
+			expect: "metadata:\n  labels:\n    app: web\n",
+		},
+		{
+			source: "script: |\n  echo hi\n    there\nquoted: 'x'\nflow: {a: [1, 2]}\n",
+			expect: "script: |\n  echo hi\n    there\nquoted: 'x'\nflow: {a: [1, 2]}\n",
+		},
+		{
+			source: "big: {{ .big }}\nfloat: {{ .bigFloat }}\n",
+			expect: "big: 99999999999999999999\nfloat: 9.9999999999999999999e+19\n",
+		},
+		{
+			source: "time: {{ .time }}\nbytes: {{ .bytes }}\nbinary: !!binary |\n  aGVs\n  bG8=\n",
+			expect: "time: 2001-12-15T02:59:43.1Z\nbytes: !!binary aGVsbG8=\nbinary: !!binary |\n  aGVs\n  bG8=\n",
+		},
+		{
+			source: "a: &a\n  - {{ .name }}\nb: *a\n",
+			expect: "a: &a\n  - web\nb: *a\n",
+		},
+		{
+			source: "{{ range .ports }}\n---\nport: {{ . }}\n{{ end }}\n",
+			expect: "---\nport: 80\n---\nport: 443\n",
+		},
+		{
+			source: "{{ range .ports }}\n---\nport: {{ . }}\n{{ end }}\n{{ if not .debug }}\n---\nrelease: true\n{{ end }}\n",
+			expect: "---\nport: 80\n---\nport: 443\n---\nrelease: true\n",
+		},
+		{
+			source: "name: {{ .name }}\n{{ if not .debug }}\n---\nkind: extra\n{{ end }}\n",
+			expect: "name: web\n---\nkind: extra\n",
+		},
+		{
+			source: "name: {{ .name }}\n{{ if .debug }}\n---\nkind: extra\n{{ end }}\n",
+			expect: "name: web\n",
+		},
+		{
+			source: "- first\n{{ range .ports }}\n- {{ . }}\n{{ end }}\n- last\n",
+			expect: "- first\n- 80\n- 443\n- last\n",
+		},
+		{
+			source: "list:\n  {{ range .missing }}\n  - {{ . }}\n  {{ else }}\n  - none\n  {{ end }}\n  - z\n",
+			expect: "list:\n  - none\n  - z\n",
+		},
+		{
+			source: "name: app-{{ .name }}\nimage: \"{{ .name }}:{{ index .ports 0 }}\"\nnote: 'it''s {{ .name }}'\n",
+			expect: "name: app-web\nimage: \"web:80\"\nnote: 'it''s web'\n",
+		},
+		{
+			source: `a: "x\n\t{{ .name }}\t\"\\"` + "\n" + `b: "\x7b\x7b .name }}"` + "\n" + `c: "{{ printf "%s\"" .name }}\u00e9"` + "\n",
+			expect: `a: "x\n\tweb\t\"\\"` + "\n" + `b: "{{ .name }}"` + "\n" + `c: "web\"é"` + "\n",
+		},
+		{
+			source: `image: "{{ or .repo "nginx" }}:{{ printf "%d" (index .ports 0) }}"` + "\n" +
+				`name: "{{ printf "}}{{%s" .name }}"` + "\n",
+			expect: `image: "nginx:80"` + "\n" + `name: "}}{{web"` + "\n",
+		},
+		{
+			source: "debug: pre-{{ if .debug }}yes{{ else }}no{{ end }}-post\nport: {{ index .ports 0 }}{{ index .ports 1 }}\n",
+			expect: "debug: pre-no-post\nport: '80443'\n",
+		},
+		{
+			source: "trim: a {{- .name -}} b\nsep: a {{ .name }} b\n",
+			expect: "trim: awebb\nsep: a web b\n",
+		},
+		{
+			source: "- v{{ index .ports 0 }}\n- {{ .name }}/{{ .name }}\n",
+			expect: "- v80\n- web/web\n",
+		},
+		{
+			source: "script: |\n  echo {{ .name }}\n  done\n",
+			expect: "script: |\n  echo web\n  done\n",
+		},
+		{
+			source: "a: |-\n  x {{ .name }}\n    y\nb: >\n  x {{ .name }}\n  y\n\n  z\nc: |+\n  {{ .name }}\n\nd: 1\n",
+			expect: "a: |-\n  x web\n    y\nb: >\n  x web\n  y\n\n  z\nc: |+\n  web\n\nd: 1\n",
+		},
+		{
+			source: "config.ini: |\n  [server]\n  {{ range $i, $p := .ports }}\n  port{{ $i }} = {{ $p }}\n  {{ end }}\n  {{ if .debug }}\n  debug = true\n  {{ end }}\n  name = {{ .name }}\n",
+			expect: "config.ini: |\n  [server]\n  port0 = 80\n  port1 = 443\n  name = web\n",
+		},
+		{
+			source: "{{ .name }}: 1\napp-{{ .name }}: 2\n{{ if .debug }}x{{ else }}y{{ end }}: 3\n",
+			expect: "web: 1\napp-web: 2\ny: 3\n",
+		},
+		{
+			source: "args: [--port, {{ index .ports 0 }}]\nall: [a, {{ range .ports }}{{ . }}{{ end }}, z]\n",
+			expect: "args: [--port, 80]\nall: [a, 80, 443, z]\n",
+		},
+		{
+			source: "m: {a: {{ .name }}}\nn: { {{ .name }}: v, x: y-{{ .name }}}\n",
+			expect: "m: {a: web}\nn: {web: v, x: y-web}\n",
+		},
+		{
+			source: "{{/* The application. */}}\nname: {{ .name }}\n{{- /* Listening ports. */}}\nports:\n  {{/* first */}}\n  - {{ index .ports 0 }}\n  - x{{/* inline */}}y\nscript: |\n  {{/* not rendered */}}\n  echo\n",
+			expect: "name: web\nports:\n  - 80\n  - xy\nscript: |\n  echo\n",
+		},
+		{
+			source: "first:\n  {{ range .ports }}\n  - {{ . }}\n  {{ break }}\n  {{ end }}\nrest:\n  {{ range .ports }}\n  {{ if eq . 80 }}{{ continue }}{{ end }}\n  - {{ . }}\n  {{ end }}\n",
+			expect: "first:\n  - 80\nrest:\n  - 443\n",
+		},
+		{
+			source: "{{ range .ports }}\n---\nport: {{ . }}\n{{ if eq . 80 }}{{ break }}{{ end }}\nname: {{ $.name }}\n{{ end }}\n",
+			expect: "---\nport: 80\n",
+		},
+		{
+			source: "n: [{{ range 3 }}{{ . }}{{ end }}]\ns: \"{{ range 5 }}{{ if eq . 3 }}{{ break }}{{ end }}{{ . }}{{ end }}\"\nnone: [{{ range 0 }}{{ . }}{{ else }}x{{ end }}]\n",
+			expect: "n: [0, 1, 2]\ns: \"012\"\nnone: [x]\n",
+		},
+		{
+			source: "a: {{ with .missing }}a{{ else with .name }}{{ . }}{{ else }}c{{ end }}\nb: {{ with .missing }}a{{ else with .debug }}b{{ else }}c{{ end }}\n",
+			expect: "a: web\nb: c\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			var buf bytes.Buffer
+			if _, err := (&executor.Executor{}).Render(&buf, f, data); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := buf.String(); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestRenderDirectives(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "%YAML 1.2\n%TAG !e! tag:example.com,2000:app/\n---\na: !e!foo {{ .name }}\n",
+			expect: "%YAML 1.2\n%TAG !e! tag:example.com,2000:app/\n---\na: !e!foo web\n",
+		},
+		{
+			source: "a: 1\n...\n%YAML 1.1\n---\nb: 2\n",
+			expect: "a: 1\n...\n%YAML 1.1\n---\nb: 2\n",
+		},
+		{
+			source: "a: 1\n---\n%TAG ! tag:example.com,2000:\n---\nb: !x 2\n",
+			expect: "a: 1\n...\n%TAG ! tag:example.com,2000:\n---\nb: !x 2\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			source := test.source
+			// Render the template, then render its output again: the output must parse and render to itself.
+			for i := 0; i < 2; i++ {
+				f, err := parser.ParseBytes([]byte(source), 0)
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				var buf bytes.Buffer
+				if _, err := (&executor.Executor{}).Render(&buf, f, data); err != nil {
+					t.Fatalf("%+v", err)
+				}
+				if actual := buf.String(); actual != test.expect {
+					t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+				}
+				source = buf.String()
+			}
+		})
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	source := `{{ define "labels" }}
+app: {{ .name }}
+{{ end }}
+metadata:
+  labels:
+    {{ template "labels" . }}
+  ports:
+    {{ range .ports }}
+    - port: {{ . }}
+    {{ end }}
+`
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var buf bytes.Buffer
+	sourceMap, err := (&executor.Executor{}).Render(&buf, f, data)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	tests := []struct {
+		line   int
+		expect string
+	}{
+		{line: 1, expect: "4:1"},
+		{line: 3, expect: `2:1 (template "labels" at 6:5)`},
+		{line: 5, expect: "9:7 (range iteration 0 at 8:5)"},
+		{line: 6, expect: "9:7 (range iteration 1 at 8:5)"},
+	}
+	for _, test := range tests {
+		mapping := sourceMap.Lookup(test.line, 0)
+		if mapping == nil || mapping.Origin == nil {
+			t.Fatalf("no origin for line %d", test.line)
+		}
+		if actual := mapping.Origin.String(); actual != test.expect {
+			t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+		}
+	}
+
+	mapping := sourceMap.Lookup(5, 13)
+	if actual := mapping.Origin.String(); actual != "9:13 (range iteration 0 at 8:5)" {
+		t.Fatalf("expected: [%s] but got [%s]", "9:13 (range iteration 0 at 8:5)", actual)
+	}
+
+	sourceMap.File = "deployment.yaml"
+	expect := `invalid value at line 6 (deployment.yaml:9:7 (range iteration 1 at 8:5))`
+	if actual := sourceMap.Rewrite("invalid value at line 6"); actual != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, actual)
+	}
+
+	err = sourceMap.Annotate(errors.New("error validating data: line 6: unknown field"))
+	if !strings.HasPrefix(err.Error(), "[9:7] error validating data: line 6 (deployment.yaml:") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = sourceMap.Annotate(errors.New("no position")); err.Error() != "no position" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExecuteError(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "a: 1\nb: {{ .missing }}\n",
+			expect: `[2:4] at <.missing>: map has no entry for key "missing"`,
+		},
+		{
+			source: "a: {{ nope }}\n",
+			expect: `[1:4] at <nope>: "nope" is not a defined function`,
+		},
+		{
+			source: "{{ define \"x\" }}\nk: {{ .y }}\n{{ end }}\na:\n  {{ template \"x\" 3 }}\n",
+			expect: `[2:4] executing "x" at <.y>: can't evaluate field y in type int`,
+		},
+		{
+			source: "a:\n  {{ template \"x\" }}\n",
+			expect: `[2:3] template "x" not defined`,
+		},
+		{
+			source: "a:\n  b: {{ if .name }}\n---\nc: 1\n{{ end }}\n",
+			expect: `[4:1] documents may only be produced at the top level of a document`,
+		},
+		{
+			source: "a: x-{{ .ports }}\n",
+			expect: `[1:6] cannot interpolate Sequence into a string`,
+		},
+		{
+			source: "a: [{{ range $i, $x := 3 }}{{ $x }}{{ end }}]\n",
+			expect: `[1:5] can't use 3 to iterate over more than one variable`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			_, err = (&executor.Executor{MissingKey: executor.MissingKeyError}).Execute(f, data)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if actual := err.Error(); !strings.HasPrefix(actual, test.expect) {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	source := `{{ define "labels" }}
+app: {{ .name }}
+{{ end }}
+labels:
+  {{ template "labels" . }}
+ports:
+  {{ range $i, $p := .ports }}
+  - {{ . }}
+  {{ end }}
+debug: {{ if .debug }}yes{{ else }}no{{ end }}
+`
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	result, err := (&executor.Executor{Trace: true}).Execute(f, map[string]interface{}{
+		"name":  "web",
+		"ports": []int{80, 443},
+		"debug": false,
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	expect := []string{
+		`5:3 template "labels" = map[debug:false name:web ports:[80 443]]`,
+		`2:6 action {{ .name }} = web`,
+		`7:3 range 0 = 80`,
+		`7:3 variable $p = 80`,
+		`7:3 variable $i = 0`,
+		`8:5 action {{ . }} = 80`,
+		`7:3 range 1 = 443`,
+		`7:3 variable $p = 443`,
+		`7:3 variable $i = 1`,
+		`8:5 action {{ . }} = 443`,
+		`10:8 branch else = false`,
+	}
+	if len(result.Trace.Events) != len(expect) {
+		t.Fatalf("expected %d events but got %d", len(expect), len(result.Trace.Events))
+	}
+	for i, e := range result.Trace.Events {
+		if actual := fmt.Sprintf("%d:%d %s", e.Line, e.Column, e); actual != expect[i] {
+			t.Fatalf("expected: [%s] but got [%s]", expect[i], actual)
+		}
+	}
+	if result.Trace.Events[1].Template != "labels" {
+		t.Fatalf("expected event in template %q but got %q", "labels", result.Trace.Events[1].Template)
+	}
+
+	var buf bytes.Buffer
+	if err := result.Trace.WriteJSON(&buf); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var trace executor.Trace
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !reflect.DeepEqual(&trace, result.Trace) {
+		t.Fatalf("expected: [%v] but got [%v]", result.Trace, &trace)
+	}
+
+	var p printer.Printer
+	annotated := result.Trace.Annotate(&p, lexer.Tokenize(source))
+	if !strings.Contains(annotated, " 8 |   - {{ . }}\n   |     ^ action {{ . }} = 80\n") {
+		t.Fatalf("unexpected annotation: %s", annotated)
+	}
+	if p.LineNumber || p.LineNumberFormat != nil {
+		t.Fatalf("Annotate modified the printer: %+v", p)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	funcs := map[string]interface{}{"upper": strings.ToUpper, "repeat": strings.Repeat}
+
+	tests := []struct {
+		source   string
+		executor executor.Executor
+		ctx      context.Context
+		expect   string
+	}{
+		{
+			source:   "{{ define \"r\" }}\nx:\n  {{ template \"r\" . }}\n{{ end }}\na:\n  {{ template \"r\" . }}\n",
+			executor: executor.Executor{Limits: executor.Limits{MaxDepth: 10}},
+			expect:   `[3:3] executing "r": exceeded maximum template depth (10)`,
+		},
+		{
+			source:   "a: {{ .ports }}\nb: {{ .ports }}\n",
+			executor: executor.Executor{Limits: executor.Limits{MaxNodes: 8}},
+			expect:   `[2:4] exceeded maximum output size (8 nodes)`,
+		},
+		{
+			source:   "a:\n  {{ range .ports }}\n  - {{ . }}\n  {{ end }}\n",
+			executor: executor.Executor{Limits: executor.Limits{MaxRangeIterations: 1}},
+			expect:   `[2:3] exceeded maximum range iterations (1)`,
+		},
+		{
+			source: "a: {{ .name }}\n",
+			ctx:    canceled,
+			expect: `[1:4] context canceled`,
+		},
+		{
+			source:   "a: {{ upper .name }}\n",
+			executor: executor.Executor{Funcs: funcs, AllowedFuncs: []string{}},
+			expect:   `[1:4] at <upper>: "upper" is not an allowed function`,
+		},
+		{
+			source:   "a: {{ .name }}\nb: {{ .name }}\n",
+			executor: executor.Executor{Limits: executor.Limits{MaxOutputBytes: 6}},
+			expect:   `[2:4] exceeded maximum output size (6 bytes)`,
+		},
+		{
+			source:   "a: {{ len (repeat \"x\" 1000) }}\n",
+			executor: executor.Executor{Funcs: funcs, Limits: executor.Limits{MaxOutputBytes: 100}},
+			expect:   `[1:4] at <repeat "x" 1000>: exceeded maximum output size (100 bytes)`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytesFuncs([]byte(test.source), 0, funcs)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			_, err = test.executor.ExecuteContext(ctx, f, data)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if actual := err.Error(); !strings.HasPrefix(actual, test.expect) {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	f, err := parser.ParseBytesFuncs([]byte("a: {{ upper .name }}\n"), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var buf bytes.Buffer
+	e := executor.Executor{Funcs: funcs, AllowedFuncs: []string{"upper"}}
+	if _, err := e.Render(&buf, f, data); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if actual := buf.String(); actual != "a: WEB\n" {
+		t.Fatalf("expected: [%s] but got [%s]", "a: WEB\n", actual)
+	}
+
+	// The rendered output includes indicators and indentation as well as scalar text.
+	buf.Reset()
+	e = executor.Executor{Funcs: funcs, Limits: executor.Limits{MaxOutputBytes: 5}}
+	result, err := e.Execute(f, data)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = result.Render(&buf)
+	if err == nil || err.Error() != "exceeded maximum output size (5 bytes)" || buf.Len() != 0 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestScope(t *testing.T) {
+	funcs := map[string]interface{}{
+		"entries": func(scope *executor.Scope, name string, data interface{}) (int, error) {
+			node, err := scope.Template(name, data)
+			if err != nil {
+				return 0, err
+			}
+			return len(node.(*ast.MappingNode).Values), nil
+		},
+		"node": func(scope *executor.Scope, name string, data interface{}) (ast.Node, error) {
+			return scope.Template(name, data)
+		},
+	}
+	source := "{{ define \"labels\" }}\napp: {{ .name }}\ntier: web\n{{ end }}\ncount: {{ entries \"labels\" . }}\n" +
+		"labels: {{ node \"labels\" . }}\ntext: {{ printf \"%s\" (node \"labels\" .) | len }}\n"
+	f, err := parser.ParseBytesFuncs([]byte(source), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := (&executor.Executor{Funcs: funcs}).Render(&buf, f, data); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if expect := "count: 2\nlabels:\n  app: web\n  tier: web\ntext: 18\n"; buf.String() != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, buf.String())
+	}
+
+	f, err = parser.ParseBytesFuncs([]byte("count: {{ entries \"missing\" . }}\n"), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = (&executor.Executor{Funcs: funcs}).Execute(f, data)
+	if expect := `[1:8] at <entries "missing" .>: error calling entries: template "missing" not defined`; err == nil || !strings.HasPrefix(err.Error(), expect) {
+		t.Fatalf("expected: [%s] but got [%v]", expect, err)
+	}
+
+	funcs["funcs"] = func(scope *executor.Scope) []string {
+		var names []string
+		for name := range scope.Funcs() {
+			names = append(names, name)
+		}
+		return names
+	}
+	f, err = parser.ParseBytesFuncs([]byte("funcs: {{ funcs }}\n"), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	buf.Reset()
+	if _, err := (&executor.Executor{Funcs: funcs, AllowedFuncs: []string{"funcs"}}).Render(&buf, f, data); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if expect := "funcs:\n  - funcs\n"; buf.String() != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, buf.String())
+	}
+	// A template evaluated by a function may not call the functions that the executor does not allow, even if the
+	// function passes them to the parser.
+	funcs["eval"] = func(scope *executor.Scope, text string) (ast.Node, error) {
+		f, err := parser.ParseBytesFuncs([]byte(text), 0, funcs)
+		if err != nil {
+			return nil, err
+		}
+		return scope.Eval(f, nil)
+	}
+	f, err = parser.ParseBytesFuncs([]byte("a: {{ eval \"{{ funcs }}\" }}\n"), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	_, err = (&executor.Executor{Funcs: funcs, AllowedFuncs: []string{"eval"}}).Execute(f, data)
+	if expect := `"funcs" is not an allowed function`; err == nil || !strings.Contains(err.Error(), expect) {
+		t.Fatalf("expected an error containing [%s] but got [%v]", expect, err)
+	}
+}
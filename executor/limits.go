@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// Limits bounds the resources that an execution may consume. Limits protect against templates from untrusted sources
+// that recurse through template invocations, loop over large or nested ranges, or produce enormous output. A zero
+// value for a limit selects its default.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of template invocations. Defaults to 100000.
+	MaxDepth int
+	// MaxNodes is the maximum number of YAML nodes that an execution may produce. Defaults to no limit.
+	MaxNodes int
+	// MaxOutputBytes is the maximum number of bytes of scalar text that an execution may produce, the maximum length
+	// of a string returned by a function, and the maximum size of the rendered output. Defaults to no limit.
+	MaxOutputBytes int
+	// MaxRangeIterations is the maximum total number of range iterations across an execution. Iterations of nested
+	// ranges count toward the same total. Defaults to no limit.
+	MaxRangeIterations int
+}
+
+func (l Limits) maxDepth() int {
+	if l.MaxDepth <= 0 {
+		return maxExecDepth
+	}
+	return l.MaxDepth
+}
+
+// usage records the resources consumed by an execution. It is shared by the states of invoked templates.
+type usage struct {
+	nodes      int
+	bytes      int
+	iterations int
+}
+
+// checkContext stops execution if the execution's context has been canceled or has expired.
+func (s *state) checkContext(tk *token.Token) {
+	select {
+	case <-s.ctx.Done():
+		s.at(tk, nil)
+		s.errorf("%v", s.ctx.Err())
+	default:
+	}
+}
+
+// countNode records the production of an output node and stops execution if the node limit has been reached.
+func (s *state) countNode(tk *token.Token) {
+	s.usage.nodes++
+	if max := s.e.Limits.MaxNodes; max > 0 && s.usage.nodes > max {
+		s.at(tk, nil)
+		s.errorf("exceeded maximum output size (%v nodes)", max)
+	}
+}
+
+// countBytes records the production of n bytes of output text and stops execution if the byte limit has been reached.
+func (s *state) countBytes(tk *token.Token, n int) {
+	s.usage.bytes += n
+	if max := s.e.Limits.MaxOutputBytes; max > 0 && s.usage.bytes > max {
+		s.at(tk, nil)
+		s.errorf("exceeded maximum output size (%v bytes)", max)
+	}
+}
+
+// checkResult stops execution if a function returned a string that is longer than the byte limit. The string need not
+// be output: a string that large is rejected as soon as it is produced.
+func (s *state) checkResult(node ast.TemplateNode, v reflect.Value) {
+	v = indirectInterface(v)
+	if max := s.e.Limits.MaxOutputBytes; max > 0 && v.Kind() == reflect.String && v.Len() > max {
+		s.at(s.tk, node)
+		s.errorf("exceeded maximum output size (%v bytes)", max)
+	}
+}
+
+// countIteration records a range iteration and stops execution if the iteration limit has been reached.
+func (s *state) countIteration(tk *token.Token) {
+	s.usage.iterations++
+	if max := s.e.Limits.MaxRangeIterations; max > 0 && s.usage.iterations > max {
+		s.at(tk, nil)
+		s.errorf("exceeded maximum range iterations (%v)", max)
+	}
+}
+
+// isAllowed returns true if the named function may be called. Builtin functions may always be called.
+func (e *Executor) isAllowed(name string) bool {
+	if e.AllowedFuncs == nil {
+		return true
+	}
+	for _, allowed := range e.AllowedFuncs {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package executor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// renderMode describes the position of the cursor when a value is rendered.
+type renderMode int
+
+const (
+	// modeTop indicates that the cursor is at the start of the value's line, after any indentation.
+	modeTop renderMode = iota
+	// modeKey indicates that the cursor follows a mapping key, an anchor, or a tag.
+	modeKey
+	// modeDash indicates that the cursor follows a sequence entry indicator.
+	modeDash
+)
+
+// renderer renders an executed file as block-style YAML and records the output position of each node.
+type renderer struct {
+	result    *Result
+	buf       bytes.Buffer
+	line      int
+	column    int
+	sourceMap *SourceMap
+	maxBytes  int
+	exceeded  bool
+}
+
+func (r *renderer) write(s string) {
+	if r.exceeded || r.maxBytes > 0 && r.buf.Len()+len(s) > r.maxBytes {
+		r.exceeded = true
+		return
+	}
+	for _, c := range s {
+		if c == '\n' {
+			r.line, r.column = r.line+1, 1
+		} else {
+			r.column++
+		}
+	}
+	r.buf.WriteString(s)
+}
+
+func (r *renderer) newline(indent int) {
+	r.write("\n")
+	r.write(strings.Repeat(" ", indent))
+}
+
+// mark records the current output position as the position of the given node.
+func (r *renderer) mark(node ast.Node) {
+	r.sourceMap.Mappings = append(r.sourceMap.Mappings, Mapping{
+		Line:   r.line,
+		Column: r.column,
+		Node:   node,
+		Origin: r.result.origins[node],
+	})
+}
+
+func (r *renderer) file(f *ast.File) {
+	for i, doc := range f.Docs {
+		switch {
+		case isDirective(doc):
+			// Directives precede the header of the document they apply to, so a document that precedes them must be
+			// ended explicitly.
+			if prev := i - 1; prev >= 0 && !isDirective(f.Docs[prev]) && f.Docs[prev].End == nil {
+				r.write("...\n")
+			}
+		case i > 0 || doc.Start != nil:
+			r.write("---\n")
+		}
+		if doc.Body != nil {
+			r.value(doc.Body, 0, modeTop)
+			r.write("\n")
+		}
+		if doc.End != nil {
+			r.write("...\n")
+		}
+	}
+}
+
+// isDirective returns true if the document holds a directive.
+func isDirective(doc *ast.DocumentNode) bool {
+	_, ok := doc.Body.(*ast.DirectiveNode)
+	return ok
+}
+
+// isBlock returns true if the node is rendered as a block collection.
+func isBlock(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return !n.IsFlowStyle && len(n.Values) != 0
+	case *ast.SequenceNode:
+		return !n.IsFlowStyle && len(n.Values) != 0
+	}
+	return false
+}
+
+// value renders a node in block context. Block collections that follow a key are rendered on the next line at the
+// given indentation; block collections in other positions begin at the cursor.
+func (r *renderer) value(node ast.Node, indent int, mode renderMode) {
+	if !isBlock(node) {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			r.prefix(mode)
+			r.mark(n)
+			r.write("&" + n.Name.GetToken().Value)
+			r.value(n.Value, indent, modeKey)
+		case *ast.TagNode:
+			r.prefix(mode)
+			r.mark(n)
+			r.write(n.Start.Value)
+			r.value(n.Value, indent, modeKey)
+		case *ast.BinaryNode:
+			r.mark(n)
+			r.value(n.Text, indent, mode)
+		case *ast.LiteralNode:
+			r.prefix(mode)
+			if mode == modeTop {
+				indent += 2
+			}
+			r.literal(n, indent)
+		case *ast.StringNode:
+			r.prefix(mode)
+			if strings.Contains(n.Value, "\n") && n.Token.Type == token.StringType {
+				if mode == modeTop {
+					indent += 2
+				}
+				r.mark(n)
+				r.block(token.LiteralBlockHeader(n.Value), n.Value, indent)
+				return
+			}
+			r.flow(n, false)
+		default:
+			r.prefix(mode)
+			r.flow(n, false)
+		}
+		return
+	}
+
+	if mode == modeKey {
+		r.newline(indent)
+	}
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		r.mark(n)
+		for i, mv := range n.Values {
+			if i > 0 {
+				r.newline(indent)
+			}
+			r.mark(mv)
+			r.key(mv.Key)
+			r.write(":")
+			r.value(mv.Value, indent+2, modeKey)
+		}
+	case *ast.SequenceNode:
+		r.mark(n)
+		for i, v := range n.Values {
+			if i > 0 {
+				r.newline(indent)
+			}
+			r.write("- ")
+			r.value(v, indent+2, modeDash)
+		}
+	}
+}
+
+// prefix writes the separator between a key, anchor, or tag and a value on the same line.
+func (r *renderer) prefix(mode renderMode) {
+	if mode == modeKey {
+		r.write(" ")
+	}
+}
+
+// literal renders a literal or folded block scalar using its original header and content.
+func (r *renderer) literal(n *ast.LiteralNode, indent int) {
+	r.mark(n)
+	content := n.Value.Token.Origin
+	if content == "" {
+		content = n.Value.Value
+	}
+	r.block(n.Start.Value, dedent(content), indent)
+}
+
+// block renders a block scalar with the given header and content.
+func (r *renderer) block(header, content string, indent int) {
+	r.write(header)
+	if strings.Contains(header, "+") {
+		content = strings.TrimSuffix(content, "\n")
+	} else {
+		content = strings.TrimRight(content, "\n")
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			r.write("\n")
+		} else {
+			r.newline(indent)
+			r.write(line)
+		}
+	}
+}
+
+// dedent removes the common indentation from the non-empty lines of s.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if common == -1 || n < common {
+			common = n
+		}
+	}
+	if common < 0 {
+		common = 0
+	}
+	for i, line := range lines {
+		if len(line) >= common {
+			lines[i] = line[common:]
+		} else {
+			lines[i] = strings.TrimLeft(line, " ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// key renders a mapping key.
+func (r *renderer) key(node ast.Node) {
+	if k, ok := node.(*ast.MappingKeyNode); ok {
+		node = k.Value
+	}
+	r.flow(node, true)
+}
+
+// flow renders a node in flow context. If inFlow is false, only the node itself is a flow node.
+func (r *renderer) flow(node ast.Node, inFlow bool) {
+	r.mark(node)
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		r.write("{")
+		for i, mv := range n.Values {
+			if i > 0 {
+				r.write(", ")
+			}
+			r.mark(mv)
+			r.key(mv.Key)
+			r.write(": ")
+			r.flow(mv.Value, true)
+		}
+		r.write("}")
+	case *ast.SequenceNode:
+		r.write("[")
+		for i, v := range n.Values {
+			if i > 0 {
+				r.write(", ")
+			}
+			r.flow(v, true)
+		}
+		r.write("]")
+	case *ast.AnchorNode:
+		r.write("&" + n.Name.GetToken().Value + " ")
+		r.flow(n.Value, inFlow)
+	case *ast.AliasNode:
+		r.write("*" + n.Value.GetToken().Value)
+	case *ast.TagNode:
+		r.write(n.Start.Value + " ")
+		r.flow(n.Value, inFlow)
+	case *ast.BinaryNode:
+		r.flow(n.Text, inFlow)
+	case *ast.LiteralNode:
+		r.write(strconv.Quote(n.Value.Value))
+	case *ast.StringNode:
+		r.write(quote(n.Value, n.Token.Type, inFlow))
+	case *ast.NullNode:
+		r.write("null")
+	case *ast.MergeKeyNode:
+		r.write("<<")
+	case *ast.DirectiveNode:
+		r.write(n.Start.Value + Text(n.Value))
+	case nil:
+		r.write("null")
+	default:
+		r.write(node.GetToken().Value)
+	}
+}
+
+// quote returns the representation of a string scalar. Strings that were quoted in the template keep their quoting
+// style; plain strings are quoted only if they would not otherwise be read back as the same string.
+func quote(value string, typ token.Type, inFlow bool) string {
+	switch {
+	case typ == token.DoubleQuoteType || !isPrintable(value):
+		return strconv.Quote(value)
+	case typ == token.SingleQuoteType || needsQuotes(value, inFlow):
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return value
+}
+
+// needsQuotes returns true if the plain string value must be quoted.
+func needsQuotes(value string, inFlow bool) bool {
+	if token.IsNeedQuoted(value) || strings.TrimSpace(value) != value {
+		return true
+	}
+	if inFlow && strings.ContainsAny(value, ",[]{}") {
+		return true
+	}
+	switch value[0] {
+	case '@', '`':
+		return true
+	case '-', '?':
+		if len(value) == 1 || value[1] == ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrintable returns true if the value contains only printable characters.
+func isPrintable(value string) bool {
+	for _, c := range value {
+		if !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// A Scope gives a function access to the execution of the action that calls it. A function whose first parameter has
+// type *Scope receives the scope of the calling action as its first argument; the template supplies the arguments
+// for the remaining parameters. A Scope is only valid for the duration of the call that receives it.
+//
+// A function may return the nodes it obtains from a Scope. An action whose result is a node splices the node into the
+// output; a node that is passed to a string parameter or to a builtin function is converted to its Text.
+type Scope struct {
+	s  *state
+	tk *token.Token
+}
+
+var scopeType = reflect.TypeOf((*Scope)(nil))
+
+// Funcs returns the functions that templates may call in addition to the builtin functions. If the executor's
+// AllowedFuncs is non-nil, only the allowed functions are returned.
+func (sc *Scope) Funcs() map[string]interface{} {
+	e := sc.s.e
+	if e.AllowedFuncs == nil {
+		return e.Funcs
+	}
+	funcs := make(map[string]interface{}, len(e.AllowedFuncs))
+	for name, fn := range e.Funcs {
+		if e.isAllowed(name) {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+// Template evaluates the template with the given name with data as the value of dot and returns the node it
+// produces. A template that produces no nodes produces a null node; a template that produces several mapping entries
+// or sequences produces a single mapping or sequence.
+func (sc *Scope) Template(name string, data interface{}) (node ast.Node, err error) {
+	tmpl, ok := sc.s.tmpl[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not defined", name)
+	}
+
+	defer sc.s.recover(&err)
+	dot := reflect.ValueOf(data)
+	s := sc.s.invoke(sc.tk, name, dot)
+	return s.combine(sc.tk, s.walkList(dot, tmpl.List)), nil
+}
+
+// Eval evaluates the templates in a parsed file with data as the value of dot and returns the node produced by the
+// body of the file's document, which is combined as by Template. The file's templates may invoke the templates
+// defined by the executing file as well as those defined by the file itself.
+func (sc *Scope) Eval(f *ast.File, data interface{}) (node ast.Node, err error) {
+	defer sc.s.recover(&err)
+
+	dot := reflect.ValueOf(data)
+	s := sc.s.invoke(sc.tk, f.Name, dot)
+	s.tmpl = make(map[string]*ast.DefineNode, len(sc.s.tmpl))
+	for name, d := range sc.s.tmpl {
+		s.tmpl[name] = d
+	}
+	for _, d := range ast.FilterFile(ast.DefineType, f) {
+		d := d.(*ast.DefineNode)
+		s.tmpl[d.Name] = d
+	}
+
+	var nodes []ast.Node
+	for _, doc := range f.Docs {
+		if doc.Body != nil {
+			nodes = append(nodes, s.walk(dot, doc.Body)...)
+		}
+	}
+	return s.combine(sc.tk, nodes), nil
+}
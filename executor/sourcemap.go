@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// FrameKind identifies the kind of a frame in an origin's stack.
+type FrameKind int
+
+const (
+	// TemplateFrame is a frame for a template invocation.
+	TemplateFrame FrameKind = iota
+	// RangeFrame is a frame for an iteration of a range action.
+	RangeFrame
+)
+
+// Frame records a template invocation or range iteration that was active when a node was produced.
+type Frame struct {
+	// Kind is the kind of the frame.
+	Kind FrameKind
+	// Token is the token of the template or range action.
+	Token *token.Token
+	// Name is the name of the invoked template. Only set for template frames.
+	Name string
+	// Index is the zero-based index of the iteration. Only set for range frames.
+	Index int
+}
+
+// String returns a description of the frame, e.g. `template "labels" at 12:5`.
+func (f Frame) String() string {
+	pos := positionString(f.Token)
+	if f.Kind == TemplateFrame {
+		return fmt.Sprintf("template %q at %s", f.Name, pos)
+	}
+	return fmt.Sprintf("range iteration %d at %s", f.Index, pos)
+}
+
+// Origin records the source of a rendered node: the token in the template that produced the node and the template
+// invocations and range iterations that were active at the time, outermost first.
+type Origin struct {
+	Token  *token.Token
+	Frames []Frame
+}
+
+// String returns a description of the origin, e.g. `7:5 (range iteration 2 at 3:3, template "labels" at 12:5)`.
+// Frames are listed innermost first.
+func (o *Origin) String() string {
+	var sb strings.Builder
+	sb.WriteString(positionString(o.Token))
+	if len(o.Frames) != 0 {
+		sb.WriteString(" (")
+		for i := len(o.Frames) - 1; i >= 0; i-- {
+			if i != len(o.Frames)-1 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(o.Frames[i].String())
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+func positionString(tk *token.Token) string {
+	if tk == nil || tk.Position == nil {
+		return "?"
+	}
+	return fmt.Sprintf("%d:%d", tk.Position.Line, tk.Position.Column)
+}
+
+// Mapping maps a position in rendered output to the node rendered at that position and its origin.
+type Mapping struct {
+	// Line is the 1-based line of the node in the rendered output.
+	Line int
+	// Column is the 1-based column of the node in the rendered output.
+	Column int
+	// Node is the rendered node.
+	Node ast.Node
+	// Origin is the origin of the node in the template.
+	Origin *Origin
+}
+
+// SourceMap maps positions in rendered output to their origins in the template. Mappings are ordered by position.
+type SourceMap struct {
+	// File is the name of the template file.
+	File string
+	// Mappings holds the mappings for each rendered node.
+	Mappings []Mapping
+}
+
+// Lookup returns the mapping for the node at the given position in the rendered output. If column is less than one,
+// Lookup returns the mapping for the first scalar on the line. Otherwise, Lookup returns the last mapping at or before
+// the position. If there is no such mapping, Lookup returns the nearest preceding mapping, or nil if there is none.
+func (m *SourceMap) Lookup(line, column int) *Mapping {
+	var found *Mapping
+	for i := range m.Mappings {
+		mapping := &m.Mappings[i]
+		if mapping.Line > line || mapping.Line == line && column > 0 && mapping.Column > column {
+			break
+		}
+		found = mapping
+		if mapping.Line == line && column <= 0 && isScalar(mapping.Node) {
+			break
+		}
+	}
+	return found
+}
+
+func isScalar(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.MappingNode, *ast.MappingValueNode, *ast.SequenceNode, *ast.AnchorNode, *ast.TagNode:
+		return false
+	}
+	return true
+}
+
+var lineReference = regexp.MustCompile(`\bline (\d+)`)
+
+// Rewrite rewrites references of the form "line N" in a message about the rendered output to include the origin of
+// the line in the template.
+func (m *SourceMap) Rewrite(msg string) string {
+	return lineReference.ReplaceAllStringFunc(msg, func(ref string) string {
+		line, err := strconv.Atoi(ref[len("line "):])
+		if err != nil {
+			return ref
+		}
+		mapping := m.Lookup(line, 0)
+		if mapping == nil || mapping.Origin == nil {
+			return ref
+		}
+		file := m.File
+		if file != "" {
+			file += ":"
+		}
+		return fmt.Sprintf("%s (%s%s)", ref, file, mapping.Origin)
+	})
+}
+
+// Annotate annotates an error that refers to a line in the rendered output with the origin of the line. If the error
+// refers to no such line, Annotate returns the error unchanged.
+func (m *SourceMap) Annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	match := lineReference.FindStringSubmatch(msg)
+	if match == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(match[1])
+	mapping := m.Lookup(line, 0)
+	if mapping == nil || mapping.Origin == nil || mapping.Origin.Token == nil {
+		return err
+	}
+	return errors.ErrSyntax(m.Rewrite(msg), mapping.Origin.Token)
+}
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// TraceKind identifies the kind of a trace event.
+type TraceKind string
+
+const (
+	// TraceAction records the evaluation of an action.
+	TraceAction TraceKind = "action"
+	// TraceBranch records the branch taken by an if or with action.
+	TraceBranch TraceKind = "branch"
+	// TraceRange records an iteration of a range action.
+	TraceRange TraceKind = "range"
+	// TraceVariable records a variable binding.
+	TraceVariable TraceKind = "variable"
+	// TraceTemplate records a template invocation.
+	TraceTemplate TraceKind = "template"
+)
+
+// TraceEvent records a single step of template execution.
+type TraceEvent struct {
+	// Kind is the kind of the event.
+	Kind TraceKind `json:"kind"`
+	// Line is the line of the action that produced the event.
+	Line int `json:"line"`
+	// Column is the column of the action that produced the event.
+	Column int `json:"column"`
+	// Template is the name of the executing template, if any.
+	Template string `json:"template,omitempty"`
+	// Source is the text of the action that produced the event.
+	Source string `json:"source"`
+	// Name is the name of the bound variable or invoked template.
+	Name string `json:"name,omitempty"`
+	// Branch is the branch taken by an if or with action: "then", "else", or "none".
+	Branch string `json:"branch,omitempty"`
+	// Index is the index of a range iteration.
+	Index int `json:"index"`
+	// Value is the formatted value of the event: the result of an action, the condition of a branch, the element of
+	// a range iteration, the value bound to a variable, or the data passed to a template.
+	Value string `json:"value"`
+}
+
+// String returns a description of the event, e.g. `range 1 = 443`.
+func (e TraceEvent) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(e.Kind))
+	switch e.Kind {
+	case TraceAction:
+		sb.WriteString(" " + e.Source)
+	case TraceBranch:
+		sb.WriteString(" " + e.Branch)
+	case TraceRange:
+		fmt.Fprintf(&sb, " %d", e.Index)
+	case TraceVariable:
+		sb.WriteString(" " + e.Name)
+	case TraceTemplate:
+		fmt.Fprintf(&sb, " %q", e.Name)
+	}
+	sb.WriteString(" = " + e.Value)
+	return sb.String()
+}
+
+// Trace records the steps of a template execution in the order in which they occurred.
+type Trace struct {
+	Events []TraceEvent `json:"events"`
+}
+
+// WriteJSON writes the trace to w as JSON.
+func (t *Trace) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+var colorEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Annotate prints the template tokens using the printer and annotates each line with the events that occurred on that
+// line. Line numbers are always printed; the given printer is not modified.
+func (t *Trace) Annotate(p *printer.Printer, tokens token.Tokens) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	events := map[int][]TraceEvent{}
+	for _, e := range t.Events {
+		events[e.Line] = append(events[e.Line], e)
+	}
+
+	lineNumbers := *p
+	lineNumbers.LineNumber = true
+	p = &lineNumbers
+	lines := strings.Split(p.PrintTokens(tokens), "\n")
+	out := make([]string, 0, len(lines))
+	for i, text := range lines {
+		out = append(out, text)
+
+		line := tokens[0].Position.Line + i
+		if len(events[line]) == 0 {
+			continue
+		}
+
+		// Annotation lines use the line number format with the digits blanked out.
+		header := colorEscape.ReplaceAllString(p.LineNumberFormat(line), "")
+		header = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return ' '
+			}
+			return r
+		}, header)
+		for _, e := range events[line] {
+			out = append(out, header+strings.Repeat(" ", e.Column-1)+"^ "+e.String())
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// trace records an event at the current action if tracing is enabled.
+func (s *state) trace(kind TraceKind, tk *token.Token, e TraceEvent, value reflect.Value) {
+	if s.tracer == nil {
+		return
+	}
+	e.Kind, e.Template, e.Value = kind, s.name, formatValue(value)
+	if tk != nil {
+		e.Source = tk.Value
+		if tk.Position != nil {
+			e.Line, e.Column = tk.Position.Line, tk.Position.Column
+		}
+	}
+	s.tracer.Events = append(s.tracer.Events, e)
+}
+
+// traceDecls records the variables bound by a pipeline.
+func (s *state) traceDecls(tk *token.Token, pipe *ast.PipeNode, value reflect.Value) {
+	for _, variable := range pipe.Decl {
+		s.trace(TraceVariable, tk, TraceEvent{Name: variable.Ident[0]}, value)
+	}
+}
+
+// formatValue formats a value for a trace event.
+func formatValue(v reflect.Value) string {
+	if isMissing(v) {
+		return "<no value>"
+	}
+	iface, ok := printableValue(v)
+	if !ok {
+		return v.Type().String()
+	}
+	return fmt.Sprint(iface)
+}
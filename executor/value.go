@@ -0,0 +1,296 @@
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/token"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	timeType     = reflect.TypeOf(time.Time{})
+	bytesType    = reflect.TypeOf([]byte(nil))
+	nodeType     = reflect.TypeOf((*ast.Node)(nil)).Elem()
+)
+
+// asNode returns the YAML node held by v, if any. Nodes are produced by functions that evaluate templates, e.g. a
+// function that calls Scope.Template.
+func asNode(v reflect.Value) (ast.Node, bool) {
+	v = indirectInterface(v)
+	if !v.IsValid() || !v.Type().Implements(nodeType) || isNil(v) {
+		return nil, false
+	}
+	return v.Interface().(ast.Node), true
+}
+
+// Text returns the text of a node produced by an execution. The text of a scalar is its value, as it would be
+// interpolated into a string, except that the text of a quoted string keeps its quotes; the text of null is empty; and
+// the text of any other node is its rendered YAML without a trailing newline.
+func Text(node ast.Node) string {
+	switch n := node.(type) {
+	case nil, *ast.NullNode:
+		return ""
+	case *ast.StringNode:
+		if n.Token.Type != token.StringType {
+			return quote(n.Value, n.Token.Type, false)
+		}
+		return n.Value
+	case *ast.LiteralNode:
+		return n.Value.Value
+	case ast.ScalarNode:
+		return n.GetToken().Value
+	}
+
+	rd := &renderer{result: &Result{}, line: 1, column: 1, sourceMap: &SourceMap{}}
+	rd.file(&ast.File{Docs: []*ast.DocumentNode{ast.Document(nil, node)}})
+	return strings.TrimSuffix(rd.buf.String(), "\n")
+}
+
+// valueNode converts the result of an action into a YAML node. Maps, structs, slices, and arrays are converted into
+// mappings and sequences; nil values are converted into null. Each node produced is attributed to the action token tk.
+// A value that is itself a node is spliced into the output as is.
+func (s *state) valueNode(tk *token.Token, v reflect.Value) ast.Node {
+	if n, ok := asNode(v); ok {
+		return n
+	}
+
+	v, _ = indirect(v)
+	if !v.IsValid() || isNil(v) {
+		return s.origin(ast.Null(newToken(tk, token.NullType, "null")), tk)
+	}
+
+	// Arbitrary-precision numbers and times are converted to numbers and timestamps rather than to strings via their
+	// String methods. Byte slices are converted to base64-encoded binary values.
+	switch v.Type() {
+	case bigIntType:
+		i := reflect.New(bigIntType)
+		i.Elem().Set(v)
+		node, _ := ast.Integer(newToken(tk, token.IntegerType, i.Interface().(*big.Int).String()))
+		return s.origin(node, tk)
+	case bigFloatType:
+		f := reflect.New(bigFloatType)
+		f.Elem().Set(v)
+		return s.origin(bigFloatNode(tk, f.Interface().(*big.Float)), tk)
+	case timeType:
+		text := v.Interface().(time.Time).Format(time.RFC3339Nano)
+		node, _ := ast.Timestamp(newToken(tk, token.StringType, text))
+		return s.origin(node, tk)
+	case bytesType:
+		text := base64.StdEncoding.EncodeToString(v.Bytes())
+		node, _ := ast.Binary(ast.String(newToken(tk, token.StringType, text)))
+		tag := ast.Tag(newToken(tk, token.TagType, string(token.BinaryTag)))
+		tag.Value = node
+		return s.origin(tag, tk)
+	}
+
+	if v.Type().Implements(errorType) || v.Type().Implements(fmtStringerType) {
+		return s.origin(ast.String(newToken(tk, token.StringType, fmt.Sprint(v.Interface()))), tk)
+	}
+	if v.CanAddr() && (reflect.PtrTo(v.Type()).Implements(errorType) || reflect.PtrTo(v.Type()).Implements(fmtStringerType)) {
+		return s.origin(ast.String(newToken(tk, token.StringType, fmt.Sprint(v.Addr().Interface()))), tk)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return s.origin(ast.Bool(newToken(tk, token.BoolType, strconv.FormatBool(v.Bool()))), tk)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		node, _ := ast.Integer(newToken(tk, token.IntegerType, strconv.FormatInt(v.Int(), 10)))
+		return s.origin(node, tk)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		node, _ := ast.Integer(newToken(tk, token.IntegerType, strconv.FormatUint(v.Uint(), 10)))
+		return s.origin(node, tk)
+	case reflect.Float32, reflect.Float64:
+		return s.origin(floatNode(tk, v.Float()), tk)
+	case reflect.Complex64, reflect.Complex128:
+		return s.origin(ast.String(newToken(tk, token.StringType, fmt.Sprint(v.Complex()))), tk)
+	case reflect.String:
+		return s.origin(ast.String(newToken(tk, token.StringType, v.String())), tk)
+	case reflect.Array, reflect.Slice:
+		seq := ast.Sequence(newToken(tk, token.SequenceEntryType, "-"), false)
+		for i := 0; i < v.Len(); i++ {
+			seq.Values = append(seq.Values, s.valueNode(tk, v.Index(i)))
+		}
+		return s.origin(seq, tk)
+	case reflect.Map:
+		m := ast.Mapping(newToken(tk, token.MappingStartType, "{"), false)
+		for _, key := range sortKeys(v.MapKeys()) {
+			m.Values = append(m.Values, s.entryNode(tk, s.valueNode(tk, key), v.MapIndex(key)))
+		}
+		return s.origin(m, tk)
+	case reflect.Struct:
+		m := ast.Mapping(newToken(tk, token.MappingStartType, "{"), false)
+		s.appendFields(m, tk, v)
+		return s.origin(m, tk)
+	}
+	s.at(tk, nil)
+	s.errorf("can't convert value of type %s to YAML", v.Type())
+	return nil
+}
+
+// entryNode creates a mapping entry with the given key and value.
+func (s *state) entryNode(tk *token.Token, key ast.Node, value reflect.Value) *ast.MappingValueNode {
+	mv := ast.MappingValue(newToken(tk, token.MappingValueType, ":"), key, s.valueNode(tk, value))
+	s.origin(mv, tk)
+	return mv
+}
+
+// appendFields appends the exported fields of the struct v to m. Field names may be overridden using `yaml` struct
+// tags. Fields tagged with "-" are skipped, and fields tagged with "omitempty" are skipped if they are empty.
+func (s *state) appendFields(m *ast.MappingNode, tk *token.Token, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, omitEmpty := field.Name, false
+		if tag, ok := field.Tag.Lookup("yaml"); ok {
+			if tag == "-" {
+				continue
+			}
+			options := strings.Split(tag, ",")
+			if options[0] != "" {
+				name = options[0]
+			}
+			for _, opt := range options[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		fv := v.Field(i)
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		key := s.origin(ast.String(newToken(tk, token.StringType, name)), tk)
+		m.Values = append(m.Values, s.entryNode(tk, key, fv))
+	}
+}
+
+// isEmptyValue reports whether v is the zero value of its type for the purposes of omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// floatNode creates a node for a floating-point value.
+func floatNode(tk *token.Token, f float64) ast.Node {
+	switch {
+	case math.IsInf(f, 1):
+		return ast.Infinity(newToken(tk, token.InfinityType, ".inf"))
+	case math.IsInf(f, -1):
+		return ast.Infinity(newToken(tk, token.InfinityType, "-.inf"))
+	case math.IsNaN(f):
+		return ast.Nan(newToken(tk, token.NanType, ".nan"))
+	}
+	text := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return ast.Float(newToken(tk, token.FloatType, text))
+}
+
+// bigFloatNode converts an arbitrary-precision float into a YAML node.
+func bigFloatNode(tk *token.Token, f *big.Float) ast.Node {
+	if f.IsInf() {
+		return floatNode(tk, math.Inf(f.Sign()))
+	}
+	text := f.Text('g', -1)
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return ast.Float(newToken(tk, token.FloatType, text))
+}
+
+// newToken creates a token for a node produced by the action token tk. The new token shares the position of tk.
+func newToken(tk *token.Token, typ token.Type, value string) *token.Token {
+	t := tk.Clone()
+	if t == nil {
+		t = &token.Token{}
+	}
+	t.Type = typ
+	t.CharacterType = token.CharacterTypeMiscellaneous
+	t.Indicator = token.NotIndicator
+	t.Value, t.Origin = value, value
+	t.Prev, t.Next = nil, nil
+	return t
+}
+
+// copyScalar returns a shallow copy of a scalar node.
+func copyScalar(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.NullNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.BoolNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.IntegerNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.FloatNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.InfinityNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.NanNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.TimestampNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.BinaryNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.StringNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.MergeKeyNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.LiteralNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	case *ast.DirectiveNode:
+		c := *n
+		c.BaseNode = &ast.BaseNode{Comment: n.GetComment()}
+		return &c
+	}
+	return node
+}
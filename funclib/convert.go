@@ -0,0 +1,77 @@
+package funclib
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// toString converts a value to a string. Nil converts to the empty string.
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return strval(v)
+}
+
+// atoi parses a decimal integer. Strings that are not integers convert to zero.
+func atoi(s string) int {
+	i, _ := strconv.Atoi(strings.TrimSpace(s))
+	return i
+}
+
+func toInt(v interface{}) int {
+	return int(toInt64(v))
+}
+
+// toInt64 converts a number, a boolean, or a numeric string to an integer. Floating-point numbers are truncated.
+// Values that cannot be converted convert to zero.
+func toInt64(v interface{}) int64 {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(val.Float())
+	case reflect.Bool:
+		if val.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Invalid:
+		return 0
+	}
+
+	s := strings.TrimSpace(strval(v))
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return i
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return int64(f)
+}
+
+// toFloat64 converts a number, a boolean, or a numeric string to a floating-point number. Values that cannot be
+// converted convert to zero.
+func toFloat64(v interface{}) float64 {
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return val.Float()
+	case reflect.Bool:
+		if val.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Invalid:
+		return 0
+	}
+
+	f, _ := strconv.ParseFloat(strings.TrimSpace(strval(v)), 64)
+	return f
+}
@@ -0,0 +1,86 @@
+package funclib
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+)
+
+// empty returns true if a value is empty: nil, false, zero, or an empty string, list, or dictionary. Structs are
+// never empty; nodes are empty if their text is empty.
+func empty(given interface{}) bool {
+	if n, ok := given.(ast.Node); ok {
+		return executor.Text(n) == ""
+	}
+
+	v := reflect.ValueOf(given)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// defaultValue returns given if it is present and not empty, and d otherwise.
+func defaultValue(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || empty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+// coalesce returns its first non-empty argument, or nil if all of its arguments are empty.
+func coalesce(v ...interface{}) interface{} {
+	for _, val := range v {
+		if !empty(val) {
+			return val
+		}
+	}
+	return nil
+}
+
+// required returns an error with the given message if a value is nil or the empty string.
+func required(msg string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// ternary returns vt if cond is true, and vf otherwise.
+func ternary(vt, vf interface{}, cond bool) interface{} {
+	if cond {
+		return vt
+	}
+	return vf
+}
+
+// fail returns an error with the given message.
+func fail(msg string) (string, error) {
+	return "", errors.New(msg)
+}
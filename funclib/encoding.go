@@ -0,0 +1,122 @@
+package funclib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/decode"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/json"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// date formats a time with the given layout. The time may be a time.Time or a number of seconds since the Unix
+// epoch, which is formatted in UTC.
+func date(layout string, t interface{}) (string, error) {
+	switch t := t.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		return t.Format(layout), nil
+	case int:
+		return time.Unix(int64(t), 0).UTC().Format(layout), nil
+	case int64:
+		return time.Unix(t, 0).UTC().Format(layout), nil
+	}
+	return "", fmt.Errorf("date: cannot use %T as a time", t)
+}
+
+// valueTemplate is a template that renders the value of dot.
+var valueTemplate = func() *ast.File {
+	f, err := parser.ParseBytes([]byte("{{ . }}"), 0)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}()
+
+// toYaml encodes a value as YAML. The result has no trailing newline.
+func toYaml(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	if _, err := (&executor.Executor{}).Render(&buf, valueTemplate, v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// fromYaml decodes the first document in a YAML string. The string is parsed as plain YAML, so text that looks like a
+// template action is decoded verbatim. Aliases are resolved and merge keys are expanded.
+func fromYaml(s string) (interface{}, error) {
+	file, err := parser.ParseBytes([]byte(s), parser.ParseBigNumbers|parser.ParseNoTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.ResolveAliases(file, parser.AliasLimits{}); err != nil {
+		return nil, err
+	}
+	if err := parser.ExpandMerges(file); err != nil {
+		return nil, err
+	}
+	if len(file.Docs) == 0 {
+		return nil, nil
+	}
+	return decode.Value(file.Docs[0].Body)
+}
+
+// toJson encodes a value as JSON. Nodes, e.g. the results of include, are converted with the json package.
+func toJson(v interface{}) (string, error) {
+	if n, ok := v.(ast.Node); ok {
+		b, err := json.Marshal(n)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// fromJson decodes a JSON string. Integers decode as int64s; other numbers decode as float64s.
+func fromJson(s string) (interface{}, error) {
+	dec := stdjson.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return jsonValue(v), nil
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case stdjson.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,116 @@
+// Package funclib implements a library of template functions that is compatible with the helpers commonly used by
+// Helm charts: string manipulation, lists and dictionaries, defaults, type conversions, semantic version constraints,
+// hashing, YAML and JSON encoding, and the include and tpl functions.
+//
+// The functions are registered by passing the result of Map to both the parser and the executor:
+//
+//	funcs := funclib.Map(funclib.Options{})
+//	f, err := parser.ParseBytesFuncs(source, 0, funcs)
+//	...
+//	result, err := (&executor.Executor{Funcs: funcs}).Execute(f, data)
+//
+// The include and tpl functions produce YAML nodes rather than text. In a value position, their result is spliced into
+// the output, so that `labels: {{ include "labels" . }}` produces a mapping without re-indentation. Passed to a
+// function that expects a string, compared, or printed, their result is converted to its text.
+//
+// The functions are deterministic: functions that read the clock or produce random values are only available if the
+// corresponding options are set.
+package funclib
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Options configures the function library.
+type Options struct {
+	// Now returns the current time. If Now is nil, the now function is not available.
+	Now func() time.Time
+	// Rand is the source of random values. If Rand is nil, the randAlphaNum function is not available.
+	Rand *rand.Rand
+}
+
+// Map returns the functions in the library.
+func Map(options Options) map[string]interface{} {
+	funcs := map[string]interface{}{
+		// Strings
+		"upper":      upper,
+		"lower":      lower,
+		"title":      title,
+		"trim":       trim,
+		"trimAll":    trimAll,
+		"trimPrefix": trimPrefix,
+		"trimSuffix": trimSuffix,
+		"replace":    replace,
+		"contains":   contains,
+		"hasPrefix":  hasPrefix,
+		"hasSuffix":  hasSuffix,
+		"repeat":     repeat,
+		"substr":     substr,
+		"trunc":      trunc,
+		"nospace":    nospace,
+		"quote":      quote,
+		"squote":     squote,
+		"cat":        cat,
+		"indent":     indent,
+		"nindent":    nindent,
+		"splitList":  splitList,
+		"join":       join,
+		"b64enc":     b64enc,
+		"b64dec":     b64dec,
+
+		// Lists and dictionaries
+		"list":           list,
+		"first":          first,
+		"last":           last,
+		"append":         push,
+		"has":            has,
+		"uniq":           uniq,
+		"dict":           dict,
+		"get":            get,
+		"set":            set,
+		"unset":          unset,
+		"hasKey":         hasKey,
+		"keys":           keys,
+		"merge":          merge,
+		"mergeOverwrite": mergeOverwrite,
+
+		// Defaults
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"required": required,
+		"ternary":  ternary,
+		"fail":     fail,
+
+		// Type conversions
+		"toString":  toString,
+		"toStrings": toStrings,
+		"atoi":      atoi,
+		"int":       toInt,
+		"int64":     toInt64,
+		"float64":   toFloat64,
+
+		// Versions, hashes, and dates
+		"semverCompare": semverCompare,
+		"sha256sum":     sha256sum,
+		"date":          date,
+
+		// Encodings
+		"toYaml":   toYaml,
+		"fromYaml": fromYaml,
+		"toJson":   toJson,
+		"fromJson": fromJson,
+
+		// Templates
+		"include": include,
+		"tpl":     tpl,
+	}
+	if options.Now != nil {
+		funcs["now"] = options.Now
+	}
+	if options.Rand != nil {
+		funcs["randAlphaNum"] = func(n int) string { return randAlphaNum(options.Rand, n) }
+	}
+	return funcs
+}
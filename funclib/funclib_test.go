@@ -0,0 +1,285 @@
+package funclib_test
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/funclib"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+var data = map[string]interface{}{
+	"name":     "web",
+	"ports":    []int{80, 443},
+	"labels":   map[string]interface{}{"app": "web", "tier": "frontend"},
+	"version":  "1.21.3",
+	"snippet":  "{{ .name | upper }}",
+	"verbatim": "a: \"{{ .name }}\"",
+	"config":   "replicas: {{ len .ports }}\nname: {{ include \"name\" . }}",
+	"bad":      "x: {{ fail \"boom\" }}",
+	"unknown":  "x: {{ nope }}",
+	"said":     "he said \"hi\"\nnext",
+	"hash":     "{{ sha256sum .name }}",
+}
+
+func render(t *testing.T, funcs map[string]interface{}, source string) (string, error) {
+	t.Helper()
+
+	f, err := parser.ParseBytesFuncs([]byte(source), 0, funcs)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := (&executor.Executor{Funcs: funcs}).Render(&buf, f, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func TestFuncs(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "name: {{ .name | upper | quote }}\ntitle: {{ title \"hello, world\" }}\n",
+			expect: "name: \"WEB\"\ntitle: Hello, World\n",
+		},
+		{
+			source: "a: {{ trunc 3 .name }}\nb: {{ replace \"e\" \"E\" .name }}\nc: {{ b64enc .name }}\nd: {{ trimSuffix \"b\" .name }}\n",
+			expect: "a: web\nb: wEb\nc: d2Vi\nd: we\n",
+		},
+		{
+			source: "joined: {{ join \",\" .ports }}\nsplit: {{ splitList \",\" \"a,b\" }}\n",
+			expect: "joined: 80,443\nsplit:\n  - a\n  - b\n",
+		},
+		{
+			source: "keys: {{ keys .labels }}\nhas: {{ hasKey .labels \"app\" }}\nfirst: {{ first .ports }}\nlast: {{ last .ports }}\n",
+			expect: "keys:\n  - app\n  - tier\nhas: true\nfirst: 80\nlast: 443\n",
+		},
+		{
+			source: "merged: {{ merge (dict \"app\" \"api\" \"x\" 1) .labels }}\n",
+			expect: "merged:\n  app: api\n  tier: frontend\n  x: 1\n",
+		},
+		{
+			source: "list: {{ append (list 1 \"a\" 1 | uniq) \"b\" }}\n",
+			expect: "list:\n  - 1\n  - a\n  - b\n",
+		},
+		{
+			source: "a: {{ .missing | default \"x\" }}\nb: {{ coalesce \"\" 0 .name }}\nc: {{ empty .ports }}\nd: {{ ternary 1 2 false }}\n",
+			expect: "a: x\nb: web\nc: false\nd: 2\n",
+		},
+		{
+			source: "a: {{ .said | quote }}\nb: {{ squote \"it's\" }}\nc: --name={{ quote .name }}\nd: {{ quote .name | len }}\ne: {{ quote .name .name }}\n",
+			expect: "a: \"he said \\\"hi\\\"\\nnext\"\nb: 'it''s'\nc: --name=\"web\"\nd: 5\ne: '\"web\" \"web\"'\n",
+		},
+		{
+			source: "a: {{ int \"42\" }}\nb: {{ float64 \"1.5\" }}\nc: {{ toString 42 | quote }}\nd: {{ atoi \"x\" }}\n",
+			expect: "a: 42\nb: 1.5\nc: \"42\"\nd: 0\n",
+		},
+		{
+			source: `image: "{{ .repo | default "nginx" }}:{{ .version }}"` + "\n",
+			expect: `image: "nginx:1.21.3"` + "\n",
+		},
+		{
+			source: "sum: {{ sha256sum \"hello\" }}\n",
+			expect: "sum: 2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\n",
+		},
+		{
+			source: "json: {{ toJson .labels }}\nfrom: {{ fromJson \"[1, 2]\" }}\n",
+			expect: "json: '{\"app\":\"web\",\"tier\":\"frontend\"}'\nfrom:\n  - 1\n  - 2\n",
+		},
+		{
+			source: "yaml: {{ toYaml .labels | quote }}\nfrom: {{ fromYaml \"a: [1, 2]\" }}\n",
+			expect: "yaml: \"app: web\\ntier: frontend\"\nfrom:\n  a:\n    - 1\n    - 2\n",
+		},
+		{
+			source: "from: {{ (fromYaml .verbatim).a | quote }}\n",
+			expect: "from: \"{{ .name }}\"\n",
+		},
+		{
+			source: "{{ define \"name\" }}\n{{ printf \"%s-app\" .name }}\n{{ end }}\nname: {{ include \"name\" . | upper }}\n",
+			expect: "name: WEB-APP\n",
+		},
+		{
+			source: "{{ define \"labels\" }}\napp: {{ .name }}\n{{ end }}\nlabels: {{ include \"labels\" . | quote }}\n",
+			expect: "labels: \"app: web\"\n",
+		},
+		{
+			source: "{{ define \"labels\" }}\napp: {{ .name }}\nports: {{ .ports }}\n{{ end }}\nlabels: {{ include \"labels\" . | toJson }}\n",
+			expect: "labels: '{\"app\":\"web\",\"ports\":[80,443]}'\n",
+		},
+		{
+			source: "name: {{ tpl .snippet . }}\n",
+			expect: "name: WEB\n",
+		},
+		{
+			source: "{{ define \"labels\" }}\napp: {{ .name }}\ntier: web\n{{ end }}\nmetadata:\n  labels: {{ include \"labels\" . }}\n",
+			expect: "metadata:\n  labels:\n    app: web\n    tier: web\n",
+		},
+		{
+			source: "{{ define \"ports\" }}\n{{ range .ports }}\n- {{ . }}\n{{ end }}\n{{ end }}\nports:\n  - 8080\n  - {{ include \"ports\" . }}\n",
+			expect: "ports:\n  - 8080\n  - - 80\n    - 443\n",
+		},
+		{
+			source: "{{ define \"name\" }}\n{{ .name }}\n{{ end }}\n" +
+				"a: {{ printf \"%s-config\" (include \"name\" .) }}\nb: {{ eq (include \"name\" .) \"web\" }}\n" +
+				"c: {{ if include \"name\" . }}yes{{ end }}\nd: {{ include \"name\" . }}-x\ne: {{ include \"name\" . | len }}\n",
+			expect: "a: web-config\nb: true\nc: yes\nd: web-x\ne: 3\n",
+		},
+		{
+			source: "{{ define \"name\" }}\n{{ printf \"%s-app\" .name }}\n{{ end }}\nconfig: {{ tpl .config . }}\n",
+			expect: "config:\n  replicas: 2\n  name: web-app\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			actual, err := render(t, funclib.Map(funclib.Options{}), tt.source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expect {
+				t.Fatalf("unexpected output:\nexpected:\n%s\nactual:\n%s", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestFuncsError(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "name: {{ required \"name is required\" .missing }}\n",
+			expect: "name is required",
+		},
+		{
+			source: "name: {{ fail \"unsupported\" }}\n",
+			expect: "unsupported",
+		},
+		{
+			source: "name: {{ include \"missing\" . }}\n",
+			expect: `template "missing" not defined`,
+		},
+		{
+			source: "name: {{ tpl .bad . }}\n",
+			expect: `[1:7] at <tpl .bad .>: error calling tpl: [1:4] at <fail "boom">: error calling fail: boom`,
+		},
+		{
+			source: "name: {{ tpl .unknown . }}\n",
+			expect: `[1:7] at <tpl .unknown .>: error calling tpl: [1:4] at <nope>: "nope" is not a defined function`,
+		},
+		{
+			source: "name: {{ semverCompare \">= 1.b\" .version }}\n",
+			expect: "invalid version constraint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := render(t, funclib.Map(funclib.Options{}), tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.expect) {
+				t.Fatalf("expected an error containing %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
+
+func TestTplAllowedFuncs(t *testing.T) {
+	funcs := funclib.Map(funclib.Options{})
+	e := executor.Executor{Funcs: funcs, AllowedFuncs: []string{"tpl", "upper"}}
+	for _, tt := range []struct {
+		source string
+		expect string
+	}{
+		{source: "name: {{ tpl .snippet . }}\n", expect: "name: WEB\n"},
+		{source: "name: {{ tpl .hash . }}\n", expect: `"sha256sum" is not an allowed function`},
+		{source: "name: {{ tpl \"{{ b64enc .name }}\" . }}\n", expect: `"b64enc" is not an allowed function`},
+	} {
+		t.Run(tt.source, func(t *testing.T) {
+			f, err := parser.ParseBytesFuncs([]byte(tt.source), 0, funcs)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			var buf bytes.Buffer
+			_, err = e.Render(&buf, f, data)
+			if err == nil {
+				if actual := buf.String(); actual != tt.expect {
+					t.Fatalf("expected %q, got %q", tt.expect, actual)
+				}
+			} else if !strings.Contains(err.Error(), tt.expect) {
+				t.Fatalf("expected an error containing %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expect     bool
+	}{
+		{"1.21.3", "1.21.3", true},
+		{"= 1.21", "1.21.9", true},
+		{"!= 1.21", "1.21.9", false},
+		{">= 1.19", "v1.21.3", true},
+		{">= 1.19, < 1.21", "1.21.3", false},
+		{"> 1.21", "1.21.9", false},
+		{"> 1.21", "1.22.0", true},
+		{"<= 1.21", "1.21.9", true},
+		{"<= 1.21.3", "1.21.4", false},
+		{"~1.21.1", "1.21.9", true},
+		{"~1.21.1", "1.22.0", false},
+		{"^1.2", "1.99.0", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"1.x", "1.5.0", true},
+		{"*", "3.0.0", true},
+		{"1.19 - 1.21", "1.21.8", true},
+		{"1.19 - 1.21", "1.22.0", false},
+		{"< 1.19 || >= 1.21", "1.21.3", true},
+		{">= 1.19", "1.21.3-rc.1", false},
+		{">= 1.19-0", "1.21.3-rc.1", true},
+		{">= 1.21.3-rc.2", "1.21.3-rc.10", true},
+		{">= 1.21.3-rc.2", "1.21.3-alpha", false},
+	}
+	funcs := funclib.Map(funclib.Options{})
+	semverCompare := funcs["semverCompare"].(func(string, string) (bool, error))
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			actual, err := semverCompare(tt.constraint, tt.version)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expect {
+				t.Fatalf("expected %v, got %v", tt.expect, actual)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	funcs := funclib.Map(funclib.Options{})
+	for _, name := range []string{"now", "randAlphaNum"} {
+		if _, ok := funcs[name]; ok {
+			t.Errorf("%s is available without options", name)
+		}
+	}
+
+	funcs = funclib.Map(funclib.Options{
+		Now:  func() time.Time { return time.Date(2001, 12, 15, 2, 59, 43, 0, time.UTC) },
+		Rand: rand.New(rand.NewSource(1)),
+	})
+	actual, err := render(t, funcs, "date: {{ now | date \"2006-01-02\" }}\nid: {{ randAlphaNum 8 | len }}\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expect := "date: 2001-12-15\nid: 8\n"; actual != expect {
+		t.Fatalf("unexpected output:\nexpected:\n%s\nactual:\n%s", expect, actual)
+	}
+}
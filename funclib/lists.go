@@ -0,0 +1,174 @@
+package funclib
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// listval converts a slice or array to a list. It returns an error if the value is not a list.
+func listval(name string, v interface{}) ([]interface{}, error) {
+	if l, ok := v.([]interface{}); ok {
+		return l, nil
+	}
+	l := reflect.ValueOf(v)
+	if l.Kind() != reflect.Slice && l.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s: cannot use %T as a list", name, v)
+	}
+	list := make([]interface{}, l.Len())
+	for i := range list {
+		list[i] = l.Index(i).Interface()
+	}
+	return list, nil
+}
+
+func list(v ...interface{}) []interface{} {
+	return v
+}
+
+// first returns the first element of a list, or nil if the list is empty.
+func first(v interface{}) (interface{}, error) {
+	l, err := listval("first", v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+// last returns the last element of a list, or nil if the list is empty.
+func last(v interface{}) (interface{}, error) {
+	l, err := listval("last", v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+// push returns a copy of a list with an element appended.
+func push(v interface{}, elem interface{}) ([]interface{}, error) {
+	l, err := listval("append", v)
+	if err != nil {
+		return nil, err
+	}
+	return append(l[:len(l):len(l)], elem), nil
+}
+
+// has returns true if a list holds an element equal to needle.
+func has(needle interface{}, v interface{}) (bool, error) {
+	if v == nil {
+		return false, nil
+	}
+	l, err := listval("has", v)
+	if err != nil {
+		return false, err
+	}
+	for _, elem := range l {
+		if reflect.DeepEqual(elem, needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// uniq returns a copy of a list without duplicate elements. The first of each set of equal elements is kept.
+func uniq(v interface{}) ([]interface{}, error) {
+	l, err := listval("uniq", v)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, elem := range l {
+		if ok, _ := has(elem, result); !ok {
+			result = append(result, elem)
+		}
+	}
+	return result, nil
+}
+
+// dict returns a dictionary of its arguments, which alternate between keys and values. Keys are converted to strings.
+// If the number of arguments is odd, the last key maps to the empty string.
+func dict(v ...interface{}) map[string]interface{} {
+	d := make(map[string]interface{}, (len(v)+1)/2)
+	for i := 0; i < len(v); i += 2 {
+		key := strval(v[i])
+		if i+1 >= len(v) {
+			d[key] = ""
+			break
+		}
+		d[key] = v[i+1]
+	}
+	return d
+}
+
+// get returns the value of a key in a dictionary, or the empty string if the key is not present.
+func get(d map[string]interface{}, key string) interface{} {
+	if v, ok := d[key]; ok {
+		return v
+	}
+	return ""
+}
+
+// set sets the value of a key in a dictionary and returns the dictionary.
+func set(d map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	d[key] = value
+	return d
+}
+
+// unset removes a key from a dictionary and returns the dictionary.
+func unset(d map[string]interface{}, key string) map[string]interface{} {
+	delete(d, key)
+	return d
+}
+
+func hasKey(d map[string]interface{}, key string) bool {
+	_, ok := d[key]
+	return ok
+}
+
+// keys returns the sorted keys of one or more dictionaries. Keys that are present in more than one dictionary are
+// listed once for each dictionary.
+func keys(dicts ...map[string]interface{}) []string {
+	var k []string
+	for _, d := range dicts {
+		for key := range d {
+			k = append(k, key)
+		}
+	}
+	sort.Strings(k)
+	return k
+}
+
+// merge merges the source dictionaries into dst and returns dst. Keys that are already present in dst are kept;
+// nested dictionaries are merged recursively.
+func merge(dst map[string]interface{}, srcs ...map[string]interface{}) map[string]interface{} {
+	for _, src := range srcs {
+		mergeInto(dst, src, false)
+	}
+	return dst
+}
+
+// mergeOverwrite is like merge, but the values in later dictionaries replace the values in earlier ones.
+func mergeOverwrite(dst map[string]interface{}, srcs ...map[string]interface{}) map[string]interface{} {
+	for _, src := range srcs {
+		mergeInto(dst, src, true)
+	}
+	return dst
+}
+
+func mergeInto(dst, src map[string]interface{}, overwrite bool) {
+	for key, v := range src {
+		existing, ok := dst[key]
+		if !ok {
+			dst[key] = v
+			continue
+		}
+		d, dok := existing.(map[string]interface{})
+		s, sok := v.(map[string]interface{})
+		switch {
+		case dok && sok:
+			mergeInto(d, s, overwrite)
+		case overwrite:
+			dst[key] = v
+		}
+	}
+}
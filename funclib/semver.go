@@ -0,0 +1,251 @@
+package funclib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A version is a semantic version. Build metadata is discarded.
+type version struct {
+	parts [3]uint64
+	pre   string
+}
+
+// A partialVersion is a version in a constraint. Only the first n parts of the version are specified; the rest are
+// wildcards.
+type partialVersion struct {
+	version
+	n int
+}
+
+// parseVersion parses a partial version. Components that are missing or that are x, X, or * are wildcards. If
+// wildcards are not allowed, missing components are zero.
+func parseVersion(s string, wildcards bool) (partialVersion, error) {
+	text := s
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i != -1 {
+		s = s[:i]
+	}
+
+	var v partialVersion
+	if i := strings.IndexByte(s, '-'); i != -1 {
+		s, v.pre = s[:i], s[i+1:]
+		if v.pre == "" {
+			return partialVersion{}, fmt.Errorf("invalid semantic version %q", text)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return partialVersion{}, fmt.Errorf("invalid semantic version %q", text)
+	}
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			if !wildcards {
+				return partialVersion{}, fmt.Errorf("invalid semantic version %q", text)
+			}
+			break
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return partialVersion{}, fmt.Errorf("invalid semantic version %q", text)
+		}
+		v.parts[i], v.n = n, i+1
+	}
+	if !wildcards {
+		v.n = 3
+	}
+	return v, nil
+}
+
+// compareVersions compares two versions using the precedence rules of semantic versioning.
+func compareVersions(a, b version) int {
+	for i := range a.parts {
+		switch {
+		case a.parts[i] < b.parts[i]:
+			return -1
+		case a.parts[i] > b.parts[i]:
+			return 1
+		}
+	}
+
+	switch {
+	case a.pre == b.pre:
+		return 0
+	case a.pre == "":
+		return 1
+	case b.pre == "":
+		return -1
+	}
+
+	ai, bi := strings.Split(a.pre, "."), strings.Split(b.pre, ".")
+	for i := 0; i < len(ai) && i < len(bi); i++ {
+		an, aerr := strconv.ParseUint(ai[i], 10, 64)
+		bn, berr := strconv.ParseUint(bi[i], 10, 64)
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		default:
+			if c := strings.Compare(ai[i], bi[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(ai) < len(bi):
+		return -1
+	case len(ai) > len(bi):
+		return 1
+	}
+	return 0
+}
+
+// next returns the smallest version that is greater than every version matched by the first n parts of v.
+func (v partialVersion) next(n int) version {
+	var next version
+	copy(next.parts[:n], v.parts[:n])
+	next.parts[n-1]++
+	return next
+}
+
+// A constraint is a single comparison in a version constraint.
+type constraint struct {
+	op      string
+	version partialVersion
+}
+
+// check returns true if v satisfies the constraint.
+func (c constraint) check(v version) bool {
+	// A prerelease version only satisfies constraints that mention a prerelease.
+	if v.pre != "" && c.version.pre == "" && c.op != "!=" {
+		return false
+	}
+
+	lo, n := c.version.version, c.version.n
+	within := func(upper int) bool {
+		return compareVersions(v, lo) >= 0 && (upper == 0 || compareVersions(v, c.version.next(upper)) < 0)
+	}
+
+	switch c.op {
+	case "", "=":
+		if n == 3 {
+			return compareVersions(v, lo) == 0
+		}
+		return within(n)
+	case "!=":
+		if n == 3 {
+			return compareVersions(v, lo) != 0
+		}
+		return !within(n)
+	case ">":
+		switch n {
+		case 0:
+			return false
+		case 3:
+			return compareVersions(v, lo) > 0
+		}
+		return compareVersions(v, c.version.next(n)) >= 0
+	case ">=":
+		return compareVersions(v, lo) >= 0
+	case "<":
+		return n != 0 && compareVersions(v, lo) < 0
+	case "<=":
+		switch n {
+		case 0:
+			return true
+		case 3:
+			return compareVersions(v, lo) <= 0
+		}
+		return compareVersions(v, c.version.next(n)) < 0
+	case "~", "~>":
+		switch n {
+		case 0:
+			return true
+		case 1:
+			return within(1)
+		}
+		return within(2)
+	case "^":
+		switch {
+		case n == 0:
+			return true
+		case n == 1 || lo.parts[0] != 0:
+			return within(1)
+		case n == 2 || lo.parts[1] != 0:
+			return within(2)
+		}
+		return within(3)
+	}
+	return false
+}
+
+var (
+	hyphenRange  = regexp.MustCompile(`(\S+)\s+-\s+(\S+)`)
+	constraintOp = regexp.MustCompile(`^(!=|>=|<=|~>|=|>|<|~|\^)?(.*)$`)
+)
+
+// parseConstraints parses a version constraint. The constraint is a list of alternatives separated by ||. Each
+// alternative is a list of comparisons separated by commas or spaces, all of which must hold.
+func parseConstraints(s string) ([][]constraint, error) {
+	var alternatives [][]constraint
+	for _, alt := range strings.Split(hyphenRange.ReplaceAllString(s, ">= $1, <= $2"), "||") {
+		fields := strings.Fields(strings.Replace(alt, ",", " ", -1))
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q", s)
+		}
+
+		var constraints []constraint
+		for i := 0; i < len(fields); i++ {
+			m := constraintOp.FindStringSubmatch(fields[i])
+			op, text := m[1], m[2]
+			if text == "" && op != "" && i+1 < len(fields) {
+				i, text = i+1, fields[i+1]
+			}
+			v, err := parseVersion(text, true)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %v", s, err)
+			}
+			constraints = append(constraints, constraint{op: op, version: v})
+		}
+		alternatives = append(alternatives, constraints)
+	}
+	return alternatives, nil
+}
+
+// semverCompare returns true if a version satisfies a constraint. Constraints use the syntax of the constraints in
+// Helm charts, e.g. ">= 1.2, < 2.0 || ^3.1".
+func semverCompare(constraints, ver string) (bool, error) {
+	alternatives, err := parseConstraints(constraints)
+	if err != nil {
+		return false, err
+	}
+	v, err := parseVersion(ver, false)
+	if err != nil {
+		return false, err
+	}
+
+	for _, constraints := range alternatives {
+		ok := true
+		for _, c := range constraints {
+			if !c.check(v.version) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
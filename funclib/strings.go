@@ -0,0 +1,204 @@
+package funclib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// strval converts a value to a string. Strings and byte slices are used as is, nodes are converted to their text,
+// errors and Stringers are formatted with their methods, and other values are formatted with fmt.
+func strval(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case ast.Node:
+		return executor.Text(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func upper(s string) string { return strings.ToUpper(s) }
+
+func lower(s string) string { return strings.ToLower(s) }
+
+// title converts the first letter of each word in s to upper case.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) || unicode.IsPunct(prev) {
+			prev = r
+			return unicode.ToTitle(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+func trim(s string) string { return strings.TrimSpace(s) }
+
+func trimAll(cutset, s string) string { return strings.Trim(s, cutset) }
+
+func trimPrefix(prefix, s string) string { return strings.TrimPrefix(s, prefix) }
+
+func trimSuffix(suffix, s string) string { return strings.TrimSuffix(s, suffix) }
+
+func replace(old, new, s string) string { return strings.Replace(s, old, new, -1) }
+
+func contains(substr, s string) bool { return strings.Contains(s, substr) }
+
+func hasPrefix(prefix, s string) bool { return strings.HasPrefix(s, prefix) }
+
+func hasSuffix(suffix, s string) bool { return strings.HasSuffix(s, suffix) }
+
+func repeat(count int, s string) string { return strings.Repeat(s, count) }
+
+// substr returns the bytes of s between start and end. A negative start or end stands for the start or end of s.
+func substr(start, end int, s string) string {
+	if start < 0 || start > len(s) {
+		start = 0
+	}
+	if end < 0 || end > len(s) {
+		end = len(s)
+	}
+	if start > end {
+		return ""
+	}
+	return s[start:end]
+}
+
+// trunc truncates s to n bytes. A negative n keeps the last -n bytes of s instead.
+func trunc(n int, s string) string {
+	switch {
+	case n >= 0 && len(s) > n:
+		return s[:n]
+	case n < 0 && len(s) > -n:
+		return s[len(s)+n:]
+	}
+	return s
+}
+
+// nospace removes all whitespace from s.
+func nospace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// quote returns each non-nil argument quoted with double quotes, separated by spaces. A single argument is returned as
+// a double-quoted scalar, so that an action that consists of it renders as that scalar rather than as a string that
+// contains quotes. The text of the scalar is the quoted argument.
+func quote(args ...interface{}) interface{} {
+	return quoted(args, token.DoubleQuote, func(s string) string { return fmt.Sprintf("%q", s) })
+}
+
+// squote returns each non-nil argument quoted with single quotes, separated by spaces. A single argument is returned
+// as a single-quoted scalar, as by quote.
+func squote(args ...interface{}) interface{} {
+	return quoted(args, token.SingleQuote, func(s string) string { return "'" + s + "'" })
+}
+
+// quoted quotes the non-nil arguments and joins them with spaces. A single argument is returned as a scalar whose token
+// is created by newToken.
+func quoted(args []interface{}, newToken func(value, org string, pos *token.Position) *token.Token, quote func(string) string) interface{} {
+	var values, quoted []string
+	for _, arg := range args {
+		if arg != nil {
+			value := strval(arg)
+			values, quoted = append(values, value), append(quoted, quote(value))
+		}
+	}
+	if len(values) == 1 {
+		return ast.String(newToken(values[0], quoted[0], &token.Position{}))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// cat returns its non-nil arguments separated by spaces.
+func cat(args ...interface{}) string {
+	var strs []string
+	for _, arg := range args {
+		if arg != nil {
+			strs = append(strs, strval(arg))
+		}
+	}
+	return strings.Join(strs, " ")
+}
+
+// indent indents each line of s by the given number of spaces.
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+// nindent is like indent, but starts its result with a line break.
+func nindent(spaces int, s string) string {
+	return "\n" + indent(spaces, s)
+}
+
+func splitList(sep, s string) []string { return strings.Split(s, sep) }
+
+// join joins the elements of a list, converted to strings, with sep.
+func join(sep string, v interface{}) string {
+	return strings.Join(toStrings(v), sep)
+}
+
+func b64enc(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+const alphaNum = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// randAlphaNum returns a string of n random letters and digits.
+func randAlphaNum(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphaNum[r.Intn(len(alphaNum))]
+	}
+	return string(b)
+}
+
+// toStrings converts a list to a list of strings. Nil elements are converted to empty strings; a value that is not
+// a list is converted to a list of one string.
+func toStrings(v interface{}) []string {
+	if v == nil {
+		return nil
+	}
+	if s, ok := v.([]string); ok {
+		return s
+	}
+	l := reflect.ValueOf(v)
+	if l.Kind() != reflect.Slice && l.Kind() != reflect.Array {
+		return []string{strval(v)}
+	}
+	strs := make([]string, l.Len())
+	for i := range strs {
+		if e := l.Index(i).Interface(); e != nil {
+			strs[i] = strval(e)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,23 @@
+package funclib
+
+import (
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+// include evaluates the template with the given name and returns the node it produces. In a value position, the node
+// is spliced into the output; passed to a function that expects a string, it is converted to its text.
+func include(scope *executor.Scope, name string, data interface{}) (ast.Node, error) {
+	return scope.Template(name, data)
+}
+
+// tpl parses text as a template, evaluates it, and returns the node it produces, which is used as by include. The
+// template may call the functions available to the calling template and invoke the templates it defines.
+func tpl(scope *executor.Scope, text string, data interface{}) (ast.Node, error) {
+	f, err := parser.ParseBytesFuncs([]byte(text), 0, scope.Funcs())
+	if err != nil {
+		return nil, err
+	}
+	return scope.Eval(f, data)
+}
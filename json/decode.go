@@ -0,0 +1,250 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+var numberPattern = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?`)
+
+// ParseBytes builds a YAML syntax tree from JSON. Each top-level JSON value in the input becomes a document. The nodes
+// in the tree are flow-style collections and double-quoted strings whose tokens hold their positions in the JSON
+// source, so errors reported against the tree point into the JSON.
+func ParseBytes(bytes []byte) (*ast.File, error) {
+	d := &decoder{src: bytes, line: 1, column: 1}
+	file := &ast.File{Docs: []*ast.DocumentNode{}}
+	for {
+		d.skipSpace()
+		if d.offset == len(d.src) {
+			return file, nil
+		}
+		node, err := d.value()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse JSON")
+		}
+		file.Docs = append(file.Docs, ast.Document(nil, node))
+	}
+}
+
+// ParseFile builds a YAML syntax tree from the named JSON file.
+func ParseFile(filename string) (*ast.File, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file: %s", filename)
+	}
+	f, err := ParseBytes(bytes)
+	if err != nil {
+		return nil, err
+	}
+	f.Name = filename
+	return f, nil
+}
+
+type decoder struct {
+	src    []byte
+	offset int
+	line   int
+	column int
+
+	// space is the offset of the whitespace that precedes the next token. The whitespace is included in the token's
+	// origin.
+	space  int
+	tokens token.Tokens
+}
+
+func (d *decoder) pos() *token.Position {
+	return &token.Position{Line: d.line, Column: d.column, Offset: d.offset}
+}
+
+func (d *decoder) skipSpace() {
+	for ; d.offset < len(d.src); d.offset++ {
+		switch d.src[d.offset] {
+		case ' ', '\t', '\r':
+			d.column++
+		case '\n':
+			d.line, d.column = d.line+1, 1
+		default:
+			return
+		}
+	}
+}
+
+// token creates a token for the next n bytes of the source and advances past them.
+func (d *decoder) token(n int, create func(value, org string, pos *token.Position) *token.Token) *token.Token {
+	value := string(d.src[d.offset : d.offset+n])
+	tk := create(value, string(d.src[d.space:d.offset+n]), d.pos())
+	d.tokens.Add(tk)
+	d.offset, d.column, d.space = d.offset+n, d.column+n, d.offset+n
+	return tk
+}
+
+// errorf reports an error at the next byte of the source.
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	var tk *token.Token
+	if d.offset < len(d.src) {
+		tk = d.token(1, token.String)
+	} else {
+		tk = d.token(0, token.String)
+	}
+	return errors.ErrSyntax(fmt.Sprintf(format, args...), tk)
+}
+
+func (d *decoder) value() (ast.Node, error) {
+	d.skipSpace()
+	if d.offset == len(d.src) {
+		return nil, d.errorf("unexpected end of JSON input")
+	}
+
+	switch c := d.src[d.offset]; {
+	case c == '{':
+		return d.object()
+	case c == '[':
+		return d.array()
+	case c == '"':
+		return d.string()
+	case c == '-' || c >= '0' && c <= '9':
+		n := len(numberPattern.Find(d.src[d.offset:]))
+		if n == 0 {
+			return nil, d.errorf("invalid number")
+		}
+		tk := d.token(n, func(value, org string, pos *token.Position) *token.Token {
+			return token.NewWithSchema(value, org, pos, token.JSONSchema)
+		})
+		if tk.Type == token.FloatType {
+			return ast.Float(tk), nil
+		}
+		node, err := ast.Integer(tk)
+		if err != nil {
+			return nil, errors.ErrSyntax(err.Error(), tk)
+		}
+		return node, nil
+	}
+
+	for _, literal := range []string{"true", "false", "null"} {
+		if d.hasPrefix(literal) {
+			tk := d.token(len(literal), token.New)
+			if tk.Type == token.NullType {
+				return ast.Null(tk), nil
+			}
+			return ast.Bool(tk), nil
+		}
+	}
+	return nil, d.errorf("invalid character %q looking for beginning of value", d.src[d.offset])
+}
+
+func (d *decoder) hasPrefix(literal string) bool {
+	end := d.offset + len(literal)
+	return end <= len(d.src) && string(d.src[d.offset:end]) == literal
+}
+
+// expect consumes the given punctuation, skipping any preceding whitespace.
+func (d *decoder) expect(c byte, create func(org string, pos *token.Position) *token.Token) (*token.Token, error) {
+	d.skipSpace()
+	if d.offset == len(d.src) || d.src[d.offset] != c {
+		if d.offset == len(d.src) {
+			return nil, d.errorf("unexpected end of JSON input")
+		}
+		return nil, d.errorf("invalid character %q, expected %q", d.src[d.offset], c)
+	}
+	return d.token(1, func(_, org string, pos *token.Position) *token.Token {
+		tk := create(org, pos)
+		tk.Origin = org
+		return tk
+	}), nil
+}
+
+// peek returns the next non-whitespace byte of the source, or 0 at the end of the input.
+func (d *decoder) peek() byte {
+	d.skipSpace()
+	if d.offset == len(d.src) {
+		return 0
+	}
+	return d.src[d.offset]
+}
+
+func (d *decoder) object() (ast.Node, error) {
+	start, _ := d.expect('{', token.MappingStart)
+	node := ast.Mapping(start, true)
+	if d.peek() == '}' {
+		node.End, _ = d.expect('}', token.MappingEnd)
+		return node, nil
+	}
+	for {
+		if d.peek() != '"' {
+			return nil, d.errorf("object keys must be strings")
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		colon, err := d.expect(':', func(_ string, pos *token.Position) *token.Token { return token.MappingValue(pos) })
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		node.Values = append(node.Values, ast.MappingValue(colon, key, value))
+
+		if d.peek() == '}' {
+			node.End, _ = d.expect('}', token.MappingEnd)
+			return node, nil
+		}
+		if _, err := d.expect(',', token.CollectEntry); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) array() (ast.Node, error) {
+	start, _ := d.expect('[', token.SequenceStart)
+	node := ast.Sequence(start, true)
+	if d.peek() == ']' {
+		node.End, _ = d.expect(']', token.SequenceEnd)
+		return node, nil
+	}
+	for {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		node.Values = append(node.Values, value)
+
+		if d.peek() == ']' {
+			node.End, _ = d.expect(']', token.SequenceEnd)
+			return node, nil
+		}
+		if _, err := d.expect(',', token.CollectEntry); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *decoder) string() (*ast.StringNode, error) {
+	end := d.offset + 1
+	for ; end < len(d.src); end++ {
+		switch c := d.src[end]; {
+		case c == '\\':
+			end++
+		case c == '"':
+			var value string
+			if err := stdjson.Unmarshal(d.src[d.offset:end+1], &value); err != nil {
+				return nil, d.errorf("invalid string: %v", err)
+			}
+			tk := d.token(end+1-d.offset, func(_, org string, pos *token.Position) *token.Token {
+				return token.DoubleQuote(value, org, pos)
+			})
+			return ast.String(tk), nil
+		case c < ' ':
+			return nil, d.errorf("invalid control character in string")
+		}
+	}
+	return nil, d.errorf("unterminated string")
+}
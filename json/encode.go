@@ -0,0 +1,256 @@
+// Package json converts between YAML syntax trees and JSON.
+package json
+
+import (
+	"bytes"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// An Encoder converts YAML nodes into JSON. Scalars are converted according to their resolved types: nulls, booleans,
+// and numbers are converted to the corresponding JSON values, timestamps are converted to RFC 3339 strings, and binary
+// values are converted to base64-encoded strings. Aliases are replaced with the values of their anchors and tags are
+// ignored. Aliases must be resolved before conversion (see parser.ResolveAliases), which also bounds the size of their
+// expansion, and merge keys must be expanded before conversion (see parser.ExpandMerges).
+//
+// By default, it is an error to convert a mapping with a key that is not a string or to convert NaN or an infinity,
+// as JSON cannot represent these values.
+type Encoder struct {
+	// AllowNonStringKeys converts scalar keys that are not strings to their JSON text, e.g. the key 1 to "1". Keys that
+	// are collections are always an error.
+	AllowNonStringKeys bool
+	// AllowNonFinite converts NaN and infinities to the strings "NaN", "Infinity", and "-Infinity".
+	AllowNonFinite bool
+	// Indent, if non-empty, is used to indent the output. Otherwise, the output is compact.
+	Indent string
+}
+
+// Marshal converts a node into compact JSON using the default encoder.
+func Marshal(node ast.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := (&Encoder{}).Encode(&buf, node); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Encode writes the JSON for a node to w, followed by a newline.
+func (e *Encoder) Encode(w io.Writer, node ast.Node) error {
+	enc := &encoder{options: e}
+	if err := enc.node(node); err != nil {
+		return errors.Wrapf(err, "failed to convert to JSON")
+	}
+	return e.write(w, enc.buf.Bytes())
+}
+
+// EncodeFile writes the JSON for each document in a file to w. Each document is followed by a newline. Directives are
+// ignored.
+func (e *Encoder) EncodeFile(w io.Writer, f *ast.File) error {
+	for _, doc := range f.Docs {
+		if _, ok := doc.Body.(*ast.DirectiveNode); ok {
+			continue
+		}
+		if err := e.Encode(w, doc.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) write(w io.Writer, text []byte) error {
+	if e.Indent != "" {
+		var buf bytes.Buffer
+		if err := stdjson.Indent(&buf, text, "", e.Indent); err != nil {
+			return err
+		}
+		text = buf.Bytes()
+	}
+	_, err := w.Write(append(text, '\n'))
+	return err
+}
+
+type encoder struct {
+	options *Encoder
+	buf     bytes.Buffer
+}
+
+func (e *encoder) string(s string) {
+	var buf bytes.Buffer
+	enc := stdjson.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) //nolint:errcheck
+	e.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+func (e *encoder) float(node ast.Node, f float64) error {
+	switch {
+	case math.IsNaN(f), math.IsInf(f, 0):
+		if !e.options.AllowNonFinite {
+			return errors.ErrSyntax(fmt.Sprintf("cannot convert %s to JSON", node.GetToken().Value), node.GetToken())
+		}
+		switch {
+		case math.IsNaN(f):
+			e.string("NaN")
+		case f > 0:
+			e.string("Infinity")
+		default:
+			e.string("-Infinity")
+		}
+	default:
+		e.buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return nil
+}
+
+// value resolves anchors, aliases, and tags to the nodes they annotate.
+func (e *encoder) value(node ast.Node) (ast.Node, error) {
+	for {
+		switch n := node.(type) {
+		case *ast.AnchorNode:
+			node = n.Value
+		case *ast.AliasNode:
+			if n.Anchor == nil {
+				return nil, errors.ErrSyntax(fmt.Sprintf("unresolved alias %s", n.String()), n.GetToken())
+			}
+			node = n.Anchor.Value
+		case *ast.TagNode:
+			node = n.Value
+		default:
+			return node, nil
+		}
+	}
+}
+
+func (e *encoder) node(node ast.Node) error {
+	node, err := e.value(node)
+	if err != nil {
+		return err
+	}
+
+	switch n := node.(type) {
+	case nil, *ast.NullNode:
+		e.buf.WriteString("null")
+	case *ast.BoolNode:
+		e.buf.WriteString(strconv.FormatBool(n.Value))
+	case *ast.IntegerNode:
+		e.buf.WriteString(fmt.Sprint(n.Value))
+	case *ast.FloatNode:
+		if n.BigValue != nil {
+			e.buf.WriteString(n.BigValue.Text('g', -1))
+			return nil
+		}
+		return e.float(n, n.Value)
+	case *ast.InfinityNode:
+		return e.float(n, n.Value)
+	case *ast.NanNode:
+		return e.float(n, math.NaN())
+	case *ast.StringNode:
+		e.string(n.Value)
+	case *ast.LiteralNode:
+		e.string(n.Value.Value)
+	case *ast.TimestampNode:
+		e.string(n.Value.Format(time.RFC3339Nano))
+	case *ast.BinaryNode:
+		e.string(base64.StdEncoding.EncodeToString(n.Value))
+	case *ast.MappingNode:
+		e.buf.WriteByte('{')
+		for i, mv := range n.Values {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.entry(mv); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte('}')
+	case *ast.MappingValueNode:
+		e.buf.WriteByte('{')
+		if err := e.entry(n); err != nil {
+			return err
+		}
+		e.buf.WriteByte('}')
+	case *ast.SequenceNode:
+		e.buf.WriteByte('[')
+		for i, v := range n.Values {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.node(v); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	case *ast.MergeKeyNode:
+		return errors.ErrSyntax("merge keys must be expanded before conversion to JSON", n.Token)
+	case *ast.ActionNode, *ast.IfNode, *ast.RangeNode, *ast.WithNode, *ast.TemplateInvokeNode, *ast.DefineNode,
+		*ast.TemplateListNode, *ast.InterpolatedStringNode, *ast.TemplateCommentNode, *ast.BreakNode,
+		*ast.ContinueNode:
+		return errors.ErrSyntax("templates must be executed before conversion to JSON", n.GetToken())
+	default:
+		return errors.ErrSyntax(fmt.Sprintf("cannot convert %s to JSON", n.Type()), n.GetToken())
+	}
+	return nil
+}
+
+func (e *encoder) entry(mv *ast.MappingValueNode) error {
+	if mv.Template != nil {
+		return errors.ErrSyntax("templates must be executed before conversion to JSON", mv.Template.GetToken())
+	}
+	if err := e.key(mv.Key, mv.Start); err != nil {
+		return err
+	}
+	e.buf.WriteByte(':')
+	return e.node(mv.Value)
+}
+
+// key writes a mapping key. If the key is null, the error position is given by the mapping value token tk.
+func (e *encoder) key(node ast.Node, tk *token.Token) error {
+	if k, ok := node.(*ast.MappingKeyNode); ok {
+		node = k.Value
+	}
+	node, err := e.value(node)
+	if err != nil {
+		return err
+	}
+
+	switch n := node.(type) {
+	case *ast.StringNode:
+		e.string(n.Value)
+		return nil
+	case *ast.LiteralNode:
+		e.string(n.Value.Value)
+		return nil
+	case *ast.MergeKeyNode:
+		return errors.ErrSyntax("merge keys must be expanded before conversion to JSON", n.Token)
+	case ast.ScalarNode:
+		if !e.options.AllowNonStringKeys {
+			return errors.ErrSyntax(fmt.Sprintf("cannot convert %s key %s to JSON", n.Type(), n.GetToken().Value), n.GetToken())
+		}
+		key := &encoder{options: e.options}
+		if err := key.node(n); err != nil {
+			return err
+		}
+		if text := key.buf.String(); text[0] == '"' {
+			e.buf.WriteString(text)
+		} else {
+			e.string(text)
+		}
+		return nil
+	case nil:
+		if !e.options.AllowNonStringKeys {
+			return errors.ErrSyntax("cannot convert null key to JSON", tk)
+		}
+		e.string("null")
+		return nil
+	}
+	return errors.ErrSyntax(fmt.Sprintf("cannot convert %s key to JSON", node.Type()), node.GetToken())
+}
@@ -0,0 +1,177 @@
+package json_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/json"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{source: "a: 1\nb: -2.5\nc: true\nd: null\ne: ~\nf: x\n", expect: `{"a":1,"b":-2.5,"c":true,"d":null,"e":null,"f":"x"}`},
+		{source: "- 0x1f\n- 0o17\n- 1.0e3\n- '1'\n- \"<&>\"\n", expect: `[31,15,1000,"1","<&>"]`},
+		{source: "a: |\n  x\n  y\nb: >-\n  x\n  y\n", expect: `{"a":"x\ny\n","b":"x y"}`},
+		{source: "a: 123456789012345678901234567890\n", expect: `{"a":123456789012345678901234567890}`},
+		{source: "a: !!timestamp 2001-12-14t21:59:43.10-05:00\n", expect: `{"a":"2001-12-14T21:59:43.1-05:00"}`},
+		{source: "a: !!binary aGVsbG8=\n", expect: `{"a":"aGVsbG8="}`},
+		{source: "a: &x [1, 2]\nb: *x\n", expect: `{"a":[1,2],"b":[1,2]}`},
+		{source: "a: !custom {b: []}\n", expect: `{"a":{"b":[]}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), parser.ParseBigNumbers)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			actual, err := json.Marshal(f.Docs[0].Body)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if string(actual) != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		expect  string
+		allowed string
+	}{
+		{source: "1: a\n", expect: "[1:1] cannot convert Integer key 1 to JSON", allowed: `{"1":"a"}`},
+		{source: "true: a\n", expect: "[1:1] cannot convert Bool key true to JSON", allowed: `{"true":"a"}`},
+		{source: "a: .nan\n", expect: "[1:4] cannot convert .nan to JSON", allowed: `{"a":"NaN"}`},
+		{source: "a: [.inf, -.inf]\n", expect: "[1:5] cannot convert .inf to JSON", allowed: `{"a":["Infinity","-Infinity"]}`},
+		{source: "a:\n  <<: {b: 1}\n", expect: "[2:3] merge keys must be expanded before conversion to JSON"},
+		{source: "a: &x [1]\nb: *x\n", expect: "[2:4] unresolved alias *x"},
+		{source: "a: &x [*x]\n", expect: "[1:8] unresolved alias *x"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			_, err = json.Marshal(f.Docs[0].Body)
+			if err == nil || !strings.Contains(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+			if test.allowed == "" {
+				return
+			}
+
+			var buf bytes.Buffer
+			e := json.Encoder{AllowNonStringKeys: true, AllowNonFinite: true}
+			if err := e.Encode(&buf, f.Docs[0].Body); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := strings.TrimSpace(buf.String()); actual != test.allowed {
+				t.Fatalf("expected: [%s] but got [%s]", test.allowed, actual)
+			}
+		})
+	}
+}
+
+func TestEncodeFile(t *testing.T) {
+	f, err := parser.ParseBytes([]byte("%YAML 1.2\n---\na: [1]\n---\nb\n"), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var buf bytes.Buffer
+	if err := (&json.Encoder{Indent: "  "}).EncodeFile(&buf, f); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := "{\n  \"a\": [\n    1\n  ]\n}\n\"b\"\n"
+	if actual := buf.String(); actual != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, actual)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	source := "{\n  \"a\": [1, -2.5e3, \"x\\ny\"],\n  \"b\": {\"c\": true, \"d\": null}\n}\n[]"
+	f, err := json.ParseBytes([]byte(source))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(f.Docs) != 2 {
+		t.Fatalf("expected 2 documents but got %d", len(f.Docs))
+	}
+
+	m := f.Docs[0].Body.(*ast.MappingNode)
+	a := m.Values[0].Value.(*ast.SequenceNode)
+	if v := a.Values[0].(*ast.IntegerNode).Value; v != uint64(1) {
+		t.Fatalf("unexpected value: %v", v)
+	}
+	if v := a.Values[1].(*ast.FloatNode).Value; v != -2500 {
+		t.Fatalf("unexpected value: %v", v)
+	}
+	if v := a.Values[2].(*ast.StringNode).Value; v != "x\ny" {
+		t.Fatalf("unexpected value: %q", v)
+	}
+	d := m.Values[1].Value.(*ast.MappingNode).Values[1]
+	if _, ok := d.Value.(*ast.NullNode); !ok {
+		t.Fatalf("expected null but got %s", d.Value.Type())
+	}
+
+	positions := []struct {
+		node         ast.Node
+		line, column int
+	}{
+		{node: m, line: 1, column: 1},
+		{node: m.Values[0].Key, line: 2, column: 3},
+		{node: a.Values[1], line: 2, column: 12},
+		{node: d.Key, line: 3, column: 20},
+		{node: f.Docs[1].Body, line: 5, column: 1},
+	}
+	for _, p := range positions {
+		pos := p.node.GetToken().Position
+		if pos.Line != p.line || pos.Column != p.column {
+			t.Fatalf("expected %s at %d:%d but got %d:%d", p.node, p.line, p.column, pos.Line, pos.Column)
+		}
+	}
+
+	actual, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if expect := `{"a":[1,-2500,"x\ny"],"b":{"c":true,"d":null}}`; string(actual) != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, actual)
+	}
+}
+
+func TestParseBytesErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{source: `{"a": 1,}`, expect: "[1:9] object keys must be strings"},
+		{source: `{1: 2}`, expect: "[1:2] object keys must be strings"},
+		{source: "[1,\n 2 3]", expect: "[2:4] invalid character '3', expected ','"},
+		{source: `[01]`, expect: "[1:3] invalid character '1', expected ','"},
+		{source: `{"a" 1}`, expect: "[1:6] invalid character '1', expected ':'"},
+		{source: `["a`, expect: "[1:2] unterminated string"},
+		{source: "[\"a\tb\"]", expect: "[1:2] invalid control character in string"},
+		{source: `[tru]`, expect: "[1:2] invalid character 't' looking for beginning of value"},
+		{source: `[1,`, expect: "unexpected end of JSON input"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := json.ParseBytes([]byte(test.source))
+			if err == nil || !strings.Contains(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
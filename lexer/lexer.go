@@ -11,6 +11,18 @@ import (
 func Tokenize(src string) token.Tokens {
 	var s scanner.Scanner
 	s.Init(src)
+	return tokenize(&s)
+}
+
+// TokenizeWithoutTemplates splits plain YAML into tokens. Template actions are not recognized.
+func TokenizeWithoutTemplates(src string) token.Tokens {
+	var s scanner.Scanner
+	s.Init(src)
+	s.DisableTemplates()
+	return tokenize(&s)
+}
+
+func tokenize(s *scanner.Scanner) token.Tokens {
 	var tokens token.Tokens
 	for {
 		subTokens, err := s.Scan()
@@ -8,8 +8,18 @@ type context struct {
 	size   int
 	tokens token.Tokens
 	mode   Mode
+	schema token.Schema
 
-	funcs map[string]interface{}
+	version    string            // the version given by the current document's YAML directive, if any
+	tagHandles map[string]string // the tag handles defined by the current document's TAG directives
+
+	funcs    map[string]interface{}
+	tags     *TagRegistry
+	filename string
+	includes []string // the files that are including the file being parsed, outermost first
+
+	rangeDepth int      // the number of {{range}} actions that enclose the template being parsed
+	vars       []string // the variables in scope in the template that encloses the YAML being parsed, if any
 }
 
 func (c *context) next() bool {
@@ -78,6 +88,17 @@ func (c *context) nextNotCommentToken() *token.Token {
 	return nil
 }
 
+// nextNotCommentIndex returns the index of the next token that is not a comment, or the number of tokens if there is
+// no such token.
+func (c *context) nextNotCommentIndex() int {
+	for i := c.idx + 1; i < c.size; i++ {
+		if c.tokens[i].Type != token.CommentType {
+			return i
+		}
+	}
+	return c.size
+}
+
 func (c *context) afterNextNotCommentToken() *token.Token {
 	notCommentTokenCount := 0
 	for i := c.idx + 1; i < c.size; i++ {
@@ -97,6 +118,34 @@ func (c *context) enabledComment() bool {
 	return c.mode&ParseComments != 0
 }
 
+func (c *context) enabledBigNumbers() bool {
+	return c.mode&ParseBigNumbers != 0
+}
+
+func (c *context) enabledTemplates() bool {
+	return c.mode&ParseNoTemplates == 0
+}
+
+// resolve resolves the type of a plain scalar token using the current schema.
+func (c *context) resolve(tk *token.Token) {
+	if c.schema == token.DefaultSchema {
+		return
+	}
+	switch tk.Type {
+	case token.StringType,
+		token.NullType,
+		token.BoolType,
+		token.IntegerType,
+		token.BinaryIntegerType,
+		token.OctetIntegerType,
+		token.HexIntegerType,
+		token.FloatType,
+		token.InfinityType,
+		token.NanType:
+		tk.Type = c.schema.Resolve(tk.Value)
+	}
+}
+
 func (c *context) isCurrentCommentToken() bool {
 	tk := c.currentToken()
 	if tk == nil {
@@ -137,5 +186,6 @@ func newContext(tokens token.Tokens, mode Mode) *context {
 		size:   len(filteredTokens),
 		tokens: filteredTokens,
 		mode:   mode,
+		schema: mode.schema(),
 	}
 }
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+const (
+	primaryTagHandle   = "!"
+	secondaryTagHandle = "!!"
+	yamlTagPrefix      = "tag:yaml.org,2002:"
+)
+
+var tagHandlePattern = regexp.MustCompile(`^!(?:[0-9A-Za-z-]*!)?$`)
+
+// applyDirective parses the content of a directive into the node's fields and applies the directive to the document
+// that follows it. YAML directives select the document's schema and TAG directives define its tag handles. Other
+// directives are reserved and are ignored.
+func (c *context) applyDirective(node *ast.DirectiveNode) error {
+	s, ok := node.Value.(*ast.StringNode)
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(s.Value)
+	if len(fields) == 0 {
+		return nil
+	}
+	node.Name = fields[0]
+
+	switch node.Name {
+	case "YAML":
+		if len(fields) < 2 {
+			return errors.ErrSyntax("invalid YAML directive: expected a version", s.Token)
+		}
+		if c.version != "" {
+			return errors.ErrSyntax("duplicate YAML directive", s.Token)
+		}
+		node.Version, c.version = fields[1], fields[1]
+		if schema, ok := token.SchemaForVersion(node.Version); ok && c.mode.schema() == token.DefaultSchema {
+			c.schema = schema
+		}
+	case "TAG":
+		if len(fields) < 3 {
+			return errors.ErrSyntax("invalid TAG directive: expected a handle and a prefix", s.Token)
+		}
+		node.Handle, node.Prefix = fields[1], fields[2]
+		if !tagHandlePattern.MatchString(node.Handle) {
+			return errors.ErrSyntax(fmt.Sprintf("invalid tag handle %s", node.Handle), s.Token)
+		}
+		if _, ok := c.tagHandles[node.Handle]; ok {
+			return errors.ErrSyntax(fmt.Sprintf("duplicate TAG directive for handle %s", node.Handle), s.Token)
+		}
+		if c.tagHandles == nil {
+			c.tagHandles = map[string]string{}
+		}
+		c.tagHandles[node.Handle] = node.Prefix
+	}
+	return nil
+}
+
+// resetDirectives discards the effects of the directives that applied to the previous document.
+func (c *context) resetDirectives() {
+	c.schema = c.mode.schema()
+	c.version, c.tagHandles = "", nil
+}
+
+// resolveTag resolves a tag to its full form using the tag handles of the current document, e.g. !!str to
+// tag:yaml.org,2002:str. Verbatim tags (!<...>) and the non-specific tag (!) are returned as-is. It is an error to use
+// a named handle that has not been defined by a TAG directive.
+func (c *context) resolveTag(tk *token.Token) (string, error) {
+	tag := tk.Value
+	switch {
+	case tag == primaryTagHandle:
+		return tag, nil
+	case strings.HasPrefix(tag, "!<") && strings.HasSuffix(tag, ">"):
+		return tag[2 : len(tag)-1], nil
+	}
+
+	var handle, suffix string
+	if i := strings.IndexByte(tag[1:], '!'); i >= 0 {
+		handle, suffix = tag[:i+2], tag[i+2:]
+	} else {
+		handle, suffix = primaryTagHandle, tag[1:]
+	}
+
+	prefix, ok := c.tagHandles[handle]
+	if !ok {
+		switch handle {
+		case primaryTagHandle:
+			prefix = primaryTagHandle
+		case secondaryTagHandle:
+			prefix = yamlTagPrefix
+		default:
+			return "", errors.ErrSyntax(fmt.Sprintf("undefined tag handle %s", handle), tk)
+		}
+	}
+	if unescaped, err := url.PathUnescape(suffix); err == nil {
+		suffix = unescaped
+	}
+	return prefix + suffix, nil
+}
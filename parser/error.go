@@ -17,7 +17,7 @@ func FormatError(e error, colored, inclSource bool) string {
 	var pp errors.PrettyPrinter
 	if xerrors.As(e, &pp) {
 		var buf bytes.Buffer
-		pp.PrettyPrint(&errors.Sink{&buf}, colored, inclSource)
+		pp.PrettyPrint(&errors.Sink{Buffer: &buf}, colored, inclSource)
 		return buf.String()
 	}
 
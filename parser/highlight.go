@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// templateItemKinds maps the types of lex items to the kinds of template items used by the printer. Keywords that are
+// not listed are printer.TemplateKeyword.
+var templateItemKinds = map[itemType]printer.TemplateItemKind{
+	itemBool:         printer.TemplateConstant,
+	itemNil:          printer.TemplateConstant,
+	itemChar:         printer.TemplateOperator,
+	itemCharConstant: printer.TemplateNumber,
+	itemComplex:      printer.TemplateNumber,
+	itemNumber:       printer.TemplateNumber,
+	itemAssign:       printer.TemplateOperator,
+	itemDeclare:      printer.TemplateOperator,
+	itemPipe:         printer.TemplateOperator,
+	itemLeftParen:    printer.TemplateOperator,
+	itemRightParen:   printer.TemplateOperator,
+	itemField:        printer.TemplateField,
+	itemDot:          printer.TemplateField,
+	itemVariable:     printer.TemplateVariable,
+	itemIdentifier:   printer.TemplateFunction,
+	itemLeftDelim:    printer.TemplateDelim,
+	itemRightDelim:   printer.TemplateDelim,
+	itemString:       printer.TemplateString,
+	itemRawString:    printer.TemplateString,
+	itemSpace:        printer.TemplateText,
+}
+
+// LexTemplate splits the text of a template action into items for syntax highlighting (see printer.LexTemplate).
+// Text that the lexer skips or cannot scan is returned as TemplateText, or as TemplateComment if it holds a comment.
+func LexTemplate(text string) []printer.TemplateItem {
+	l := lex("", token.Template(text, text, &token.Position{}), leftDelim, rightDelim)
+	defer l.drain()
+
+	var items []printer.TemplateItem
+	offset := 0
+	add := func(kind printer.TemplateItemKind, end int) {
+		if end > offset {
+			items = append(items, printer.TemplateItem{Kind: kind, Text: text[offset:end]})
+			offset = end
+		}
+	}
+	skip := func(end int) {
+		if strings.Contains(text[offset:end], leftComment) {
+			add(printer.TemplateComment, end)
+		} else {
+			add(printer.TemplateText, end)
+		}
+	}
+
+	for {
+		it := l.nextItem()
+		if it.typ == itemEOF || it.typ == itemError {
+			skip(len(text))
+			return items
+		}
+		if it.typ == itemComment {
+			// The delimiters around a comment are highlighted with it.
+			add(printer.TemplateComment, len(text))
+			return items
+		}
+		skip(int(it.pos))
+
+		kind, ok := templateItemKinds[it.typ]
+		if !ok && it.typ > itemKeyword {
+			kind = printer.TemplateKeyword
+		}
+		add(kind, int(it.pos)+len(it.val))
+	}
+}
@@ -0,0 +1,522 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/scanner"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// parseInterpolation parses a scalar with embedded template actions, e.g. `app-{{ .env }}` or
+// `"{{ .repo }}:{{ .tag }}"`, into an interpolated string. It returns nil if the scalar that starts at the current
+// token is not interpolated.
+func (p *parser) parseInterpolation(ctx *context, tk *token.Token) (ast.Node, error) {
+	if !ctx.enabledTemplates() {
+		return nil, nil
+	}
+	var segments []*token.Token
+	style := token.StringType
+	switch tk.Type {
+	case token.SingleQuoteType:
+		// Skip the opening quote.
+		pos := *tk.Position
+		pos.Column, pos.Offset = pos.Column+1, pos.Offset+1
+		segments, style = splitActions(tk.Value, &pos, false), tk.Type
+	case token.DoubleQuoteType:
+		// Split the source text between the quotes rather than the value of the scalar, so that escape sequences are
+		// decoded once per segment and never form delimiters.
+		pos := *tk.Position
+		pos.Column, pos.Offset = pos.Column+1, pos.Offset+1
+		content := tk.Origin[strings.IndexByte(tk.Origin, '"')+1 : strings.LastIndexByte(tk.Origin, '"')]
+		segments, style = splitActions(content, &pos, true), tk.Type
+	default:
+		run := interpolationRun(ctx)
+		if run == nil {
+			return nil, nil
+		}
+		segments = plainSegments(run)
+		ctx.progressIgnoreComment(len(run) - 1)
+	}
+	if segments == nil {
+		return nil, nil
+	}
+	if style != token.StringType {
+		if err := p.checkQuotedScalarEnd(tk); err != nil {
+			return nil, err
+		}
+	}
+
+	node, err := p.parseSegments(ctx, tk, style, trimSegments(segments))
+	if err != nil {
+		return nil, err
+	}
+	if p.isSameLineComment(ctx.nextToken(), node) {
+		ctx.progress(1)
+		if err := p.setSameLineCommentIfExists(ctx, node); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// parseTemplateKey parses a mapping key with embedded template actions. A key that consists of a single template is
+// parsed as that template, e.g. an action for `{{ .name }}: value`; any other key is parsed as an interpolated string.
+// On return, the current token is the last token of the key.
+func (p *parser) parseTemplateKey(ctx *context, run []*token.Token) (ast.Node, error) {
+	node, err := p.parseSegments(ctx, run[0], token.StringType, trimSegments(plainSegments(run)))
+	if err != nil {
+		return nil, err
+	}
+	ctx.progressIgnoreComment(len(run) - 1)
+	if len(node.Parts.Nodes) == 1 && node.Parts.Nodes[0].Type() != ast.StringType {
+		return node.Parts.Nodes[0], nil
+	}
+	return node, nil
+}
+
+// parseBlockInterpolation parses a block scalar with embedded template actions into an interpolated string. The text
+// of the scalar is kept without the block's indentation, and control actions that stand alone on a line take the line
+// with them. It returns nil if the scalar has no actions.
+func (p *parser) parseBlockInterpolation(ctx *context, literal *ast.LiteralNode) (ast.Node, error) {
+	if !ctx.enabledTemplates() {
+		return nil, nil
+	}
+	header := literal.Start
+	origin := literal.Value.Token.Origin
+
+	// The content of the block starts on the line that follows the header.
+	start := strings.Index(header.Origin, header.Value)
+	pos := &token.Position{
+		Line:   header.Position.Line + 1,
+		Column: 1,
+		Offset: header.Position.Offset - start + len(header.Origin),
+	}
+	segments := splitActions(origin, pos, false)
+	if segments == nil {
+		return nil, nil
+	}
+	segments = dedentSegments(trimStandalone(trimSegments(segments)), blockIndent(origin))
+	return p.parseSegments(ctx, header, header.Type, segments)
+}
+
+func (p *parser) parseSegments(ctx *context, tk *token.Token, style token.Type, segments []*token.Token) (*ast.InterpolatedStringNode, error) {
+	treeSet := make(map[string]*templateContext)
+	t := newTemplateContext("template", interpolatedTemplate)
+	if _, err := t.ParseSegments(segments, ctx, treeSet, ctx.funcs, builtins); err != nil {
+		return nil, err
+	}
+	return ast.InterpolatedString(tk, style, t.root), nil
+}
+
+// isPlainScalar returns true if the token is a plain scalar.
+func isPlainScalar(tk *token.Token) bool {
+	switch tk.Type {
+	case token.StringType,
+		token.NullType,
+		token.BoolType,
+		token.IntegerType,
+		token.BinaryIntegerType,
+		token.OctetIntegerType,
+		token.HexIntegerType,
+		token.FloatType,
+		token.InfinityType,
+		token.NanType:
+		return true
+	}
+	return false
+}
+
+// templateKeyword returns the keyword that begins the action in a template token, if any.
+func templateKeyword(tk *token.Token) string {
+	text := strings.TrimPrefix(tk.Value, leftDelim)
+	text = strings.TrimLeft(strings.TrimPrefix(text, "-"), spaceChars)
+	if end := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }); end >= 0 {
+		text = text[:end]
+	}
+	return text
+}
+
+// interpolationRun returns the tokens of the plain scalar that starts at the current token if the scalar embeds
+// template actions. The scanner splits such a scalar into a run of plain scalar and template tokens on a single line.
+// Control actions in the run must be closed within the run; a run that consists of a single control action and its
+// body is an ordinary template rather than an interpolated scalar. Mapping keys are parsed by parseMapKey.
+func interpolationRun(ctx *context) []*token.Token {
+	// Anchor and alias names are never interpolated.
+	if prev := ctx.previousToken(); prev != nil && (prev.Type == token.AnchorType || prev.Type == token.AliasType) {
+		return nil
+	}
+
+	run, closed := scalarRun(ctx, ctx.idx)
+	if len(run) < 2 || run[0].Type == token.TemplateType && closed == len(run) {
+		return nil
+	}
+	if run[len(run)-1].NextType() == token.MappingValueType {
+		return nil
+	}
+	if !hasTemplate(run) {
+		return nil
+	}
+	return run
+}
+
+// keyRun returns the tokens of the mapping key that starts at the given index if the key embeds template actions,
+// e.g. `{{ .name }}: value` or `app-{{ .env }}: value`. It returns nil if the tokens at the index are not such a key.
+func keyRun(ctx *context, idx int) []*token.Token {
+	run, _ := scalarRun(ctx, idx)
+	if len(run) == 0 || run[len(run)-1].NextType() != token.MappingValueType || !hasTemplate(run) {
+		return nil
+	}
+	return run
+}
+
+// scalarRun returns the longest run of plain scalar and template tokens on a single line that starts at the given
+// index and whose control actions are balanced. The run ends at a token that is followed by a ':', if any. closed is
+// the length of the shortest balanced prefix of the run.
+func scalarRun(ctx *context, idx int) (run []*token.Token, closed int) {
+	depth, balanced := 0, 0
+scan:
+	for i := idx; i < ctx.size; i++ {
+		tk := ctx.tokens[i]
+		if len(run) != 0 {
+			prev := run[len(run)-1]
+			if tk.Position.Line != prev.Position.Line+strings.Count(prev.Value, "\n") {
+				break
+			}
+		}
+		if tk.Type == token.TemplateType {
+			switch templateKeyword(tk) {
+			case "if", "range", "with", "define", "block":
+				depth++
+			case "else":
+				if depth == 0 {
+					break scan
+				}
+			case "end":
+				if depth == 0 {
+					break scan
+				}
+				depth--
+			}
+		} else if !isPlainScalar(tk) {
+			break
+		}
+
+		run = append(run, tk)
+		if depth == 0 {
+			balanced = len(run)
+			if closed == 0 {
+				closed = len(run)
+			}
+		}
+		if tk.NextType() == token.MappingValueType {
+			break
+		}
+	}
+	return run[:balanced], closed
+}
+
+// hasTemplate returns true if any of the tokens is a template token.
+func hasTemplate(tokens []*token.Token) bool {
+	for _, tk := range tokens {
+		if tk.Type == token.TemplateType {
+			return true
+		}
+	}
+	return false
+}
+
+// plainSegments returns the segments of a plain scalar. The whitespace that separates the tokens of the scalar is
+// part of its text.
+func plainSegments(run []*token.Token) []*token.Token {
+	var segments []*token.Token
+	for i, tk := range run {
+		text := tk.Origin
+		if tk.Type == token.TemplateType {
+			if start := strings.Index(tk.Origin, tk.Value); start >= 0 {
+				text = tk.Origin[:start]
+			} else {
+				text = ""
+			}
+		}
+		if i == 0 {
+			text = strings.TrimLeft(text, spaceChars)
+		}
+		if i == len(run)-1 && tk.Type != token.TemplateType {
+			text = strings.TrimRight(text, spaceChars)
+		}
+		if text != "" {
+			segments = append(segments, token.String(text, text, tk.Position))
+		}
+		if tk.Type == token.TemplateType {
+			segments = append(segments, tk)
+		}
+	}
+	return segments
+}
+
+// splitActions splits the value of a quoted or block scalar into literal text and template actions. The positions of
+// the segments are computed relative to the given position of the start of the value. If escaped is true, the value
+// is the source text of a double-quoted scalar: the value of each text segment is its text with escape sequences
+// decoded, and its origin is its source text. Actions are template source and are never decoded. It returns nil if
+// the value has no actions.
+func splitActions(value string, pos *token.Position, escaped bool) []*token.Token {
+	text := func(text string) *token.Token {
+		if escaped {
+			return token.String(scanner.UnescapeDoubleQuoted(text), text, pos)
+		}
+		return token.String(text, text, pos)
+	}
+
+	var segments []*token.Token
+	hasActions := false
+	for len(value) != 0 {
+		start := actionStart(value, escaped)
+		if start < 0 {
+			break
+		}
+		end := actionEnd(value[start:])
+		if end < 0 {
+			break
+		}
+		end += start
+
+		if start != 0 {
+			segments = append(segments, text(value[:start]))
+			pos = advance(pos, value[:start])
+		}
+		action := value[start:end]
+		segments = append(segments, token.Template(action, action, pos))
+		pos = advance(pos, action)
+		value, hasActions = value[end:], true
+	}
+	if !hasActions {
+		return nil
+	}
+	if value != "" {
+		segments = append(segments, text(value))
+	}
+	return segments
+}
+
+// actionStart returns the offset of the first left delimiter in text, or -1 if there is none. If escaped is true, the
+// text is the source text of a double-quoted scalar, and escape sequences are skipped so that an escaped brace never
+// starts a delimiter.
+func actionStart(text string, escaped bool) int {
+	for i := 0; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], leftDelim) {
+			return i
+		}
+		if escaped && text[i] == '\\' {
+			i++
+		}
+	}
+	return -1
+}
+
+// actionEnd returns the offset just past the right delimiter of the action at the start of text, or -1 if the action
+// is not closed. Delimiters inside the action's string literals are skipped.
+func actionEnd(text string) int {
+	for i := len(leftDelim); i < len(text); i++ {
+		switch c := text[i]; c {
+		case '}':
+			if strings.HasPrefix(text[i:], rightDelim) {
+				return i + len(rightDelim)
+			}
+		case '"', '`':
+			for i++; i < len(text) && text[i] != c; i++ {
+				if c == '"' && text[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// advance returns the position that follows the given text.
+func advance(pos *token.Position, text string) *token.Position {
+	next := *pos
+	for _, c := range text {
+		next.Offset++
+		if c == '\n' {
+			next.Line, next.Column = next.Line+1, 1
+		} else {
+			next.Column++
+		}
+	}
+	return &next
+}
+
+// trimSegments applies the trim markers of the actions in an interpolated scalar to the adjacent text: "{{- " trims
+// the whitespace that precedes an action, and " -}}" trims the whitespace that follows it. Empty text is removed.
+func trimSegments(segments []*token.Token) []*token.Token {
+	for i, tk := range segments {
+		if tk.Type != token.TemplateType {
+			continue
+		}
+		if i > 0 && strings.HasPrefix(tk.Value, leftDelim+leftTrimMarker) {
+			if prev := segments[i-1]; prev.Type != token.TemplateType {
+				prev.Value = strings.TrimRight(prev.Value, spaceChars)
+			}
+		}
+		if i < len(segments)-1 && strings.HasSuffix(tk.Value, rightTrimMarker+rightDelim) {
+			if next := segments[i+1]; next.Type != token.TemplateType {
+				next.Value = strings.TrimLeft(next.Value, spaceChars)
+			}
+		}
+	}
+
+	trimmed := segments[:0]
+	for _, tk := range segments {
+		if tk.Type == token.TemplateType || tk.Value != "" {
+			trimmed = append(trimmed, tk)
+		}
+	}
+	return trimmed
+}
+
+// blockIndent returns the indentation of the content of a block scalar: the indentation of its least indented
+// non-empty line.
+func blockIndent(content string) int {
+	indent := -1
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimLeft(line, " ") == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " ")); indent == -1 || n < indent {
+			indent = n
+		}
+	}
+	if indent == -1 {
+		return 0
+	}
+	return indent
+}
+
+// dedentSegments removes the indentation of a block scalar from the text segments of its content. Lines that are
+// shorter than the indentation become empty.
+func dedentSegments(segments []*token.Token, indent int) []*token.Token {
+	dedent := func(line string) string {
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if n > indent {
+			n = indent
+		}
+		return line[n:]
+	}
+
+	for _, tk := range segments {
+		if tk.Type == token.TemplateType {
+			continue
+		}
+		lines := strings.Split(tk.Value, "\n")
+		first := lines[0]
+		for i := range lines {
+			if i == 0 && tk.Position.Column != 1 {
+				continue
+			}
+			lines[i] = dedent(lines[i])
+		}
+		// Text that starts a line now starts after the indentation.
+		tk.Position = advance(tk.Position, first[:len(first)-len(lines[0])])
+		tk.Value = strings.Join(lines, "\n")
+		tk.Origin = tk.Value
+	}
+	return segments
+}
+
+// trimStandalone removes the lines of a block scalar that hold nothing but a control action, e.g. `{{ end }}`: the
+// indentation that precedes the action and the line break that follows it are removed from the adjacent text.
+// Actions with trim markers are left alone, as are actions that share their line with other text or actions.
+func trimStandalone(segments []*token.Token) []*token.Token {
+	standalone := make([]bool, len(segments))
+	for i, tk := range segments {
+		if tk.Type != token.TemplateType || !isControlAction(tk) {
+			continue
+		}
+		// Text without a line break only bounds the line at the start or end of the block.
+		startsLine := i == 0
+		if prev := i - 1; prev >= 0 && segments[prev].Type != token.TemplateType && lineStart(segments[prev].Value) >= 0 {
+			startsLine = prev == 0 || strings.Contains(segments[prev].Value, "\n")
+		}
+		endsLine := i == len(segments)-1
+		if next := i + 1; next < len(segments) && segments[next].Type != token.TemplateType && lineEnd(segments[next].Value) >= 0 {
+			endsLine = next == len(segments)-1 || strings.Contains(segments[next].Value, "\n")
+		}
+		standalone[i] = startsLine && endsLine
+	}
+
+	for i, tk := range segments {
+		if tk.Type == token.TemplateType {
+			continue
+		}
+		start, end := 0, len(tk.Value)
+		if i > 0 && standalone[i-1] {
+			start = lineEnd(tk.Value)
+		}
+		if i < len(segments)-1 && standalone[i+1] {
+			end = lineStart(tk.Value)
+		}
+		if end < start {
+			end = start
+		}
+		tk.Position = advance(tk.Position, tk.Value[:start])
+		tk.Value = tk.Value[start:end]
+	}
+
+	trimmed := segments[:0]
+	for _, tk := range segments {
+		if tk.Type == token.TemplateType || tk.Value != "" {
+			trimmed = append(trimmed, tk)
+		}
+	}
+	return trimmed
+}
+
+// isControlAction returns true if the template token is a control action or a comment without trim markers. Such
+// actions produce no text of their own.
+func isControlAction(tk *token.Token) bool {
+	if strings.HasPrefix(tk.Value, leftDelim+leftTrimMarker) || strings.HasSuffix(tk.Value, rightTrimMarker+rightDelim) {
+		return false
+	}
+	if isTemplateComment(tk) {
+		return true
+	}
+	switch templateKeyword(tk) {
+	case "if", "else", "end", "range", "with", "define", "block", "break", "continue":
+		return true
+	}
+	return false
+}
+
+// isTemplateComment returns true if the token is a template that holds nothing but a comment.
+func isTemplateComment(tk *token.Token) bool {
+	if tk.Type != token.TemplateType {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimPrefix(strings.TrimPrefix(tk.Value, leftDelim), leftTrimMarker), leftComment)
+}
+
+// lineStart returns the offset of the start of the last line of text if the line holds nothing but spaces, or -1
+// otherwise.
+func lineStart(text string) int {
+	start := strings.LastIndex(text, "\n") + 1
+	if strings.Trim(text[start:], " ") != "" {
+		return -1
+	}
+	return start
+}
+
+// lineEnd returns the offset just past the line break that ends the first line of text if the line holds nothing but
+// spaces, or -1 otherwise. The end of the text ends the line if there is no line break.
+func lineEnd(text string) int {
+	end := strings.Index(text, "\n")
+	if end < 0 {
+		end = len(text) - 1
+	}
+	if strings.Trim(text[:end+1], " \n") != "" {
+		return -1
+	}
+	return end + 1
+}
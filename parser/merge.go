@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+)
+
+type mergeExpander struct {
+	done   map[*ast.MappingNode]bool
+	active map[*ast.MappingNode]bool
+}
+
+// ExpandMerges applies the merge keys (`<<`) in each document in the given file. Each merge key is replaced by the
+// entries of the mapping or mappings it refers to. Keys that are explicitly present in a mapping override merged keys,
+// and if a merge key refers to a sequence of mappings, mappings that appear earlier in the sequence take precedence
+// over mappings that appear later.
+//
+// Merged entries are copies of the MappingValueNodes of the source mapping that share its keys and values, so they
+// retain their original source positions. Template entries of the source mapping are merged as well. Merge keys that
+// refer to aliases require that the aliases have been resolved (e.g. by ResolveAliases).
+//
+// An error is returned if the value of a merge key is not a mapping, an alias to a mapping, or a sequence of mappings
+// and aliases to mappings, if it is an unresolved alias, or if it refers to a mapping that contains the merge key.
+func ExpandMerges(f *ast.File) error {
+	e := &mergeExpander{
+		done:   map[*ast.MappingNode]bool{},
+		active: map[*ast.MappingNode]bool{},
+	}
+	for _, doc := range f.Docs {
+		if err := e.expand(doc); err != nil {
+			return errors.Wrapf(err, "failed to expand merge keys")
+		}
+	}
+	return nil
+}
+
+func mergeKey(key ast.Node) string {
+	if scalar, ok := key.(ast.ScalarNode); ok {
+		return fmt.Sprintf("%s:%v", key.Type(), scalar.GetValue())
+	}
+	return key.String()
+}
+
+// sources returns the mappings referred to by the value of a merge key in precedence order.
+func (e *mergeExpander) sources(value ast.Node, allowSequence bool) ([]*ast.MappingNode, error) {
+	switch v := value.(type) {
+	case *ast.MappingNode:
+		return []*ast.MappingNode{v}, nil
+	case *ast.MappingValueNode:
+		return []*ast.MappingNode{ast.Mapping(v.GetToken(), false, v)}, nil
+	case *ast.AnchorNode:
+		return e.sources(v.Value, allowSequence)
+	case *ast.TagNode:
+		return e.sources(v.Value, allowSequence)
+	case *ast.AliasNode:
+		if v.Anchor == nil {
+			return nil, errors.ErrSyntax(fmt.Sprintf("unresolved alias %s", v.String()), v.GetToken())
+		}
+		return e.sources(v.Anchor.Value, false)
+	case *ast.SequenceNode:
+		if allowSequence {
+			var sources []*ast.MappingNode
+			for _, elem := range v.Values {
+				elemSources, err := e.sources(elem, false)
+				if err != nil {
+					return nil, err
+				}
+				sources = append(sources, elemSources...)
+			}
+			return sources, nil
+		}
+	}
+	return nil, errors.ErrSyntax(fmt.Sprintf("cannot merge %s into a mapping", value.Type()), value.GetToken())
+}
+
+func (e *mergeExpander) expandMapping(n *ast.MappingNode) error {
+	if e.done[n] {
+		return nil
+	}
+	e.active[n] = true
+	defer delete(e.active, n)
+
+	seen := map[string]bool{}
+	hasMerge := false
+	for _, value := range n.Values {
+		if err := e.expand(value); err != nil {
+			return err
+		}
+		switch value.Key.(type) {
+		case nil:
+		case *ast.MergeKeyNode:
+			hasMerge = true
+		default:
+			seen[mergeKey(value.Key)] = true
+		}
+	}
+
+	if hasMerge {
+		values := make([]*ast.MappingValueNode, 0, len(n.Values))
+		for _, value := range n.Values {
+			if _, ok := value.Key.(*ast.MergeKeyNode); !ok {
+				values = append(values, value)
+				continue
+			}
+
+			sources, err := e.sources(value.Value, true)
+			if err != nil {
+				return err
+			}
+			for _, source := range sources {
+				if e.active[source] {
+					return errors.ErrSyntax("merge key refers to a mapping that contains it", value.Value.GetToken())
+				}
+				if err := e.expandMapping(source); err != nil {
+					return err
+				}
+				for _, merged := range source.Values {
+					// Template entries have no key and are always merged.
+					if merged.Key != nil {
+						key := mergeKey(merged.Key)
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+					}
+					c := *merged
+					c.BaseNode = &ast.BaseNode{Comment: merged.GetComment()}
+					values = append(values, &c)
+				}
+			}
+		}
+		n.Values = values
+	}
+
+	e.done[n] = true
+	return nil
+}
+
+func (e *mergeExpander) expandList(list *ast.NodeList) error {
+	if list == nil {
+		return nil
+	}
+	for _, n := range list.Nodes {
+		if err := e.expand(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *mergeExpander) expand(node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.DocumentNode:
+		return e.expand(n.Body)
+	case *ast.MappingNode:
+		return e.expandMapping(n)
+	case *ast.MappingValueNode:
+		if n.Template != nil {
+			return e.expand(n.Template)
+		}
+		return e.expand(n.Value)
+	case *ast.MappingKeyNode:
+		return e.expand(n.Value)
+	case *ast.SequenceNode:
+		for _, v := range n.Values {
+			if err := e.expand(v); err != nil {
+				return err
+			}
+		}
+	case *ast.AnchorNode:
+		return e.expand(n.Value)
+	case *ast.TagNode:
+		return e.expand(n.Value)
+	case *ast.IfNode:
+		if err := e.expandList(n.List); err != nil {
+			return err
+		}
+		return e.expandList(n.ElseList)
+	case *ast.RangeNode:
+		if err := e.expandList(n.List); err != nil {
+			return err
+		}
+		return e.expandList(n.ElseList)
+	case *ast.WithNode:
+		if err := e.expandList(n.List); err != nil {
+			return err
+		}
+		return e.expandList(n.ElseList)
+	case *ast.DefineNode:
+		return e.expandList(n.List)
+	case *ast.TemplateListNode:
+		return e.expandList(n.List)
+	}
+	return nil
+}
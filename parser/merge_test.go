@@ -0,0 +1,199 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func TestExpandMerges(t *testing.T) {
+	tests := []struct {
+		source string
+		expect map[string]string
+	}{
+		{
+			source: `
+base: &base
+  a: 1
+  b: 2
+derived:
+  <<: *base
+  b: 3
+`,
+			expect: map[string]string{"a": "1", "b": "3"},
+		},
+		{
+			source: `
+x: &x {a: 1, b: 1}
+y: &y {b: 2, c: 2}
+derived:
+  <<: [*x, *y]
+  c: 3
+`,
+			expect: map[string]string{"a": "1", "b": "1", "c": "3"},
+		},
+		{
+			source: `
+x: &x {a: 1}
+y: &y
+  <<: *x
+  b: 2
+derived:
+  <<: *y
+`,
+			expect: map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			source: `
+derived:
+  <<: {a: 1}
+  b: 2
+`,
+			expect: map[string]string{"a": "1", "b": "2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if err := parser.ExpandMerges(f); err != nil {
+				t.Fatalf("%+v", err)
+			}
+
+			root := f.Docs[0].Body.(*ast.MappingNode)
+			derived := root.Values[len(root.Values)-1].Value.(*ast.MappingNode)
+			if len(derived.Values) != len(test.expect) {
+				t.Fatalf("expected %d keys, got %d: %v", len(test.expect), len(derived.Values), derived)
+			}
+			for _, v := range derived.Values {
+				key, value := v.Key.String(), v.Value.String()
+				if test.expect[key] != value {
+					t.Fatalf("unexpected value for key %v: [%s] != [%s]", key, test.expect[key], value)
+				}
+				if v.Key.GetToken().Position.Line == 0 {
+					t.Fatalf("key %v has no position", key)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandMergesCopiesEntries(t *testing.T) {
+	source := `
+base: &base
+  a: 1
+  {{ if .b }}
+  b: 2
+  {{ end }}
+derived:
+  <<: *base
+  c: 3
+`
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := parser.ExpandMerges(f); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	root := f.Docs[0].Body.(*ast.MappingNode)
+	base := root.Values[0].Value.(*ast.AnchorNode).Value.(*ast.MappingNode)
+	derived := root.Values[1].Value.(*ast.MappingNode)
+	if len(derived.Values) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %v", len(derived.Values), derived)
+	}
+	if _, ok := derived.Values[1].Template.(*ast.IfNode); !ok || derived.Values[1].Key != nil {
+		t.Fatalf("expected a template entry, got %v", derived.Values[1])
+	}
+	for i, v := range derived.Values[:2] {
+		if v == base.Values[i] {
+			t.Fatalf("merged entry %d is the entry of the source mapping", i)
+		}
+		if v.Key != base.Values[i].Key || v.Value != base.Values[i].Value || v.Template != base.Values[i].Template {
+			t.Fatalf("merged entry %d does not share the nodes of the source entry", i)
+		}
+	}
+}
+
+func TestExpandMergesError(t *testing.T) {
+	tests := []struct {
+		source  string
+		resolve func(f *ast.File) error
+		expect  string
+	}{
+		{
+			source: "a:\n  <<: 1\n",
+			expect: `
+[2:6] cannot merge Integer into a mapping
+   1 | a:
+>  2 |   <<: 1
+            ^
+`,
+		},
+		{
+			source: "a:\n  <<: [[{b: 1}]]\n",
+			expect: `
+[2:7] cannot merge Sequence into a mapping
+   1 | a:
+>  2 |   <<: [[{b: 1}]]
+             ^
+`,
+		},
+		{
+			source:  "a:\n  <<: *x\n",
+			resolve: func(f *ast.File) error { return nil },
+			expect: `
+[2:6] unresolved alias *x
+   1 | a:
+>  2 |   <<: *x
+            ^
+`,
+		},
+		{
+			// ResolveAliases rejects this document, so link the alias by hand.
+			source: "a: &a {<<: *a}\n",
+			resolve: func(f *ast.File) error {
+				alias := ast.FilterFile(ast.AliasType, f)[0].(*ast.AliasNode)
+				alias.Anchor = ast.FilterFile(ast.AnchorType, f)[0].(*ast.AnchorNode)
+				return nil
+			},
+			expect: `
+[1:11] merge key refers to a mapping that contains it
+>  1 | a: &a{<<: *a}
+                 ^
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			resolve := test.resolve
+			if resolve == nil {
+				resolve = func(f *ast.File) error { return parser.ResolveAliases(f, parser.AliasLimits{}) }
+			}
+			if err := resolve(f); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			err = parser.ExpandMerges(f)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if actual := "\n" + err.Error(); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"strings"
 
 	"github.com/pgavlin/yomlette/ast"
@@ -62,11 +63,13 @@ func (p *parser) parseSequence(ctx *context) (ast.Node, error) {
 func (p *parser) parseTag(ctx *context) (ast.Node, error) {
 	tagToken := ctx.currentToken()
 	node := ast.Tag(tagToken)
+	uri, err := ctx.resolveTag(tagToken)
+	if err != nil {
+		return nil, err
+	}
+	node.URI = uri
 	ctx.progress(1) // skip tag token
-	var (
-		value ast.Node
-		err   error
-	)
+	var value ast.Node
 	switch token.ReservedTagKeyword(tagToken.Value) {
 	case token.MappingTag,
 		token.OrderedMapTag:
@@ -74,15 +77,22 @@ func (p *parser) parseTag(ctx *context) (ast.Node, error) {
 	case token.IntegerTag,
 		token.FloatTag,
 		token.StringTag,
-		token.BinaryTag,
-		token.TimestampTag,
 		token.NullTag:
-		typ := ctx.currentToken().Type
-		if typ == token.LiteralType || typ == token.FoldedType {
+		tk := ctx.currentToken()
+		switch tk.Type {
+		case token.LiteralType, token.FoldedType:
 			value, err = p.parseLiteral(ctx)
-		} else {
-			value = p.parseScalarValue(ctx.currentToken())
+		case token.TemplateType:
+			value, err = p.parseToken(ctx, tk)
+		default:
+			if value, err = p.parseInterpolation(ctx, tk); value == nil && err == nil {
+				value, err = p.parseScalarValue(ctx, tk)
+			}
 		}
+	case token.TimestampTag:
+		value, err = p.parseTimestamp(ctx)
+	case token.BinaryTag:
+		value, err = p.parseBinary(ctx)
 	case token.SequenceTag,
 		token.SetTag:
 		err = errors.ErrSyntax(fmt.Sprintf("sorry, currently not supported %s tag", tagToken.Value), tagToken)
@@ -94,6 +104,53 @@ func (p *parser) parseTag(ctx *context) (ast.Node, error) {
 		return nil, errors.Wrapf(err, "failed to parse tag value")
 	}
 	node.Value = value
+	if handler, ok := ctx.tags.Lookup(tagToken.Value); ok {
+		return p.handleTag(ctx, handler, node)
+	}
+	if handler, ok := ctx.tags.Lookup(uri); ok {
+		return p.handleTag(ctx, handler, node)
+	}
+	return node, nil
+}
+
+func (p *parser) parseTimestamp(ctx *context) (ast.Node, error) {
+	tk := ctx.currentToken()
+	switch tk.Type {
+	case token.StringType,
+		token.SingleQuoteType,
+		token.DoubleQuoteType:
+	default:
+		return nil, errors.ErrSyntax("unexpected timestamp value", tk)
+	}
+	node, err := ast.Timestamp(tk)
+	if err != nil {
+		return nil, errors.ErrSyntax(err.Error(), tk)
+	}
+	return node, nil
+}
+
+func (p *parser) parseBinary(ctx *context) (ast.Node, error) {
+	tk := ctx.currentToken()
+	var text ast.Node
+	switch tk.Type {
+	case token.LiteralType,
+		token.FoldedType:
+		literal, err := p.parseLiteral(ctx)
+		if err != nil {
+			return nil, err
+		}
+		text = literal
+	case token.StringType,
+		token.SingleQuoteType,
+		token.DoubleQuoteType:
+		text = ast.String(tk)
+	default:
+		return nil, errors.ErrSyntax("unexpected binary value", tk)
+	}
+	node, err := ast.Binary(text)
+	if err != nil {
+		return nil, errors.ErrSyntax(err.Error(), tk)
+	}
 	return node, nil
 }
 
@@ -126,7 +183,7 @@ func (p *parser) validateMapKey(tk *token.Token) error {
 func (p *parser) createNullToken(base *token.Token) *token.Token {
 	pos := *(base.Position)
 	pos.Column++
-	return token.New("null", "null", &pos)
+	return token.New("null", "", &pos)
 }
 
 func (p *parser) parseMapValue(ctx *context, key ast.Node, colonToken *token.Token) (ast.Node, error) {
@@ -200,6 +257,10 @@ func (p *parser) parseBlockMapping(ctx *context) (*ast.MappingNode, error) {
 }
 
 func (p *parser) continueMapping(ctx *context, node *ast.MappingNode) bool {
+	if idx := ctx.nextNotCommentIndex(); keyRun(ctx, idx) != nil {
+		return ctx.tokens[idx].Position.Column == node.GetToken().Position.Column
+	}
+
 	ntk := ctx.nextNotCommentToken()
 	antk := ctx.afterNextNotCommentToken()
 	if ntk != nil && ntk.Type == token.TemplateType {
@@ -221,8 +282,8 @@ func (p *parser) parseMappingValue(ctx *context) (*ast.MappingValueNode, error)
 		comment = c
 	}
 
-	if tk := ctx.currentToken(); tk.Type == token.TemplateType {
-		template, err := p.parseTemplate(ctx, true)
+	if tk := ctx.currentToken(); tk.Type == token.TemplateType && keyRun(ctx, ctx.idx) == nil {
+		template, err := p.parseTemplate(ctx, mappingValueTemplate)
 		if err != nil {
 			return nil, err
 		}
@@ -267,30 +328,62 @@ func (p *parser) parseMappingValue(ctx *context) (*ast.MappingValueNode, error)
 	return mv, nil
 }
 
+// parseSequenceEntry parses a block sequence. Templates between the entries of the sequence that produce further
+// entries, e.g. a {{range}} whose body is a list of entries, are parsed as part of the sequence; in that case the
+// result is a template list of the sequences and templates in order.
 func (p *parser) parseSequenceEntry(ctx *context) (ast.Node, error) {
 	tk := ctx.currentToken()
-	sequenceNode := ast.Sequence(tk, false)
 	curColumn := tk.Position.Column
-	for tk.Type == token.SequenceEntryType {
-		ctx.progress(1) // skip sequence token
-		value, err := p.parseToken(ctx, ctx.currentToken())
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse sequence")
+
+	var sequenceNode *ast.SequenceNode
+	list := ast.List()
+	for {
+		if tk.Type == token.TemplateType {
+			template, err := p.parseTemplate(ctx, sequenceEntryTemplate)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse sequence")
+			}
+			list.Append(template)
+			sequenceNode = nil
+		} else {
+			if sequenceNode == nil {
+				sequenceNode = ast.Sequence(tk, false)
+				list.Append(sequenceNode)
+			}
+			value, err := p.parseSequenceValue(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse sequence")
+			}
+			sequenceNode.Values = append(sequenceNode.Values, value)
 		}
-		sequenceNode.Values = append(sequenceNode.Values, value)
+
 		tk = ctx.nextNotCommentToken()
-		if tk == nil {
-			break
-		}
-		if tk.Type != token.SequenceEntryType {
+		if tk == nil || tk.Position.Column != curColumn {
 			break
 		}
-		if tk.Position.Column != curColumn {
+		if tk.Type != token.SequenceEntryType && (tk.Type != token.TemplateType || !p.continueSequence(ctx, curColumn)) {
 			break
 		}
 		ctx.progressIgnoreComment(1)
 	}
-	return sequenceNode, nil
+
+	if len(list.Nodes) == 1 && sequenceNode != nil {
+		return sequenceNode, nil
+	}
+	return ast.TemplateList(list.Nodes[0].GetToken(), list), nil
+}
+
+// parseSequenceValue parses the value of a single sequence entry. The current token is the entry's "-".
+func (p *parser) parseSequenceValue(ctx *context) (ast.Node, error) {
+	ctx.progress(1) // skip sequence token
+	return p.parseToken(ctx, ctx.currentToken())
+}
+
+// continueSequence returns true if the next token is a template whose body starts with a sequence entry in the given
+// column.
+func (p *parser) continueSequence(ctx *context, column int) bool {
+	tbody, _ := p.peekTemplateBody(ctx, true)
+	return tbody != nil && tbody.Type == token.SequenceEntryType && tbody.Position.Column == column
 }
 
 func (p *parser) parseAnchor(ctx *context) (ast.Node, error) {
@@ -337,7 +430,14 @@ func (p *parser) parseAlias(ctx *context) (ast.Node, error) {
 
 func (p *parser) parseMapKey(ctx *context) (ast.Node, error) {
 	tk := ctx.currentToken()
-	if value := p.parseScalarValue(tk); value != nil {
+	if run := keyRun(ctx, ctx.idx); run != nil {
+		return p.parseTemplateKey(ctx, run)
+	}
+	value, err := p.parseScalarValue(ctx, tk)
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
 		return value, nil
 	}
 	switch tk.Type {
@@ -359,10 +459,24 @@ func (p *parser) parseStringValue(tk *token.Token) ast.Node {
 	return nil
 }
 
+// parseImplicitTimestamp returns a timestamp node for a plain scalar that is a timestamp if the current schema is the
+// YAML 1.1 schema, which resolves timestamps implicitly. The other schemas only resolve timestamps that are explicitly
+// tagged.
+func (p *parser) parseImplicitTimestamp(ctx *context, tk *token.Token) ast.Node {
+	if ctx.schema != token.YAML11Schema || tk.Type != token.StringType {
+		return nil
+	}
+	node, err := ast.Timestamp(tk)
+	if err != nil {
+		return nil
+	}
+	return node
+}
+
 func (p *parser) parseScalarValueWithComment(ctx *context, tk *token.Token) (ast.Node, error) {
-	node := p.parseScalarValue(tk)
-	if node == nil {
-		return nil, nil
+	node, err := p.parseScalarValue(ctx, tk)
+	if node == nil || err != nil {
+		return nil, err
 	}
 	if p.isSameLineComment(ctx.nextToken(), node) {
 		ctx.progress(1)
@@ -373,28 +487,71 @@ func (p *parser) parseScalarValueWithComment(ctx *context, tk *token.Token) (ast
 	return node, nil
 }
 
-func (p *parser) parseScalarValue(tk *token.Token) ast.Node {
+// checkQuotedScalarEnd returns an error if a quoted scalar is followed on the line where it ends by anything other than
+// a comment, a ':', or a flow indicator. A quoted scalar cannot be continued, e.g. by `'x' y` or `'x' {{ .y }}`.
+func (p *parser) checkQuotedScalarEnd(tk *token.Token) error {
+	next := tk.Next
+	if next == nil {
+		return nil
+	}
+	switch next.Type {
+	case token.CommentType,
+		token.MappingValueType,
+		token.CollectEntryType,
+		token.SequenceEndType,
+		token.MappingEndType:
+		return nil
+	}
+	// The scanner does not advance the line number within a quoted scalar, so content on the line where a multi-line
+	// scalar ends shares the scalar's starting line.
+	if next.Position.Line != tk.Position.Line {
+		return nil
+	}
+	return errors.ErrSyntax("unexpected content after quoted scalar", next)
+}
+
+func (p *parser) parseScalarValue(ctx *context, tk *token.Token) (ast.Node, error) {
+	ctx.resolve(tk)
+	if node := p.parseImplicitTimestamp(ctx, tk); node != nil {
+		return node, nil
+	}
 	if node := p.parseStringValue(tk); node != nil {
-		return node
+		if tk.Type == token.SingleQuoteType || tk.Type == token.DoubleQuoteType {
+			if err := p.checkQuotedScalarEnd(tk); err != nil {
+				return nil, err
+			}
+		}
+		return node, nil
 	}
 	switch tk.Type {
 	case token.NullType:
-		return ast.Null(tk)
+		return ast.Null(tk), nil
 	case token.BoolType:
-		return ast.Bool(tk)
+		return ast.Bool(tk), nil
 	case token.IntegerType,
 		token.BinaryIntegerType,
 		token.OctetIntegerType,
 		token.HexIntegerType:
-		return ast.Integer(tk)
+		node, err := ast.Integer(tk)
+		if err != nil {
+			return nil, errors.ErrSyntax(err.Error(), tk)
+		}
+		if _, ok := node.Value.(*big.Int); ok && !ctx.enabledBigNumbers() {
+			return nil, errors.ErrSyntax(fmt.Sprintf("integer %s overflows 64 bits", tk.Value), tk)
+		}
+		return node, nil
 	case token.FloatType:
-		return ast.Float(tk)
+		node := ast.Float(tk).(*ast.FloatNode)
+		if node.BigValue != nil && !ctx.enabledBigNumbers() {
+			return nil, errors.ErrSyntax(fmt.Sprintf("float %s is out of range", tk.Value), tk)
+		}
+		return node, nil
 	case token.InfinityType:
-		return ast.Infinity(tk)
+		return ast.Infinity(tk), nil
 	case token.NanType:
-		return ast.Nan(tk)
+		return ast.Nan(tk), nil
 	}
-	return nil
+	return nil, nil
 }
 
 func (p *parser) parseDirective(ctx *context) (ast.Node, error) {
@@ -405,6 +562,13 @@ func (p *parser) parseDirective(ctx *context) (ast.Node, error) {
 		return nil, errors.Wrapf(err, "failed to parse directive value")
 	}
 	node.Value = value
+	if err := ctx.applyDirective(node); err != nil {
+		return nil, err
+	}
+	if next := ctx.nextToken(); next != nil && next.Type == token.DirectiveType {
+		// further directives for the same document follow
+		return node, nil
+	}
 	ctx.progress(1)
 	tk := ctx.currentToken()
 	if tk == nil {
@@ -430,6 +594,9 @@ func (p *parser) parseLiteral(ctx *context) (ast.Node, error) {
 		return nil, errors.ErrSyntax("unexpected token. required string token", value.GetToken())
 	}
 	node.Value = snode
+	if interpolated, err := p.parseBlockInterpolation(ctx, node); interpolated != nil || err != nil {
+		return interpolated, err
+	}
 	return node, nil
 }
 
@@ -510,9 +677,12 @@ func (p *parser) parseToken(ctx *context, tk *token.Token) (ast.Node, error) {
 	if tk == nil {
 		return nil, nil
 	}
-	if tk.NextType() == token.MappingValueType {
+	if tk.NextType() == token.MappingValueType || keyRun(ctx, ctx.idx) != nil {
 		return p.parseBlockMapping(ctx)
 	}
+	if node, err := p.parseInterpolation(ctx, tk); node != nil || err != nil {
+		return node, err
+	}
 	node, err := p.parseScalarValueWithComment(ctx, tk)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse scalar value")
@@ -558,18 +728,23 @@ func (p *parser) parseToken(ctx *context, tk *token.Token) (ast.Node, error) {
 	case token.LiteralType, token.FoldedType:
 		return p.parseLiteral(ctx)
 	case token.TemplateType:
-		_, antk := p.peekTemplateBody(ctx, false)
+		tbody, antk := p.peekTemplateBody(ctx, false)
 		if antk != nil && antk.Type == token.MappingValueType {
 			return p.parseBlockMapping(ctx)
 		}
-		return p.parseTemplate(ctx, false)
+		if tbody != nil && tbody.Type == token.SequenceEntryType && tbody.Position.Column == tk.Position.Column {
+			return p.parseSequenceEntry(ctx)
+		}
+		return p.parseTemplate(ctx, valueTemplate)
 	}
 	return nil, nil
 }
 
-func (p *parser) parse(tokens token.Tokens, mode Mode) (*ast.File, error) {
-	ctx := newContext(tokens, mode)
-	file := &ast.File{Docs: []*ast.DocumentNode{}}
+func (p *parser) parse(tokens token.Tokens, config *Config, includes []string) (*ast.File, error) {
+	ctx := newContext(tokens, config.Mode)
+	ctx.funcs, ctx.tags = config.Funcs, config.Tags
+	ctx.filename, ctx.includes = config.Filename, includes
+	file := &ast.File{Name: config.Filename, Docs: []*ast.DocumentNode{}}
 	for ctx.next() {
 		node, err := p.parseToken(ctx, ctx.currentToken())
 		if err != nil {
@@ -579,6 +754,10 @@ func (p *parser) parse(tokens token.Tokens, mode Mode) (*ast.File, error) {
 		if node == nil {
 			continue
 		}
+		if _, ok := node.(*ast.DirectiveNode); !ok {
+			// directives only apply to the document that follows them
+			ctx.resetDirectives()
+		}
 		if doc, ok := node.(*ast.DocumentNode); ok {
 			file.Docs = append(file.Docs, doc)
 		} else {
@@ -591,39 +770,124 @@ func (p *parser) parse(tokens token.Tokens, mode Mode) (*ast.File, error) {
 type Mode uint
 
 const (
-	ParseComments Mode = 1 << iota // parse comments and add them to AST
+	ParseComments    Mode = 1 << iota // parse comments and add them to AST
+	ParseBigNumbers                   // represent integers and floats that overflow 64 bits as *big.Int and *big.Float
+	ParseNoTemplates                  // parse plain YAML: template actions are not recognized and scalars are never interpolated
+
+	// Schema modes select the rules used to resolve the types of plain scalars. At most one schema mode should be set.
+	// If no schema mode is set, a %YAML directive selects the schema for the document that follows it: YAML 1.1
+	// documents use the YAML 1.1 schema, and YAML 1.2 documents use the core schema. Otherwise, plain scalars are
+	// resolved using token.DefaultSchema.
+	ParseFailsafeSchema // resolve plain scalars using the YAML 1.2 failsafe schema
+	ParseJSONSchema     // resolve plain scalars using the YAML 1.2 JSON schema
+	ParseCoreSchema     // resolve plain scalars using the YAML 1.2 core schema
+	ParseYAML11Schema   // resolve plain scalars using the YAML 1.1 schema
 )
 
-// ParseBytes parse from byte slice, and returns ast.File
-func ParseBytes(bytes []byte, mode Mode) (*ast.File, error) {
-	tokens := lexer.Tokenize(string(bytes))
-	f, err := Parse(tokens, mode)
+// schema returns the schema selected by the mode.
+func (m Mode) schema() token.Schema {
+	switch {
+	case m&ParseFailsafeSchema != 0:
+		return token.FailsafeSchema
+	case m&ParseJSONSchema != 0:
+		return token.JSONSchema
+	case m&ParseCoreSchema != 0:
+		return token.CoreSchema
+	case m&ParseYAML11Schema != 0:
+		return token.YAML11Schema
+	}
+	return token.DefaultSchema
+}
+
+// Config configures a parse. The zero value parses with no mode flags, no template functions, and no custom tag
+// handlers.
+type Config struct {
+	// Mode holds the parser's mode flags.
+	Mode Mode
+	// Funcs holds additional functions that templates may call.
+	Funcs map[string]interface{}
+	// Tags holds the handlers for custom tags. If Tags is nil, custom tags are parsed as plain tagged nodes.
+	Tags *TagRegistry
+	// Filename is the name of the file being parsed, if any. Tag handlers use it to resolve relative paths.
+	Filename string
+}
+
+// ParseBytes parses YAML from a byte slice.
+func (c *Config) ParseBytes(bytes []byte) (*ast.File, error) {
+	f, err := c.Parse(c.tokenize(bytes))
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse")
 	}
 	return f, nil
 }
 
-// Parse parse from token instances, and returns ast.File
-func Parse(tokens token.Tokens, mode Mode) (*ast.File, error) {
+// tokenize splits source text into tokens. Template actions are only recognized if templates are enabled.
+func (c *Config) tokenize(src []byte) token.Tokens {
+	if c.Mode&ParseNoTemplates != 0 {
+		return lexer.TokenizeWithoutTemplates(string(src))
+	}
+	return lexer.Tokenize(string(src))
+}
+
+// Parse parses YAML from tokens.
+func (c *Config) Parse(tokens token.Tokens) (*ast.File, error) {
+	return c.parse(tokens, nil)
+}
+
+func (c *Config) parse(tokens token.Tokens, includes []string) (*ast.File, error) {
 	var p parser
-	f, err := p.parse(tokens, mode)
+	f, err := p.parse(tokens, c, includes)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse")
 	}
 	return f, nil
 }
 
-// Parse parse from filename, and returns ast.File
-func ParseFile(filename string, mode Mode) (*ast.File, error) {
+// ParseFile parses YAML from the named file. The config's Filename is ignored.
+func (c *Config) ParseFile(filename string) (*ast.File, error) {
+	return c.parseFile(filename, nil)
+}
+
+func (c *Config) parseFile(filename string, includes []string) (*ast.File, error) {
 	file, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read file: %s", filename)
 	}
-	f, err := ParseBytes(file, mode)
+	config := *c
+	config.Filename = filename
+	f, err := config.parse(config.tokenize(file), includes)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse")
 	}
-	f.Name = filename
 	return f, nil
 }
+
+// ParseBytes parse from byte slice, and returns ast.File
+func ParseBytes(bytes []byte, mode Mode) (*ast.File, error) {
+	config := Config{Mode: mode}
+	return config.ParseBytes(bytes)
+}
+
+// Parse parse from token instances, and returns ast.File
+func Parse(tokens token.Tokens, mode Mode) (*ast.File, error) {
+	config := Config{Mode: mode}
+	return config.Parse(tokens)
+}
+
+// ParseBytesFuncs is like ParseBytes, but templates may also call the given functions.
+func ParseBytesFuncs(bytes []byte, mode Mode, funcs map[string]interface{}) (*ast.File, error) {
+	config := Config{Mode: mode, Funcs: funcs}
+	return config.ParseBytes(bytes)
+}
+
+// ParseFuncs is like Parse, but templates may also call the given functions.
+func ParseFuncs(tokens token.Tokens, mode Mode, funcs map[string]interface{}) (*ast.File, error) {
+	config := Config{Mode: mode, Funcs: funcs}
+	return config.Parse(tokens)
+}
+
+// Parse parse from filename, and returns ast.File
+func ParseFile(filename string, mode Mode) (*ast.File, error) {
+	config := Config{Mode: mode}
+	return config.ParseFile(filename)
+}
@@ -22,6 +22,14 @@ const (
 	mappingValueTemplate = 1
 	toplevelTemplate     = 2
 	peekTemplate         = 3
+
+	// sequenceEntryTemplate is a template that produces block sequence entries, e.g. a {{range}} between the entries of
+	// a sequence.
+	sequenceEntryTemplate = 4
+
+	// interpolatedTemplate is a scalar with embedded actions. Its input is a list of segments rather than the parser's
+	// tokens.
+	interpolatedTemplate = 5
 )
 
 type templateContext struct {
@@ -39,17 +47,21 @@ type templateContext struct {
 	peekCount int
 	vars      []string // variables defined at the moment.
 	treeSet   map[string]*templateContext
+	segments  []*token.Token // the remaining segments of an interpolated scalar.
 }
 
-func (p *parser) parseTemplate(ctx *context, mappingValue bool) (ast.Node, error) {
+func (p *parser) parseTemplate(ctx *context, kind int) (ast.Node, error) {
 	tk := ctx.currentToken()
 	if tk.Type != token.TemplateType {
 		return nil, errors.ErrSyntax("expected template token", tk)
 	}
 
 	treeSet := make(map[string]*templateContext)
-	toplevel := tk.Position.Column == 1
-	t := newTemplateContext("template", toplevel, mappingValue, false)
+	toplevel := kind == valueTemplate && tk.Position.Column == 1
+	if toplevel {
+		kind = toplevelTemplate
+	}
+	t := newTemplateContext("template", kind)
 	_, err := t.Parse(tk, leftDelim, rightDelim, ctx, treeSet, ctx.funcs, builtins)
 	if err != nil {
 		return nil, err
@@ -63,8 +75,11 @@ func (p *parser) parseTemplate(ctx *context, mappingValue bool) (ast.Node, error
 	case 1:
 		return t.root.Nodes[0], nil
 	default:
-		// need some way to return a list for toplevel nodes
-		return nil, errors.ErrSyntax("expected exactly one template node", tk)
+		// Only toplevel templates extend past their own action, so only they may produce several nodes.
+		if !toplevel {
+			return nil, errors.ErrSyntax("expected exactly one template node", tk)
+		}
+		return ast.TemplateList(tk, t.root), nil
 	}
 }
 
@@ -80,13 +95,17 @@ func (p *parser) peekTemplateBody(ctx *context, next bool) (*token.Token, *token
 		ctx.progressIgnoreComment(1)
 	}
 
-	// Skip any preceding comments.
-	for ctx.currentToken().Type == token.CommentType {
+	// Skip any preceding comments. Template comments are skipped as well: the body of a template comment is whatever
+	// follows it.
+	for tk := ctx.currentToken(); tk != nil && (tk.Type == token.CommentType || isTemplateComment(tk)); tk = ctx.currentToken() {
 		ctx.progressIgnoreComment(1)
 	}
+	if tk := ctx.currentToken(); tk == nil || tk.Type != token.TemplateType {
+		return tk, ctx.nextNotCommentToken()
+	}
 
 	treeSet := make(map[string]*templateContext)
-	t := newTemplateContext("template", false, false, true)
+	t := newTemplateContext("template", peekTemplate)
 	_, err := t.Parse(ctx.currentToken(), leftDelim, rightDelim, ctx, treeSet, ctx.funcs, builtins)
 	if err == nil {
 		// If we successfully parsed a template, then the template has no body. Ignore it.
@@ -97,8 +116,14 @@ func (p *parser) peekTemplateBody(ctx *context, next bool) (*token.Token, *token
 }
 
 func (t *templateContext) nextNode() item {
+	if t.kind == interpolatedTemplate {
+		return t.nextSegment()
+	}
+
+	// A template that starts a mapping key is part of the next YAML fragment rather than an action of its own.
 	ntk := t.ctx.nextNotCommentToken()
-	if t.kind == peekTemplate && (ntk == nil || ntk.Type != token.TemplateType) {
+	isAction := ntk != nil && ntk.Type == token.TemplateType && keyRun(t.ctx, t.ctx.nextNotCommentIndex()) == nil
+	if t.kind == peekTemplate && !isAction {
 		return item{typ: itemEOF}
 	}
 
@@ -107,8 +132,13 @@ func (t *templateContext) nextNode() item {
 		return item{typ: itemEOF}
 	}
 
-	// If the current token is not a template token, parse the next YAML fragment.
-	if ntk == nil || ntk.Type != token.TemplateType {
+	// If the current token is not a template token, parse the next YAML fragment. The variables in scope here are in
+	// scope in the fragment's templates.
+	if !isAction {
+		vars := t.ctx.vars
+		t.ctx.vars = t.vars
+		defer func() { t.ctx.vars = vars }()
+
 		if t.kind == mappingValueTemplate {
 			node, err := t.p.parseMappingValue(t.ctx)
 			if err != nil {
@@ -116,6 +146,15 @@ func (t *templateContext) nextNode() item {
 			}
 			return item{typ: itemYaml, node: node}
 		}
+		if tk := t.ctx.currentToken(); t.kind == sequenceEntryTemplate && tk.Type == token.SequenceEntryType {
+			value, err := t.p.parseSequenceValue(t.ctx)
+			if err != nil {
+				t.error(err)
+			}
+			seq := ast.Sequence(tk, false)
+			seq.Values = append(seq.Values, value)
+			return item{typ: itemYaml, node: seq}
+		}
 
 		node, err := t.p.parseToken(t.ctx, t.ctx.currentToken())
 		if err != nil {
@@ -134,6 +173,20 @@ func (t *templateContext) nextNode() item {
 	return t.lex.nextItem()
 }
 
+// nextSegment returns the next item of an interpolated scalar. Literal text is returned as a string node.
+func (t *templateContext) nextSegment() item {
+	if len(t.segments) == 0 {
+		return item{typ: itemEOF}
+	}
+	tk := t.segments[0]
+	t.segments = t.segments[1:]
+	if tk.Type != token.TemplateType {
+		return item{typ: itemYaml, node: ast.String(tk)}
+	}
+	t.lex = lex(t.name, tk, t.lex.leftDelim, t.lex.rightDelim)
+	return t.lex.nextItem()
+}
+
 func (t *templateContext) lexNext() item {
 	tk := t.lex.nextItem()
 	if tk.typ != itemEOF {
@@ -202,18 +255,8 @@ func (t *templateContext) peekNonSpace() item {
 
 // Parsing.
 
-// newTemplateContext allocates a new parse tree with the given name.
-func newTemplateContext(name string, toplevel, mappingValue, peek bool, funcs ...map[string]interface{}) *templateContext {
-	kind := valueTemplate
-	switch {
-	case peek:
-		kind = peekTemplate
-	case mappingValue:
-		kind = mappingValueTemplate
-	case toplevel:
-		kind = toplevelTemplate
-	}
-
+// newTemplateContext allocates a new parse tree with the given name and kind.
+func newTemplateContext(name string, kind int, funcs ...map[string]interface{}) *templateContext {
 	return &templateContext{
 		name:  name,
 		kind:  kind,
@@ -277,6 +320,9 @@ func (t *templateContext) startParse(funcs []map[string]interface{}, lex *templa
 	t.ctx = ctx
 	t.lex = lex
 	t.vars = []string{"$"}
+	if len(ctx.vars) != 0 {
+		t.vars = append([]string(nil), ctx.vars...)
+	}
 	t.funcs = funcs
 	t.treeSet = treeSet
 }
@@ -304,6 +350,22 @@ func (t *templateContext) Parse(tk *token.Token, leftDelim, rightDelim string, c
 	return t, nil
 }
 
+// ParseSegments parses the segments of an interpolated scalar.
+func (t *templateContext) ParseSegments(segments []*token.Token, ctx *context, treeSet map[string]*templateContext, funcs ...map[string]interface{}) (tree *templateContext, err error) {
+	defer t.recover(&err)
+	t.parseName = t.name
+	t.segments = segments
+
+	// Start with an exhausted lexer so that the first item comes from the segments.
+	items := make(chan item)
+	close(items)
+	t.startParse(funcs, &templateLexer{name: t.name, leftDelim: leftDelim, rightDelim: rightDelim, items: items, done: true}, ctx, treeSet)
+	t.parse()
+	t.add()
+	t.stopParse()
+	return t, nil
+}
+
 // add adds tree to t.treeSet.
 func (t *templateContext) add() {
 	tree := t.treeSet[t.name]
@@ -316,11 +378,15 @@ func (t *templateContext) add() {
 	}
 }
 
-// parse is the top-level parser for a template, essentially the same
-// as itemList except it also parses {{define}} actions.
-// It runs to EOF.
+// parse is the top-level parser for a template. Toplevel templates and interpolated scalars run to EOF; all other
+// templates consist of a single action.
 func (t *templateContext) parse() {
-	if t.kind != toplevelTemplate {
+	if t.kind != toplevelTemplate && t.kind != interpolatedTemplate {
+		if comment := t.peek(); comment.typ == itemComment {
+			t.next()
+			t.root = ast.List(ast.TemplateComment(comment.tk, comment.val))
+			return
+		}
 		t.expect(itemLeftDelim, "template")
 		n := t.action()
 		switch n.Type() {
@@ -336,17 +402,6 @@ func (t *templateContext) parse() {
 
 	t.root = ast.List()
 	for t.peek().typ != itemEOF {
-		if t.peek().typ == itemLeftDelim {
-			delim := t.next()
-			if t.nextNonSpace().typ == itemDefine {
-				newT := newTemplateContext("definition", true, false, t.kind == peekTemplate) // name will be updated once we know it.
-				newT.parseName = t.parseName
-				newT.startParse(t.funcs, t.lex, t.ctx, t.treeSet)
-				newT.parseDefinition()
-				continue
-			}
-			t.backup2(delim)
-		}
 		switch n := t.textOrAction(); n.Type() {
 		case nodeEnd, nodeElse:
 			t.errorf("unexpected %s", n)
@@ -356,27 +411,6 @@ func (t *templateContext) parse() {
 	}
 }
 
-// parseDefinition parses a {{define}} ...  {{end}} template definition and
-// installs the definition in t.treeSet. The "define" keyword has already
-// been scanned.
-func (t *templateContext) parseDefinition() {
-	const context = "define clause"
-	name := t.expectOneOf(itemString, itemRawString, context)
-	var err error
-	t.name, err = strconv.Unquote(name.val)
-	if err != nil {
-		t.error(err)
-	}
-	t.expect(itemRightDelim, context)
-	var end ast.Node
-	t.root, end = t.itemList()
-	if end.Type() != nodeEnd {
-		t.errorf("unexpected %s in %s", end, context)
-	}
-	t.add()
-	t.stopParse()
-}
-
 // itemList:
 //	textOrAction*
 // Terminates at {{end}} or {{else}}, returned separately.
@@ -404,6 +438,8 @@ func (t *templateContext) textOrAction() ast.Node {
 		return token.node
 	case itemLeftDelim:
 		return t.action()
+	case itemComment:
+		return ast.TemplateComment(token.tk, token.val)
 	default:
 		t.unexpected(token, "input")
 	}
@@ -419,6 +455,12 @@ func (t *templateContext) action() (n ast.Node) {
 	switch token := t.nextNonSpace(); token.typ {
 	case itemBlock:
 		return t.blockControl(token.tk)
+	case itemBreak:
+		return t.breakControl(token.tk)
+	case itemContinue:
+		return t.continueControl(token.tk)
+	case itemDefine:
+		return t.defineControl(token.tk)
 	case itemElse:
 		return t.elseControl()
 	case itemEnd:
@@ -512,7 +554,7 @@ func (t *templateContext) checkPipeline(pipe *ast.PipeNode, context string) {
 	}
 }
 
-func (t *templateContext) parseControl(tk *token.Token, allowElseIf bool, context string) (rtk *token.Token, pipe *ast.PipeNode, list, elseList *ast.NodeList) {
+func (t *templateContext) parseControl(tk *token.Token, context string) (rtk *token.Token, pipe *ast.PipeNode, list, elseList *ast.NodeList) {
 	defer t.popVars(len(t.vars))
 	pipe = t.pipeline(context)
 	var next ast.Node
@@ -520,22 +562,27 @@ func (t *templateContext) parseControl(tk *token.Token, allowElseIf bool, contex
 	switch next.Type() {
 	case nodeEnd: //done
 	case nodeElse:
-		if allowElseIf {
-			// Special case for "else if". If the "else" is followed immediately by an "if",
-			// the elseControl will have left the "if" token pending. Treat
-			//	{{if a}}_{{else if b}}_{{end}}
-			// as
-			//	{{if a}}_{{else}}{{if b}}_{{end}}{{end}}.
-			// To do this, parse the if as usual and stop at it {{end}}; the subsequent{{end}}
-			// is assumed. This technique works even for long if-else-if chains.
-			// TODO: Should we allow else-if in with and range?
-			if t.peek().typ == itemIf {
-				t.next() // Consume the "if" token.
-				elseList = ast.List()
-				elseList.Append(t.ifControl(tk))
-				// Do not consume the next item - only one {{end}} required.
-				break
-			}
+		// Special case for "else if" and "else with". If the "else" is followed immediately by an "if" or "with",
+		// the elseControl will have left the "if" or "with" token pending. Treat
+		//	{{if a}}_{{else if b}}_{{end}}
+		//	{{with a}}_{{else with b}}_{{end}}
+		// as
+		//	{{if a}}_{{else}}{{if b}}_{{end}}{{end}}
+		//	{{with a}}_{{else}}{{with b}}_{{end}}{{end}}.
+		// To do this, parse the "if" or "with" as usual and stop at its {{end}}; the subsequent {{end}} is assumed.
+		// This technique works even for long if-else-if chains.
+		if context == "if" && t.peek().typ == itemIf {
+			t.next() // Consume the "if" token.
+			elseList = ast.List()
+			elseList.Append(t.ifControl(tk))
+			// Do not consume the next item - only one {{end}} required.
+			break
+		}
+		if context == "with" && t.peek().typ == itemWith {
+			t.next() // Consume the "with" token.
+			elseList = ast.List()
+			elseList.Append(t.withControl(tk))
+			break
 		}
 		elseList, next = t.itemList()
 		if next.Type() != nodeEnd {
@@ -550,7 +597,7 @@ func (t *templateContext) parseControl(tk *token.Token, allowElseIf bool, contex
 //	{{if pipeline}} itemList {{else}} itemList {{end}}
 // If keyword is past.
 func (t *templateContext) ifControl(tk *token.Token) ast.Node {
-	return ast.If(t.parseControl(tk, true, "if"))
+	return ast.If(t.parseControl(tk, "if"))
 }
 
 // Range:
@@ -558,15 +605,18 @@ func (t *templateContext) ifControl(tk *token.Token) ast.Node {
 //	{{range pipeline}} itemList {{else}} itemList {{end}}
 // Range keyword is past.
 func (t *templateContext) rangeControl(tk *token.Token) ast.Node {
-	return ast.Range(t.parseControl(tk, false, "range"))
+	t.ctx.rangeDepth++
+	defer func() { t.ctx.rangeDepth-- }()
+	return ast.Range(t.parseControl(tk, "range"))
 }
 
 // With:
 //	{{with pipeline}} itemList {{end}}
 //	{{with pipeline}} itemList {{else}} itemList {{end}}
-// If keyword is past.
+//	{{with pipeline}} itemList {{else with pipeline}} itemList {{end}}
+// With keyword is past.
 func (t *templateContext) withControl(tk *token.Token) ast.Node {
-	return ast.With(t.parseControl(tk, false, "with"))
+	return ast.With(t.parseControl(tk, "with"))
 }
 
 // End:
@@ -581,9 +631,9 @@ func (t *templateContext) endControl() ast.Node {
 //	{{else}}
 // Else keyword is past.
 func (t *templateContext) elseControl() ast.Node {
-	// Special case for "else if".
+	// Special case for "else if" and "else with".
 	peek := t.peekNonSpace()
-	if peek.typ == itemIf {
+	if peek.typ == itemIf || peek.typ == itemWith {
 		// We see "{{else if ... " but in effect rewrite it to {{else}}{{if ... ".
 		return &elseOrEndNode{typ: nodeElse}
 	}
@@ -591,6 +641,32 @@ func (t *templateContext) elseControl() ast.Node {
 	return &elseOrEndNode{typ: nodeElse}
 }
 
+// Break:
+//	{{break}}
+// Break keyword is past.
+func (t *templateContext) breakControl(tk *token.Token) ast.Node {
+	if token := t.nextNonSpace(); token.typ != itemRightDelim {
+		t.unexpected(token, "{{break}}")
+	}
+	if t.ctx.rangeDepth == 0 {
+		t.errorf("{{break}} outside {{range}}")
+	}
+	return ast.Break(tk)
+}
+
+// Continue:
+//	{{continue}}
+// Continue keyword is past.
+func (t *templateContext) continueControl(tk *token.Token) ast.Node {
+	if token := t.nextNonSpace(); token.typ != itemRightDelim {
+		t.unexpected(token, "{{continue}}")
+	}
+	if t.ctx.rangeDepth == 0 {
+		t.errorf("{{continue}} outside {{range}}")
+	}
+	return ast.Continue(tk)
+}
+
 // Block:
 //	{{block stringValue pipeline}}
 // Block keyword is past.
@@ -603,9 +679,16 @@ func (t *templateContext) blockControl(tk *token.Token) ast.Node {
 	name := t.parseTemplateName(token, context)
 	pipe := t.pipeline(context)
 
-	block := newTemplateContext(name, true, false, t.kind == peekTemplate) // name will be updated once we know it.
+	kind := toplevelTemplate
+	if t.kind == peekTemplate {
+		kind = peekTemplate
+	}
+	block := newTemplateContext(name, kind) // name will be updated once we know it.
 	block.parseName = t.parseName
 	block.startParse(t.funcs, t.lex, t.ctx, t.treeSet)
+	rangeDepth := t.ctx.rangeDepth
+	t.ctx.rangeDepth = 0
+	defer func() { t.ctx.rangeDepth = rangeDepth }()
 	var end ast.Node
 	block.root, end = block.itemList()
 	if end.Type() != nodeEnd {
@@ -617,6 +700,26 @@ func (t *templateContext) blockControl(tk *token.Token) ast.Node {
 	return ast.TemplateInvoke(tk, name, pipe)
 }
 
+// Define:
+//	{{define stringValue}} itemList {{end}}
+// Define keyword is past. The body of the definition has its own variable scope.
+func (t *templateContext) defineControl(tk *token.Token) ast.Node {
+	const context = "define clause"
+	token := t.nextNonSpace()
+	name := t.parseTemplateName(token, context)
+	t.expect(itemRightDelim, context)
+
+	vars, rangeDepth := t.vars, t.ctx.rangeDepth
+	t.vars, t.ctx.rangeDepth = []string{"$"}, 0
+	defer func() { t.vars, t.ctx.rangeDepth = vars, rangeDepth }()
+
+	list, end := t.itemList()
+	if end.Type() != nodeEnd {
+		t.errorf("unexpected %s in %s", end, context)
+	}
+	return ast.Define(tk, name, list)
+}
+
 // Template:
 //	{{template stringValue pipeline}}
 // Template keyword is past. The name must be something that can evaluate
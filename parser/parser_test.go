@@ -3,12 +3,16 @@ package parser_test
 import (
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pgavlin/yomlette/ast"
 	"github.com/pgavlin/yomlette/lexer"
 	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
 )
 
 func TestParser(t *testing.T) {
@@ -77,6 +81,11 @@ func TestParser(t *testing.T) {
 		`simple: {{ "value template" }}`,
 		`mapping:\n  {{ if true }}\n  key: value\n  {{ else }}\n  key: otherValue\n  {{ end }}`,
 		`mapping:\n  child:\n    {{ if true }}\n    key: value\n    {{ end }}\n  {{ if false }} key: otherValue {{ end }}`,
+		"{{ define \"labels\" }}\napp: {{ .name }}\n{{ end }}\nmetadata:\n  labels:\n    {{ template \"labels\" . }}",
+		"{{ range .items }}\n---\nkind: {{ .kind }}\n{{ end }}\n{{ if .x }}\n---\nkind: x\n{{ end }}",
+		"list:\n  - a\n  {{ range .items }}\n  - {{ . }}\n  {{ end }}\n  - z\n",
+		"image: \"{{ .repo }}:{{ .tag }}\"\nname: app-{{ .env }}\nnote: 'it''s {{ .env }}'\n",
+		"script: |\n  echo {{ .env }}\n",
 	}
 	for _, src := range sources {
 		if _, err := parser.Parse(lexer.Tokenize(src), 0); err != nil {
@@ -626,6 +635,31 @@ a
         ^
 `,
 		},
+		{
+			`a: 'x' {{ .x }}`,
+			`
+[1:7] unexpected content after quoted scalar
+>  1 | a: 'x' {{ .x }}
+             ^
+`,
+		},
+		{
+			`a: "{{ .x }}" y`,
+			`
+[1:14] unexpected content after quoted scalar
+>  1 | a: "{{ .x }}" y
+                    ^
+`,
+		},
+		{
+			"a: 'x\n  y' z\nb: 1",
+			`
+[1:11] unexpected content after quoted scalar
+>  1 | a: 'x
+   2 |   y' z
+                 ^
+   2 | b: 1`,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.source, func(t *testing.T) {
@@ -641,6 +675,222 @@ a
 	}
 }
 
+func TestBigNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		big    bool
+		expect string
+	}{
+		{"v: 18446744073709551615", false, "18446744073709551615"},
+		{"v: -9223372036854775808", false, "-9223372036854775808"},
+		{"v: 99999999999999999999", true, "99999999999999999999"},
+		{"v: -9223372036854775809", true, "-9223372036854775809"},
+		{"v: 1_000_000_000_000_000_000_000", true, "1000000000000000000000"},
+		{"v: 0x1_0000_0000_0000_0000", true, "18446744073709551616"},
+		{"v: -0x8000_0000_0000_0001", true, "-9223372036854775809"},
+		{"v: 0o2_000_000_000_000_000_000_000", true, "18446744073709551616"},
+		{"v: 0b1_0000000000000000000000000000000000000000000000000000000000000000", true, "18446744073709551616"},
+		{"v: 1.0e+400", true, "1e+400"},
+		{"v: -1.5e+400", true, "-1.5e+400"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := parser.ParseBytes([]byte(test.source), 0)
+			if test.big != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			f, err := parser.ParseBytes([]byte(test.source), parser.ParseBigNumbers)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			value := f.Docs[0].Body.(*ast.MappingNode).Values[0].Value.(ast.ScalarNode).GetValue()
+			if actual := fmt.Sprint(value); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	_, err := parser.ParseBytes([]byte("a: 1\nb: 99999999999999999999\n"), 0)
+	if err == nil || !strings.HasPrefix(err.Error(), "[2:4] integer 99999999999999999999 overflows 64 bits") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	source := "a: yes\nb: 012\nc: 190:20:30\nd: 1_000\ne: +.inf\nf: ~\n"
+	tests := []struct {
+		source string
+		mode   parser.Mode
+		expect string
+	}{
+		{source, 0, "a:yes b:10 c:190:20:30 d:1000 e:+.inf f:<nil>"},
+		{source, parser.ParseFailsafeSchema, "a:yes b:012 c:190:20:30 d:1_000 e:+.inf f:~"},
+		{source, parser.ParseJSONSchema, "a:yes b:012 c:190:20:30 d:1_000 e:+.inf f:~"},
+		{source, parser.ParseCoreSchema, "a:yes b:12 c:190:20:30 d:1_000 e:+Inf f:<nil>"},
+		{source, parser.ParseYAML11Schema, "a:true b:10 c:685230 d:1000 e:+Inf f:<nil>"},
+		{"%YAML 1.1\n---\n" + source, 0, "a:true b:10 c:685230 d:1000 e:+Inf f:<nil>"},
+		{"%YAML 1.2\n---\n" + source, 0, "a:yes b:12 c:190:20:30 d:1_000 e:+Inf f:<nil>"},
+		{"%YAML 1.1\n---\n" + source, parser.ParseCoreSchema, "a:yes b:12 c:190:20:30 d:1_000 e:+Inf f:<nil>"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), test.mode)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			body := f.Docs[len(f.Docs)-1].Body.(*ast.MappingNode)
+			var values []string
+			for _, v := range body.Values {
+				values = append(values, fmt.Sprintf("%v:%v", v.Key, v.Value.(ast.ScalarNode).GetValue()))
+			}
+			if actual := strings.Join(values, " "); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	// A %YAML directive only applies to the document that follows it.
+	f, err := parser.ParseBytes([]byte("%YAML 1.1\n---\na: yes\n---\nb: yes\n"), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	body := f.Docs[len(f.Docs)-1].Body.(*ast.MappingNode)
+	if value := body.Values[0].Value.(ast.ScalarNode).GetValue(); value != "yes" {
+		t.Fatalf("expected: [yes] but got [%v]", value)
+	}
+
+	// A YAML 1.1 integer without digits is an error rather than zero. A lone zero followed by underscores is octal 0.
+	_, err = parser.ParseBytes([]byte("a: 0\nb: 0x_\n"), parser.ParseYAML11Schema)
+	if err == nil || !strings.HasPrefix(err.Error(), "[2:4] invalid integer 0x_") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f, err = parser.ParseBytes([]byte("a: 0_\n"), parser.ParseYAML11Schema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	body = f.Docs[0].Body.(*ast.MappingNode)
+	if value := body.Values[0].Value.(ast.ScalarNode).GetValue(); value != uint64(0) {
+		t.Fatalf("expected: [0] but got [%v]", value)
+	}
+}
+
+func TestTimestampAndBinary(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{"v: !!timestamp 2002-12-14", "2002-12-14T00:00:00Z"},
+		{"v: !!timestamp 2001-12-15T02:59:43.1Z", "2001-12-15T02:59:43.1Z"},
+		{"v: !!timestamp 2001-12-14t21:59:43.10-05:00", "2001-12-14T21:59:43.1-05:00"},
+		{"v: !!timestamp 2001-12-14 21:59:43.10 -5", "2001-12-14T21:59:43.1-05:00"},
+		{"v: !!timestamp 2001-12-15 2:59:43.10", "2001-12-15T02:59:43.1Z"},
+		{"v: !!timestamp '2001-12-15 2:59:43.10'", "2001-12-15T02:59:43.1Z"},
+		{"v: !!binary aGVsbG8=", "hello"},
+		{"v: !!binary |\n  aGVs\n  bG8g\n  d29y\n  bGQ=\n", "hello world"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			tag := f.Docs[0].Body.(*ast.MappingNode).Values[0].Value.(*ast.TagNode)
+			var actual string
+			switch value := tag.Value.(ast.ScalarNode).GetValue().(type) {
+			case time.Time:
+				actual = value.Format(time.RFC3339Nano)
+			case []byte:
+				actual = string(value)
+			default:
+				t.Fatalf("unexpected value %#v", value)
+			}
+			if actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		source string
+		expect string
+	}{
+		{"v: !!timestamp 2001-13-14", `[1:15] invalid timestamp "2001-13-14"`},
+		{"v: !!binary a*b", "[1:12] invalid binary value: illegal base64 data at input byte 1"},
+	}
+	for _, test := range errorTests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := parser.ParseBytes([]byte(test.source), 0)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
+
+func TestImplicitTimestamp(t *testing.T) {
+	source := "a: 2001-12-14\nb: 2001-12-14 21:59:43.10 -5\nc: '2001-12-14'\nd: |\n  2001-12-14\ne: 2001-13-14\n"
+	tests := []struct {
+		source string
+		mode   parser.Mode
+		expect string
+	}{
+		{source, 0, "a:String b:String c:String d:Literal e:String"},
+		{source, parser.ParseCoreSchema, "a:String b:String c:String d:Literal e:String"},
+		{source, parser.ParseYAML11Schema, "a:Timestamp b:Timestamp c:String d:Literal e:String"},
+		{"%YAML 1.1\n---\n" + source, 0, "a:Timestamp b:Timestamp c:String d:Literal e:String"},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), test.mode)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			body := f.Docs[len(f.Docs)-1].Body.(*ast.MappingNode)
+			var values []string
+			for _, v := range body.Values {
+				values = append(values, fmt.Sprintf("%v:%v", v.Key, v.Value.Type()))
+			}
+			if actual := strings.Join(values, " "); actual != test.expect {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+
+	f, err := parser.ParseBytes([]byte(source), parser.ParseYAML11Schema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	value := f.Docs[0].Body.(*ast.MappingNode).Values[1].Value.(*ast.TimestampNode).Value
+	if actual := value.Format(time.RFC3339Nano); actual != "2001-12-14T21:59:43.1-05:00" {
+		t.Fatalf("expected: [2001-12-14T21:59:43.1-05:00] but got [%s]", actual)
+	}
+}
+
+func TestNoTemplates(t *testing.T) {
+	source := "a: \"{{ .name | upper }}\"\nb: '{{ .x }}'\nc: |\n  {{ .y }}\nd: x{{ .y }}z\n"
+	f, err := parser.ParseBytes([]byte(source), parser.ParseNoTemplates)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var values []string
+	for _, v := range f.Docs[0].Body.(*ast.MappingNode).Values {
+		values = append(values, fmt.Sprintf("%v:%v", v.Key, v.Value.Type()))
+	}
+	if actual, expect := strings.Join(values, " "), "a:String b:String c:Literal d:String"; actual != expect {
+		t.Fatalf("expected: [%s] but got [%s]", expect, actual)
+	}
+	if actual := f.String(); actual != strings.TrimSuffix(source, "\n") {
+		t.Fatalf("expected: [%s] but got [%s]", source, actual)
+	}
+
+	// Without templates, a bare action is a flow mapping with a flow mapping key, which is not supported.
+	_, err = parser.ParseBytes([]byte("a: {{ .x }}\n"), parser.ParseNoTemplates)
+	if err == nil || !strings.HasPrefix(err.Error(), "[1:5] unexpected mapping key") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestComment(t *testing.T) {
 	tests := []struct {
 		name string
@@ -710,3 +960,479 @@ func (v *Visitor) Visit(node ast.Node) ast.Visitor {
 	}
 	return v
 }
+
+func TestLexTemplate(t *testing.T) {
+	tests := []struct {
+		source string
+		expect []printer.TemplateItem
+	}{
+		{
+			source: `{{- range $i, $v := .items | sort -}}`,
+			expect: []printer.TemplateItem{
+				{Kind: printer.TemplateDelim, Text: "{{"},
+				{Kind: printer.TemplateText, Text: "- "},
+				{Kind: printer.TemplateKeyword, Text: "range"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateVariable, Text: "$i"},
+				{Kind: printer.TemplateOperator, Text: ","},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateVariable, Text: "$v"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateOperator, Text: ":="},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateField, Text: ".items"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateOperator, Text: "|"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateFunction, Text: "sort"},
+				{Kind: printer.TemplateText, Text: " -"},
+				{Kind: printer.TemplateDelim, Text: "}}"},
+			},
+		},
+		{
+			source: "{{ printf `%d` 1 true nil . }}",
+			expect: []printer.TemplateItem{
+				{Kind: printer.TemplateDelim, Text: "{{"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateFunction, Text: "printf"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateString, Text: "`%d`"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateNumber, Text: "1"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateConstant, Text: "true"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateConstant, Text: "nil"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateField, Text: "."},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateDelim, Text: "}}"},
+			},
+		},
+		{
+			source: "{{/* note */}}",
+			expect: []printer.TemplateItem{{Kind: printer.TemplateComment, Text: "{{/* note */}}"}},
+		},
+		{
+			source: "{{ .x ",
+			expect: []printer.TemplateItem{
+				{Kind: printer.TemplateDelim, Text: "{{"},
+				{Kind: printer.TemplateText, Text: " "},
+				{Kind: printer.TemplateField, Text: ".x"},
+				{Kind: printer.TemplateText, Text: " "},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			actual := parser.LexTemplate(test.source)
+			if !reflect.DeepEqual(actual, test.expect) {
+				t.Fatalf("expected: %v but got %v", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestTemplateList(t *testing.T) {
+	tests := []struct {
+		source string
+		expect []ast.NodeType
+	}{
+		{
+			source: "{{ range .items }}\n---\nkind: {{ .kind }}\n{{ end }}\n{{ if .x }}\n---\nkind: x\n{{ end }}\n",
+			expect: []ast.NodeType{ast.RangeType, ast.IfType},
+		},
+		{
+			source: "- a\n{{ range .items }}\n- {{ . }}\n{{ end }}\n- z\n",
+			expect: []ast.NodeType{ast.SequenceType, ast.RangeType, ast.SequenceType},
+		},
+		{
+			source: "- a\n- b\n{{ if .x }}\n- c\n{{ else }}\n- d\n{{ end }}\n",
+			expect: []ast.NodeType{ast.SequenceType, ast.IfType},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if len(f.Docs) != 1 {
+				t.Fatalf("expected 1 document but got %d", len(f.Docs))
+			}
+			list, ok := f.Docs[0].Body.(*ast.TemplateListNode)
+			if !ok {
+				t.Fatalf("expected a template list but got %s", f.Docs[0].Body.Type())
+			}
+			var actual []ast.NodeType
+			for _, n := range list.List.Nodes {
+				actual = append(actual, n.Type())
+			}
+			if !reflect.DeepEqual(actual, test.expect) {
+				t.Fatalf("expected %v but got %v", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		source string
+		style  token.Type
+		expect []ast.NodeType
+	}{
+		{
+			source: "name: app-{{ .env }}",
+			style:  token.StringType,
+			expect: []ast.NodeType{ast.StringType, ast.ActionType},
+		},
+		{
+			source: "name: {{ .a }}-{{ .b }} # comment",
+			style:  token.StringType,
+			expect: []ast.NodeType{ast.ActionType, ast.StringType, ast.ActionType},
+		},
+		{
+			source: `image: "{{ .repo }}:{{ .tag }}"`,
+			style:  token.DoubleQuoteType,
+			expect: []ast.NodeType{ast.ActionType, ast.StringType, ast.ActionType},
+		},
+		{
+			source: `image: "{{ .repo | default "nginx" }}"`,
+			style:  token.DoubleQuoteType,
+			expect: []ast.NodeType{ast.ActionType},
+		},
+		{
+			source: `name: "{{ printf "%s}}" .a }}-{{ .b }}" # comment`,
+			style:  token.DoubleQuoteType,
+			expect: []ast.NodeType{ast.ActionType, ast.StringType, ast.ActionType},
+		},
+		{
+			source: `note: 'it''s {{ .env }}'`,
+			style:  token.SingleQuoteType,
+			expect: []ast.NodeType{ast.StringType, ast.ActionType},
+		},
+		{
+			source: "debug: x-{{ if .debug }}yes{{ else }}no{{ end }}",
+			style:  token.StringType,
+			expect: []ast.NodeType{ast.StringType, ast.IfType},
+		},
+		{
+			source: "script: |\n  echo {{ .env }}\n  done\n",
+			style:  token.LiteralType,
+			expect: []ast.NodeType{ast.StringType, ast.ActionType, ast.StringType},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			nodes := ast.FilterFile(ast.InterpolatedStringType, f)
+			if len(nodes) != 1 {
+				t.Fatalf("expected 1 interpolated string but got %d", len(nodes))
+			}
+			str := nodes[0].(*ast.InterpolatedStringNode)
+			if str.Style != test.style {
+				t.Fatalf("expected style %s but got %s", test.style, str.Style)
+			}
+			var actual []ast.NodeType
+			for _, n := range str.Parts.Nodes {
+				actual = append(actual, n.Type())
+			}
+			if !reflect.DeepEqual(actual, test.expect) {
+				t.Fatalf("expected %v but got %v", test.expect, actual)
+			}
+		})
+	}
+}
+
+func TestTemplateKeysAndFlowCollections(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+		key    ast.NodeType
+	}{
+		{
+			source: "{{ .name }}: value",
+			expect: "{{.name}}: value",
+			key:    ast.ActionType,
+		},
+		{
+			source: "a: 1\n{{ .name }}: value\nb: 2",
+			expect: "a: 1\n{{.name}}: value\nb: 2",
+			key:    ast.ActionType,
+		},
+		{
+			source: "a: 1\napp-{{ .name }}: value",
+			expect: "a: 1\napp-{{.name}}: value",
+			key:    ast.InterpolatedStringType,
+		},
+		{
+			source: "{{ if .x }}a{{ else }}b{{ end }}: value",
+			expect: "{{if .x}}a{{else}}b{{end}}: value",
+			key:    ast.IfType,
+		},
+		{
+			source: "m: { {{ .k }}: v, x: y}",
+			expect: "m: { {{.k}}: v, x: y }",
+			key:    ast.ActionType,
+		},
+		{
+			source: "args: [--port, {{ .port }}]",
+			expect: "args: [--port, {{.port}}]",
+		},
+		{
+			source: "args: [a, {{ range .xs }}{{ . }}{{ end }}, z]",
+			expect: "args: [a, {{range .xs}}{{.}}{{end}}, z]",
+		},
+		{
+			source: "m: {a: {{ .b }}}",
+			expect: "m: { a: {{.b}} }",
+		},
+		{
+			source: "m: {a: x-{{ .b }}, c: d}",
+			expect: "m: {a: x-{{.b}}, c: d}",
+		},
+		{
+			source: "m:\n  a: 1\n  {{ range $k, $v := .m }}\n  {{ $k }}: {{ $v }}\n  {{ end }}",
+			expect: "m:\n  a: 1\n{{range $k, $v := .m}}  {{$k}}: {{$v}}{{end}}",
+			key:    ast.ActionType,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := f.String(); actual != test.expect {
+				t.Fatalf("expected %q but got %q", test.expect, actual)
+			}
+			if test.key == ast.UnknownNodeType {
+				return
+			}
+			var keys []ast.NodeType
+			for _, n := range ast.FilterFile(ast.MappingValueType, f) {
+				if key := n.(*ast.MappingValueNode).Key; key != nil && key.Type() != ast.StringType {
+					keys = append(keys, key.Type())
+				}
+			}
+			if !reflect.DeepEqual(keys, []ast.NodeType{test.key}) {
+				t.Fatalf("expected a %s key but got %v", test.key, keys)
+			}
+		})
+	}
+}
+
+func TestBlockInterpolation(t *testing.T) {
+	source := "data:\n  config.ini: |-\n    name = {{ .name }}\n    {{ range .ports }}\n      port = {{ . }}\n    {{ end }}\n"
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	nodes := ast.FilterFile(ast.InterpolatedStringType, f)
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 interpolated string but got %d", len(nodes))
+	}
+	str := nodes[0].(*ast.InterpolatedStringNode)
+	if str.Style != token.LiteralType || str.Token.Value != "|-" {
+		t.Fatalf("unexpected block header %s %q", str.Style, str.Token.Value)
+	}
+
+	type part struct {
+		typ          ast.NodeType
+		text         string
+		line, column int
+	}
+	var describe func(list *ast.NodeList) []part
+	describe = func(list *ast.NodeList) []part {
+		var parts []part
+		for _, n := range list.Nodes {
+			p := part{typ: n.Type(), line: n.GetToken().Position.Line, column: n.GetToken().Position.Column}
+			if s, ok := n.(*ast.StringNode); ok {
+				p.text = s.Value
+			}
+			parts = append(parts, p)
+			if r, ok := n.(*ast.RangeNode); ok {
+				parts = append(parts, describe(r.List)...)
+			}
+		}
+		return parts
+	}
+	expect := []part{
+		{ast.StringType, "name = ", 3, 5},
+		{ast.ActionType, "", 3, 12},
+		{ast.StringType, "\n", 3, 23},
+		{ast.RangeType, "", 4, 5},
+		{ast.StringType, "  port = ", 5, 5},
+		{ast.ActionType, "", 5, 14},
+		{ast.StringType, "\n", 5, 21},
+	}
+	if actual := describe(str.Parts); !reflect.DeepEqual(actual, expect) {
+		t.Fatalf("expected %v but got %v", expect, actual)
+	}
+
+	expectString := "data:\n  config.ini: |-\n    name = {{.name}}\n    {{range .ports}}  port = {{.}}\n    {{end}}"
+	if actual := f.String(); actual != expectString {
+		t.Fatalf("expected %q but got %q", expectString, actual)
+	}
+}
+
+func TestTemplateComment(t *testing.T) {
+	tests := []struct {
+		source   string
+		expect   string
+		comments []string
+	}{
+		{
+			source:   "{{/* doc */}}\na: 1",
+			expect:   "{{/* doc */}}\na: 1",
+			comments: []string{"/* doc */"},
+		},
+		{
+			source:   "{{- /* doc */ -}}\na: 1",
+			expect:   "{{/* doc */}}\na: 1",
+			comments: []string{"/* doc */"},
+		},
+		{
+			source:   "{{/* multi\nline */}}\na: 1",
+			expect:   "{{/* multi\nline */}}\na: 1",
+			comments: []string{"/* multi\nline */"},
+		},
+		{
+			source:   "m:\n  {{/* x */}}\n  x: 1\n  {{/* y */}}\n  y: 2\nz: 3",
+			expect:   "m:\n{{/* x */}}\n  x: 1\n{{/* y */}}\n  y: 2\nz: 3",
+			comments: []string{"/* x */", "/* y */"},
+		},
+		{
+			source:   "ports:\n  {{/* first */}}\n  - 80\n  - 443",
+			expect:   "ports:\n{{/* first */}}  - 80\n  - 443",
+			comments: []string{"/* first */"},
+		},
+		{
+			source:   "- a\n{{/* c */}}\n- b",
+			expect:   "- a{{/* c */}}- b",
+			comments: []string{"/* c */"},
+		},
+		{
+			source:   "a: {{/* c */}}",
+			expect:   "a: {{/* c */}}",
+			comments: []string{"/* c */"},
+		},
+		{
+			source:   "a: x-{{/* c */}}y",
+			expect:   "a: x-{{/* c */}}y",
+			comments: []string{"/* c */"},
+		},
+		{
+			source:   "a: |\n  {{/* c */}}\n  x",
+			expect:   "a: |\n  {{/* c */}}x",
+			comments: []string{"/* c */"},
+		},
+		{
+			source:   "{{ define \"x\" }}\n{{/* c */}}\na: 1\n{{ end }}\nb: 1",
+			expect:   "{{define \"x\"}}{{/* c */}}a: 1{{end}}\nb: 1",
+			comments: []string{"/* c */"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := f.String(); actual != test.expect {
+				t.Fatalf("expected %q but got %q", test.expect, actual)
+			}
+			var comments []string
+			for _, n := range ast.FilterFile(ast.TemplateCommentType, f) {
+				comments = append(comments, n.(*ast.TemplateCommentNode).Text)
+			}
+			if !reflect.DeepEqual(comments, test.comments) {
+				t.Fatalf("expected comments %q but got %q", test.comments, comments)
+			}
+		})
+	}
+
+	// A comment that documents a mapping entry immediately precedes the entry.
+	f, err := parser.ParseBytes([]byte("a: 1\n{{/* The value of b. */}}\nb: 2"), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	mapping, ok := f.Docs[0].Body.(*ast.MappingNode)
+	if !ok || len(mapping.Values) != 3 {
+		t.Fatalf("expected a mapping with 3 entries but got %s", f.Docs[0].Body.Type())
+	}
+	if doc, ok := mapping.Values[1].Template.(*ast.TemplateCommentNode); !ok || doc.Text != "/* The value of b. */" {
+		t.Fatalf("expected a template comment but got %v", mapping.Values[1])
+	}
+	if key := mapping.Values[2].Key.String(); key != "b" {
+		t.Fatalf("expected key b but got %q", key)
+	}
+}
+
+func TestBreakContinueAndElseWith(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+		err    string
+	}{
+		{
+			source: "xs: [{{ range .xs }}{{ . }}{{ break }}{{ end }}]",
+			expect: "xs: [{{range .xs}}{{.}}{{break}}{{end}}]",
+		},
+		{
+			source: "s: \"{{ range .xs }}{{ if not . }}{{ continue }}{{ end }}{{ . }}{{ end }}\"",
+			expect: "s: \"{{range .xs}}{{if not .}}{{continue}}{{end}}{{.}}{{end}}\"",
+		},
+		{
+			source: "n: [{{ range 3 }}{{ . }}{{ end }}]",
+			expect: "n: [{{range 3}}{{.}}{{end}}]",
+		},
+		{
+			source: "w: {{ with .a }}a{{ else with .b }}{{ . }}{{ else }}c{{ end }}",
+			expect: "w:\n{{with .a}}a{{else}}{{with .b}}{{.}}{{else}}c{{end}}{{end}}",
+		},
+		{
+			source: "{{ break }}",
+			err:    "{{break}} outside {{range}}",
+		},
+		{
+			source: "{{ range .xs }}{{ define \"x\" }}{{ continue }}{{ end }}{{ end }}",
+			err:    "{{continue}} outside {{range}}",
+		},
+		{
+			source: "s: \"{{ range .xs }}{{ break 1 }}{{ end }}\"",
+			err:    `unexpected "1" in {{break}}`,
+		},
+		{
+			source: "s: \"{{ if .a }}a{{ else with .b }}b{{ end }}\"",
+			err:    "unexpected <with> in input",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error %q but got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := f.String(); actual != test.expect {
+				t.Fatalf("expected %q but got %q", test.expect, actual)
+			}
+			f, err = parser.ParseBytes([]byte(test.expect), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := f.String(); actual != test.expect {
+				t.Fatalf("expected %q to round-trip but got %q", test.expect, actual)
+			}
+		})
+	}
+}
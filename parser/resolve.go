@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+)
+
+const (
+	// DefaultMaxAliasDepth is the alias nesting depth permitted by ResolveAliases if AliasLimits.MaxDepth is zero.
+	DefaultMaxAliasDepth = 64
+	// DefaultMaxAliasNodes is the number of nodes that aliases may expand to if AliasLimits.MaxNodes is zero.
+	DefaultMaxAliasNodes = 1000000
+)
+
+// AliasLimits bounds the work required to expand the aliases in a document. The limits protect consumers of the
+// AST against documents that nest aliases in order to expand to an enormous number of nodes (the "billion laughs"
+// attack).
+type AliasLimits struct {
+	// MaxDepth is the maximum number of aliases that may be nested within the expansion of a single alias.
+	MaxDepth int
+	// MaxNodes is the maximum number of nodes that the aliases in a single document may expand to.
+	MaxNodes int
+}
+
+func (l AliasLimits) maxDepth() int {
+	if l.MaxDepth <= 0 {
+		return DefaultMaxAliasDepth
+	}
+	return l.MaxDepth
+}
+
+func (l AliasLimits) maxNodes() int {
+	if l.MaxNodes <= 0 {
+		return DefaultMaxAliasNodes
+	}
+	return l.MaxNodes
+}
+
+// aliasExpansion records the size of the expanded value of an anchor.
+type aliasExpansion struct {
+	nodes int // the number of nodes in the expanded value
+	depth int // the maximum alias nesting depth within the expanded value
+}
+
+type aliasResolver struct {
+	limits   AliasLimits
+	anchors  map[string]*ast.AnchorNode
+	open     map[*ast.AnchorNode]bool
+	sizes    map[*ast.AnchorNode]aliasExpansion
+	expanded int
+}
+
+// ResolveAliases links each alias in the given file to the anchor it refers to. Anchors are scoped to the document
+// that contains them, and an alias refers to the closest preceding anchor with the same name.
+//
+// An error is returned if an alias refers to an undefined anchor, if an alias refers to an anchor that contains the
+// alias, or if expanding the aliases in a document would exceed the given limits.
+func ResolveAliases(f *ast.File, limits AliasLimits) error {
+	for _, doc := range f.Docs {
+		r := &aliasResolver{
+			limits:  limits,
+			anchors: map[string]*ast.AnchorNode{},
+			open:    map[*ast.AnchorNode]bool{},
+			sizes:   map[*ast.AnchorNode]aliasExpansion{},
+		}
+		if _, err := r.resolve(doc); err != nil {
+			return errors.Wrapf(err, "failed to resolve aliases")
+		}
+	}
+	return nil
+}
+
+func nodeName(node ast.Node) string {
+	if s, ok := node.(*ast.StringNode); ok {
+		return s.Value
+	}
+	return node.String()
+}
+
+func (r *aliasResolver) resolveList(nodes []ast.Node) (aliasExpansion, error) {
+	var size aliasExpansion
+	for _, n := range nodes {
+		child, err := r.resolve(n)
+		if err != nil {
+			return aliasExpansion{}, err
+		}
+		size.nodes += child.nodes
+		if child.depth > size.depth {
+			size.depth = child.depth
+		}
+	}
+	return size, nil
+}
+
+func (r *aliasResolver) resolveAlias(n *ast.AliasNode) (aliasExpansion, error) {
+	if n.Value == nil {
+		return aliasExpansion{}, errors.ErrSyntax("unexpected alias. alias name is undefined", n.Start)
+	}
+	name := nodeName(n.Value)
+	anchor, ok := r.anchors[name]
+	if !ok {
+		return aliasExpansion{}, errors.ErrSyntax(fmt.Sprintf("undefined alias %q", name), n.Start)
+	}
+	if r.open[anchor] {
+		return aliasExpansion{}, errors.ErrSyntax(fmt.Sprintf("alias %q refers to an anchor that contains it", name), n.Start)
+	}
+	n.Anchor = anchor
+
+	size := r.sizes[anchor]
+	size.depth++
+	if size.depth > r.limits.maxDepth() {
+		msg := fmt.Sprintf("alias %q exceeds the maximum alias depth of %d", name, r.limits.maxDepth())
+		return aliasExpansion{}, errors.ErrSyntax(msg, n.Start)
+	}
+	r.expanded += size.nodes
+	if r.expanded > r.limits.maxNodes() {
+		msg := fmt.Sprintf("alias %q exceeds the maximum alias expansion of %d nodes", name, r.limits.maxNodes())
+		return aliasExpansion{}, errors.ErrSyntax(msg, n.Start)
+	}
+	return size, nil
+}
+
+func (r *aliasResolver) resolveAnchor(n *ast.AnchorNode) (aliasExpansion, error) {
+	if n.Name == nil {
+		return aliasExpansion{}, errors.ErrSyntax("unexpected anchor. anchor name is undefined", n.Start)
+	}
+	r.anchors[nodeName(n.Name)] = n
+
+	r.open[n] = true
+	size, err := r.resolve(n.Value)
+	if err != nil {
+		return aliasExpansion{}, err
+	}
+	delete(r.open, n)
+
+	r.sizes[n] = size
+	return size, nil
+}
+
+func (r *aliasResolver) resolveBranch(n *ast.BranchNode) (aliasExpansion, error) {
+	size, err := r.resolveList(n.List.Nodes)
+	if err != nil || n.ElseList == nil {
+		return size, err
+	}
+	elseSize, err := r.resolveList(n.ElseList.Nodes)
+	if err != nil {
+		return aliasExpansion{}, err
+	}
+	if elseSize.nodes > size.nodes {
+		size.nodes = elseSize.nodes
+	}
+	if elseSize.depth > size.depth {
+		size.depth = elseSize.depth
+	}
+	return size, nil
+}
+
+// resolve links the aliases in the tree rooted at node and returns the size of the tree's expansion.
+func (r *aliasResolver) resolve(node ast.Node) (aliasExpansion, error) {
+	if node == nil {
+		return aliasExpansion{}, nil
+	}
+
+	var children []ast.Node
+	switch n := node.(type) {
+	case *ast.AliasNode:
+		return r.resolveAlias(n)
+	case *ast.AnchorNode:
+		return r.resolveAnchor(n)
+	case *ast.IfNode:
+		return r.resolveBranch(&n.BranchNode)
+	case *ast.RangeNode:
+		return r.resolveBranch(&n.BranchNode)
+	case *ast.WithNode:
+		return r.resolveBranch(&n.BranchNode)
+	case *ast.DefineNode:
+		return r.resolveList(n.List.Nodes)
+	case *ast.TemplateListNode:
+		return r.resolveList(n.List.Nodes)
+	case *ast.DocumentNode:
+		children = []ast.Node{n.Body}
+	case *ast.TagNode:
+		children = []ast.Node{n.Value}
+	case *ast.MappingKeyNode:
+		children = []ast.Node{n.Value}
+	case *ast.MappingValueNode:
+		children = []ast.Node{n.Template, n.Key, n.Value}
+	case *ast.MappingNode:
+		for _, v := range n.Values {
+			children = append(children, v)
+		}
+	case *ast.SequenceNode:
+		children = n.Values
+	}
+
+	size, err := r.resolveList(children)
+	if err != nil {
+		return aliasExpansion{}, err
+	}
+	size.nodes++
+	return size, nil
+}
@@ -0,0 +1,141 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func TestResolveAliases(t *testing.T) {
+	src := `
+a: &x
+  b: 1
+c: *x
+---
+d: &x 2
+e: *x
+`
+	f, err := parser.ParseBytes([]byte(src), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := parser.ResolveAliases(f, parser.AliasLimits{}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	aliases := ast.FilterFile(ast.AliasType, f)
+	if len(aliases) != 2 {
+		t.Fatalf("expected 2 aliases, got %d", len(aliases))
+	}
+	for i, expect := range []string{"b: 1", "2"} {
+		alias := aliases[i].(*ast.AliasNode)
+		if alias.Anchor == nil {
+			t.Fatalf("alias %d was not resolved", i)
+		}
+		if actual := strings.TrimSpace(alias.Anchor.Value.String()); actual != expect {
+			t.Fatalf("unexpected anchor value for alias %d: [%s] != [%s]", i, expect, actual)
+		}
+	}
+}
+
+func TestResolveAliasesError(t *testing.T) {
+	laughs := []string{"a: &a [x, x, x, x, x, x, x, x, x, x]"}
+	for i := 1; i < 6; i++ {
+		prev := string(rune('a' + i - 1))
+		refs := strings.TrimSuffix(strings.Repeat("*"+prev+", ", 10), ", ")
+		laughs = append(laughs, fmt.Sprintf("%c: &%c [%s]", 'a'+i, 'a'+i, refs))
+	}
+
+	tests := []struct {
+		source string
+		limits parser.AliasLimits
+		expect string
+	}{
+		{
+			source: `
+a: 1
+b: *x
+`,
+			expect: `
+[3:4] undefined alias "x"
+   2 | a: 1
+>  3 | b: *x
+          ^
+`,
+		},
+		{
+			source: `
+a: &x
+  b: *x
+`,
+			expect: `
+[3:6] alias "x" refers to an anchor that contains it
+   2 | a: &x
+>  3 |   b: *x
+            ^
+`,
+		},
+		{
+			source: `
+a: &x 1
+---
+b: *x
+`,
+			expect: `
+[4:4] undefined alias "x"
+   2 | a: &x1
+   3 | ---
+>  4 | b: *x
+          ^
+`,
+		},
+		{
+			source: `
+a: &a 1
+b: &b [*a]
+c: &c [*b]
+d: *c
+`,
+			limits: parser.AliasLimits{MaxDepth: 2},
+			expect: `
+[5:4] alias "c" exceeds the maximum alias depth of 2
+   2 | a: &a1
+   3 | b: &b[*a]
+   4 | c: &c[*b]
+>  5 | d: *c
+          ^
+`,
+		},
+		{
+			source: strings.Join(laughs, "\n"),
+			limits: parser.AliasLimits{MaxNodes: 10000},
+			expect: `
+[4:36] alias "c" exceeds the maximum alias expansion of 10000 nodes
+   1 | a: &a[x, x, x, x, x, x, x, x, x, x]
+   2 | b: &b[*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]
+   3 | c: &c[*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]
+>  4 | d: &d[*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]
+                                          ^
+   5 | e: &e[*d, *d, *d, *d, *d, *d, *d, *d, *d, *d]
+   6 | f: &f[*e, *e, *e, *e, *e, *e, *e, *e, *e, *e]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(test.source), 0)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			err = parser.ResolveAliases(f, test.limits)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			actual := "\n" + err.Error()
+			if test.expect != actual {
+				t.Fatalf("expected: [%s] but got [%s]", test.expect, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"golang.org/x/xerrors"
+)
+
+// TagKind identifies the kinds of node that a tag handler accepts. Kinds may be combined, e.g.
+// ScalarTagKind|SequenceTagKind.
+type TagKind int
+
+const (
+	// ScalarTagKind accepts scalar nodes.
+	ScalarTagKind TagKind = 1 << iota
+	// SequenceTagKind accepts sequence nodes.
+	SequenceTagKind
+	// MappingTagKind accepts mapping nodes.
+	MappingTagKind
+
+	// AnyTagKind accepts nodes of any kind.
+	AnyTagKind = ScalarTagKind | SequenceTagKind | MappingTagKind
+)
+
+// String returns a description of the kinds, e.g. "scalar or sequence".
+func (k TagKind) String() string {
+	var kinds []string
+	if k&ScalarTagKind != 0 {
+		kinds = append(kinds, "scalar")
+	}
+	if k&SequenceTagKind != 0 {
+		kinds = append(kinds, "sequence")
+	}
+	if k&MappingTagKind != 0 {
+		kinds = append(kinds, "mapping")
+	}
+	return strings.Join(kinds, " or ")
+}
+
+// tagKindOf returns the kind of a node. If the kind cannot be determined at parse time, e.g. because the node is an
+// alias or a template, tagKindOf returns AnyTagKind.
+func tagKindOf(node ast.Node) TagKind {
+	switch n := node.(type) {
+	case nil:
+		return 0
+	case *ast.AnchorNode:
+		return tagKindOf(n.Value)
+	case *ast.MappingNode, *ast.MappingValueNode:
+		return MappingTagKind
+	case *ast.SequenceNode:
+		return SequenceTagKind
+	case ast.ScalarNode:
+		return ScalarTagKind
+	}
+	return AnyTagKind
+}
+
+// TagContext provides information about the current parse to tag handlers.
+type TagContext struct {
+	// Filename is the name of the file being parsed, if any.
+	Filename string
+
+	config   Config
+	includes []string
+}
+
+// ParseFile parses the named file using the configuration of the current parse. Relative paths are resolved relative
+// to the directory of the file being parsed. ParseFile returns an error if the file is already being parsed, e.g.
+// because it includes itself.
+func (c *TagContext) ParseFile(path string) (*ast.File, error) {
+	if !filepath.IsAbs(path) && c.Filename != "" {
+		path = filepath.Join(filepath.Dir(c.Filename), path)
+	}
+
+	includes := c.includes
+	if c.Filename != "" {
+		includes = append(includes[:len(includes):len(includes)], c.Filename)
+	}
+	for i, include := range includes {
+		if include == path {
+			return nil, xerrors.Errorf("include cycle: %s", strings.Join(append(includes[i:], path), " -> "))
+		}
+	}
+	return c.config.parseFile(path, includes)
+}
+
+// TagHandler handles a custom tag.
+type TagHandler struct {
+	// Kind holds the kinds of node that the tag accepts. If Kind is zero, the tag accepts nodes of any kind.
+	Kind TagKind
+	// Transform, if non-nil, is called with each tagged node once it has been parsed and validated. The node returned by
+	// Transform replaces the tagged node.
+	Transform func(ctx *TagContext, node *ast.TagNode) (ast.Node, error)
+}
+
+// TagRegistry maps tags to their handlers. A nil *TagRegistry has no handlers.
+type TagRegistry struct {
+	handlers map[string]TagHandler
+}
+
+// NewTagRegistry creates a new, empty tag registry.
+func NewTagRegistry() *TagRegistry {
+	return &TagRegistry{handlers: map[string]TagHandler{}}
+}
+
+// Register registers the handler for the given tag, e.g. "!include". Any existing handler for the tag is replaced.
+func (r *TagRegistry) Register(tag string, handler TagHandler) *TagRegistry {
+	r.handlers[tag] = handler
+	return r
+}
+
+// Lookup returns the handler for the given tag, if any.
+func (r *TagRegistry) Lookup(tag string) (TagHandler, bool) {
+	if r == nil {
+		return TagHandler{}, false
+	}
+	handler, ok := r.handlers[tag]
+	return handler, ok
+}
+
+// includePath returns the file name given by the value of an include tag. Anchors and resolved aliases are unwrapped;
+// any other value that is not a scalar is an error.
+func includePath(node *ast.TagNode) (string, error) {
+	value := node.Value
+	for {
+		switch n := value.(type) {
+		case *ast.AnchorNode:
+			value = n.Value
+			continue
+		case *ast.AliasNode:
+			if n.Anchor != nil {
+				value = n.Anchor.Value
+				continue
+			}
+		case *ast.StringNode:
+			return n.Value, nil
+		case *ast.LiteralNode:
+			return n.Value.Value, nil
+		case ast.ScalarNode:
+			return fmt.Sprint(n.GetValue()), nil
+		case nil:
+			return "", errors.ErrSyntax(fmt.Sprintf("%s requires a file name", node.Start.Value), node.Start)
+		}
+		return "", errors.ErrSyntax(fmt.Sprintf("%s requires a scalar file name, not %s", node.Start.Value, value.Type()),
+			value.GetToken())
+	}
+}
+
+// IncludeTagHandler handles tags like `!include file.yaml`. The tagged scalar names a YAML file that must contain a
+// single document. The tagged node is replaced by the body of the document. Relative names are resolved relative to
+// the directory of the including file.
+var IncludeTagHandler = TagHandler{
+	Kind: ScalarTagKind,
+	Transform: func(ctx *TagContext, node *ast.TagNode) (ast.Node, error) {
+		path, err := includePath(node)
+		if err != nil {
+			return nil, err
+		}
+		f, err := ctx.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.Docs) != 1 {
+			return nil, xerrors.Errorf("included file %s must contain exactly one document", path)
+		}
+		return f.Docs[0].Body, nil
+	},
+}
+
+// CloudFormationTags returns a registry with handlers for the short forms of AWS CloudFormation's intrinsic functions,
+// e.g. `!Ref`, `!GetAtt`, and `!Sub`. The handlers validate the kind of each tagged node and leave the node unchanged.
+func CloudFormationTags() *TagRegistry {
+	r := NewTagRegistry()
+	for tag, kind := range map[string]TagKind{
+		"!And":         SequenceTagKind,
+		"!Base64":      AnyTagKind,
+		"!Cidr":        SequenceTagKind,
+		"!Condition":   ScalarTagKind,
+		"!Equals":      SequenceTagKind,
+		"!FindInMap":   SequenceTagKind,
+		"!GetAtt":      ScalarTagKind | SequenceTagKind,
+		"!GetAZs":      ScalarTagKind | MappingTagKind,
+		"!If":          SequenceTagKind,
+		"!ImportValue": ScalarTagKind | MappingTagKind,
+		"!Join":        SequenceTagKind,
+		"!Not":         SequenceTagKind,
+		"!Or":          SequenceTagKind,
+		"!Ref":         ScalarTagKind,
+		"!Select":      SequenceTagKind,
+		"!Split":       SequenceTagKind,
+		"!Sub":         ScalarTagKind | SequenceTagKind,
+		"!Transform":   MappingTagKind,
+	} {
+		r.Register(tag, TagHandler{Kind: kind})
+	}
+	return r
+}
+
+// handleTag validates a tagged node against its handler and applies the handler's transform, if any.
+func (p *parser) handleTag(ctx *context, handler TagHandler, node *ast.TagNode) (ast.Node, error) {
+	if handler.Kind != 0 && tagKindOf(node.Value)&handler.Kind == 0 {
+		tk := node.Start
+		if node.Value != nil {
+			tk = node.Value.GetToken()
+		}
+		return nil, errors.ErrSyntax(fmt.Sprintf("%s requires a %s value", node.Start.Value, handler.Kind), tk)
+	}
+	if handler.Transform == nil {
+		return node, nil
+	}
+
+	tagContext := &TagContext{
+		Filename: ctx.filename,
+		config:   Config{Mode: ctx.mode, Funcs: ctx.funcs, Tags: ctx.tags},
+		includes: ctx.includes,
+	}
+	value, err := handler.Transform(tagContext, node)
+	if err != nil {
+		var pp errors.PrettyPrinter
+		if xerrors.As(err, &pp) {
+			return nil, errors.Wrapf(err, "failed to handle %s tag", node.Start.Value)
+		}
+		return nil, errors.ErrSyntax(err.Error(), node.Start)
+	}
+	return value, nil
+}
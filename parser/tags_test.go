@@ -0,0 +1,183 @@
+package parser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/parser"
+)
+
+func TestTagKinds(t *testing.T) {
+	tests := []struct {
+		source string
+		expect string
+	}{
+		{source: "a: !Ref Bucket\n"},
+		{source: "a: !GetAtt Bucket.Arn\n"},
+		{source: "a: !GetAtt [Bucket, Arn]\n"},
+		{source: "a: !Join\n  - ''\n  - [a, b]\n"},
+		{source: "a: !Sub '${AWS::Region}'\n"},
+		{source: "a: !Ref {{ .name }}\n"},
+		{source: "a: !Custom [1, 2]\n"},
+		{
+			source: "a: !Ref [Bucket]\n",
+			expect: "[1:8] !Ref requires a scalar value",
+		},
+		{
+			source: "a: !Join\n  k: v\n",
+			expect: "[2:3] !Join requires a sequence value",
+		},
+		{
+			source: "a: !GetAtt {k: v}\n",
+			expect: "[1:11] !GetAtt requires a scalar or sequence value",
+		},
+	}
+	config := parser.Config{Tags: parser.CloudFormationTags()}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := config.ParseBytes([]byte(test.source))
+			if test.expect == "" {
+				if err != nil {
+					t.Fatalf("%+v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
+
+func TestIncludeTag(t *testing.T) {
+	config := parser.Config{Tags: parser.NewTagRegistry().Register("!include", parser.IncludeTagHandler)}
+
+	f, err := config.ParseFile(filepath.Join("testdata", "include", "main.yml"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	child := f.Docs[0].Body.(*ast.MappingNode).Values[1].Value
+	if _, ok := child.(*ast.MappingNode); !ok {
+		t.Fatalf("expected a mapping but got %s", child.Type())
+	}
+	if actual := child.String(); actual != "a: 1\nb: [x, y]" {
+		t.Fatalf("unexpected included value: %s", actual)
+	}
+
+	config.Filename = filepath.Join("testdata", "include", "main.yml")
+	f, err = config.ParseBytes([]byte("child: !include &x child.yml\n"))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	child = f.Docs[0].Body.(*ast.MappingNode).Values[0].Value
+	if actual := child.String(); actual != "a: 1\nb: [x, y]" {
+		t.Fatalf("unexpected included value: %s", actual)
+	}
+
+	errorTests := []struct {
+		source string
+		expect string
+	}{
+		{source: "a: &y child.yml\nb: !include *y\n", expect: "[2:12] !include requires a scalar file name, not Alias"},
+		{source: "a: !include {{ .x }}\n", expect: "[1:12] !include requires a scalar file name, not Action"},
+	}
+	for _, test := range errorTests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := config.ParseBytes([]byte(test.source))
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+	config.Filename = ""
+
+	a, b := filepath.Join("testdata", "include", "cycle_a.yml"), filepath.Join("testdata", "include", "cycle_b.yml")
+	_, err = config.ParseFile(a)
+	if err == nil || !strings.Contains(err.Error(), "include cycle: "+a+" -> "+b+" -> "+a) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTagDirectives(t *testing.T) {
+	source := `%YAML 1.2
+%TAG !e! tag:example.com,2000:app/
+%TAG ! tag:local.example.com,2000:
+---
+a: !e!foo x
+b: !e!b%C3%A4r y
+c: !local z
+d: !!str w
+e: !<tag:verbatim.example.com,2000:v> v
+f: ! u
+---
+a: !local z
+b: !!str w
+`
+	f, err := parser.ParseBytes([]byte(source), 0)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	directives := []*ast.DirectiveNode{f.Docs[0].Body.(*ast.DirectiveNode), f.Docs[1].Body.(*ast.DirectiveNode)}
+	if d := directives[0]; d.Name != "YAML" || d.Version != "1.2" {
+		t.Fatalf("unexpected directive: %+v", d)
+	}
+	if d := directives[1]; d.Name != "TAG" || d.Handle != "!e!" || d.Prefix != "tag:example.com,2000:app/" {
+		t.Fatalf("unexpected directive: %+v", d)
+	}
+
+	expect := [][]string{
+		{
+			"tag:example.com,2000:app/foo",
+			"tag:example.com,2000:app/bär",
+			"tag:local.example.com,2000:local",
+			"tag:yaml.org,2002:str",
+			"tag:verbatim.example.com,2000:v",
+			"!",
+		},
+		{
+			"!local",
+			"tag:yaml.org,2002:str",
+		},
+	}
+	docs := f.Docs[len(f.Docs)-2:]
+	for i, doc := range docs {
+		for j, v := range doc.Body.(*ast.MappingNode).Values {
+			if actual := v.Value.(*ast.TagNode).URI; actual != expect[i][j] {
+				t.Fatalf("expected: [%s] but got [%s]", expect[i][j], actual)
+			}
+		}
+	}
+
+	errorTests := []struct {
+		source string
+		expect string
+	}{
+		{
+			source: "a: !e!foo x\n",
+			expect: "[1:4] undefined tag handle !e!",
+		},
+		{
+			source: "%TAG !e! tag:example.com,2000:\n---\na: !e!foo x\n---\nb: !e!foo y\n",
+			expect: "[5:4] undefined tag handle !e!",
+		},
+		{
+			source: "%TAG !e! a:\n%TAG !e! b:\n---\na: x\n",
+			expect: "[2:2] duplicate TAG directive for handle !e!",
+		},
+		{
+			source: "%YAML 1.2\n%YAML 1.2\n---\na: x\n",
+			expect: "[2:2] duplicate YAML directive",
+		},
+	}
+	for _, test := range errorTests {
+		t.Run(test.source, func(t *testing.T) {
+			_, err := parser.ParseBytes([]byte(test.source), 0)
+			if err == nil || !strings.HasPrefix(err.Error(), test.expect) {
+				t.Fatalf("expected: [%s] but got [%v]", test.expect, err)
+			}
+		})
+	}
+}
@@ -29,6 +29,35 @@ type Printer struct {
 	Bool             PrintFunc
 	String           PrintFunc
 	Number           PrintFunc
+	Comment          PrintFunc
+	Tag              PrintFunc
+	Template         PrintFunc
+	Directive        PrintFunc
+	// Document styles document headers (---) and document ends (...).
+	Document PrintFunc
+	// Styles maps token types to properties. A style for a token type takes precedence over the property fields
+	// above, except that map keys and the names of anchors and aliases are styled by MapKey, Anchor, and Alias.
+	Styles map[token.Type]PrintFunc
+	// TemplateStyles maps the kinds of the items inside template actions to properties. Items whose kinds have no
+	// style are styled as part of their template token.
+	TemplateStyles map[TemplateItemKind]PrintFunc
+	// LexTemplate splits the text of a template token into items for TemplateStyles. The items must cover the text.
+	// See parser.LexTemplate.
+	LexTemplate func(text string) []TemplateItem
+	// Escape, if non-nil, is applied to the source text of each token before its property is added, e.g. to escape
+	// HTML.
+	Escape func(s string) string
+}
+
+// decorate adds a property to a line of source text. Empty lines are left undecorated.
+func (p *Printer) decorate(prop *Property, src string) string {
+	if src == "" {
+		return ""
+	}
+	if p.Escape != nil {
+		src = p.Escape(src)
+	}
+	return prop.Prefix + src + prop.Suffix
 }
 
 func defaultLineNumberFormat(num int) string {
@@ -36,55 +65,121 @@ func defaultLineNumberFormat(num int) string {
 }
 
 func (p *Printer) property(tk *token.Token) *Property {
-	prop := &Property{}
+	if fn := p.style(tk); fn != nil {
+		return fn()
+	}
+	return &Property{}
+}
+
+func (p *Printer) style(tk *token.Token) PrintFunc {
+	switch tk.Type {
+	case token.CommentType, token.TagType, token.TemplateType, token.DirectiveType,
+		token.DocumentHeaderType, token.DocumentEndType:
+		return p.typeStyle(tk.Type)
+	}
 	switch tk.PreviousType() {
 	case token.AnchorType:
-		if p.Anchor != nil {
-			return p.Anchor()
-		}
-		return prop
+		return p.Anchor
 	case token.AliasType:
-		if p.Alias != nil {
-			return p.Alias()
-		}
-		return prop
+		return p.Alias
 	}
-	switch tk.NextType() {
-	case token.MappingValueType:
-		if p.MapKey != nil {
-			return p.MapKey()
-		}
-		return prop
+	if tk.NextType() == token.MappingValueType {
+		return p.MapKey
 	}
-	switch tk.Type {
+	return p.typeStyle(tk.Type)
+}
+
+func (p *Printer) typeStyle(typ token.Type) PrintFunc {
+	if fn, ok := p.Styles[typ]; ok {
+		return fn
+	}
+	switch typ {
+	case token.CommentType:
+		return p.Comment
+	case token.TagType:
+		return p.Tag
+	case token.TemplateType:
+		return p.Template
+	case token.DirectiveType:
+		return p.Directive
+	case token.DocumentHeaderType, token.DocumentEndType:
+		return p.Document
 	case token.BoolType:
-		if p.Bool != nil {
-			return p.Bool()
-		}
-		return prop
+		return p.Bool
 	case token.AnchorType:
-		if p.Anchor != nil {
-			return p.Anchor()
-		}
-		return prop
+		return p.Anchor
 	case token.AliasType:
-		if p.Anchor != nil {
-			return p.Alias()
+		return p.Alias
+	case token.StringType, token.SingleQuoteType, token.DoubleQuoteType, token.LiteralType, token.FoldedType:
+		return p.String
+	case token.IntegerType, token.BinaryIntegerType, token.OctetIntegerType, token.HexIntegerType, token.FloatType,
+		token.InfinityType, token.NanType:
+		return p.Number
+	}
+	return nil
+}
+
+// segment is a run of source text with a single property.
+type segment struct {
+	prop *Property
+	text string
+}
+
+// segments splits the source text of a token into runs with the same property. Template tokens and the actions
+// embedded in quoted and block scalars are split into their items if the printer has template styles.
+func (p *Printer) segments(tk *token.Token) []segment {
+	prop := p.property(tk)
+	if p.LexTemplate == nil || len(p.TemplateStyles) == 0 {
+		return []segment{{prop: prop, text: tk.Origin}}
+	}
+
+	switch tk.Type {
+	case token.TemplateType:
+		start := strings.Index(tk.Origin, tk.Value)
+		if start < 0 {
+			return []segment{{prop: prop, text: tk.Origin}}
 		}
-		return prop
+		segments := []segment{{prop: prop, text: tk.Origin[:start]}}
+		segments = append(segments, p.templateSegments(prop, tk.Value)...)
+		return append(segments, segment{prop: prop, text: tk.Origin[start+len(tk.Value):]})
 	case token.StringType, token.SingleQuoteType, token.DoubleQuoteType:
-		if p.String != nil {
-			return p.String()
+		var segments []segment
+		text := tk.Origin
+		for {
+			start := strings.Index(text, "{{")
+			if start < 0 {
+				break
+			}
+			end := strings.Index(text[start:], "}}")
+			if end < 0 {
+				break
+			}
+			end += start + len("}}")
+
+			templateProp := prop
+			if fn := p.typeStyle(token.TemplateType); fn != nil {
+				templateProp = fn()
+			}
+			segments = append(segments, segment{prop: prop, text: text[:start]})
+			segments = append(segments, p.templateSegments(templateProp, text[start:end])...)
+			text = text[end:]
 		}
-		return prop
-	case token.IntegerType, token.FloatType:
-		if p.Number != nil {
-			return p.Number()
+		return append(segments, segment{prop: prop, text: text})
+	}
+	return []segment{{prop: prop, text: tk.Origin}}
+}
+
+// templateSegments splits the text of a template action into its items. Items without a style use the given property.
+func (p *Printer) templateSegments(prop *Property, text string) []segment {
+	var segments []segment
+	for _, item := range p.LexTemplate(text) {
+		itemProp := prop
+		if fn := p.TemplateStyles[item.Kind]; fn != nil {
+			itemProp = fn()
 		}
-		return prop
-	default:
+		segments = append(segments, segment{prop: itemProp, text: item.Text})
 	}
-	return prop
+	return segments
 }
 
 // PrintTokens create text from token collection
@@ -100,37 +195,17 @@ func (p *Printer) PrintTokens(tokens token.Tokens) string {
 	texts := []string{}
 	lineNumber := tokens[0].Position.Line
 	for _, tk := range tokens {
-		lines := strings.Split(tk.Origin, "\n")
-		prop := p.property(tk)
-		header := ""
-		if p.LineNumber {
-			header = p.LineNumberFormat(lineNumber)
-		}
-		if len(lines) == 1 {
-			line := prop.Prefix + lines[0] + prop.Suffix
-			if len(texts) == 0 {
-				texts = append(texts, header+line)
-				lineNumber++
-			} else {
-				text := texts[len(texts)-1]
-				texts[len(texts)-1] = text + line
-			}
-		} else {
-			for idx, src := range lines {
+		for _, seg := range p.segments(tk) {
+			for idx, src := range strings.Split(seg.text, "\n") {
+				header := ""
 				if p.LineNumber {
 					header = p.LineNumberFormat(lineNumber)
 				}
-				line := prop.Prefix + src + prop.Suffix
-				if idx == 0 {
-					if len(texts) == 0 {
-						texts = append(texts, header+line)
-						lineNumber++
-					} else {
-						text := texts[len(texts)-1]
-						texts[len(texts)-1] = text + line
-					}
+				line := p.decorate(seg.prop, src)
+				if idx == 0 && len(texts) != 0 {
+					texts[len(texts)-1] += line
 				} else {
-					texts = append(texts, fmt.Sprintf("%s%s", header, line))
+					texts = append(texts, header+line)
 					lineNumber++
 				}
 			}
@@ -187,6 +262,30 @@ func (p *Printer) setDefaultColorSet() {
 			Suffix: format(color.Reset),
 		}
 	}
+	p.Comment = func() *Property {
+		return &Property{
+			Prefix: format(color.FgHiBlack),
+			Suffix: format(color.Reset),
+		}
+	}
+	p.Tag = func() *Property {
+		return &Property{
+			Prefix: format(color.FgHiBlue),
+			Suffix: format(color.Reset),
+		}
+	}
+	p.Template = func() *Property {
+		return &Property{
+			Prefix: format(color.FgHiRed),
+			Suffix: format(color.Reset),
+		}
+	}
+	p.Directive = func() *Property {
+		return &Property{
+			Prefix: format(color.FgHiBlue),
+			Suffix: format(color.Reset),
+		}
+	}
 }
 
 func (p *Printer) PrintErrorMessage(msg string, isColored bool) string {
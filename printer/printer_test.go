@@ -1,10 +1,13 @@
 package printer_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/pgavlin/yomlette/lexer"
+	"github.com/pgavlin/yomlette/parser"
 	"github.com/pgavlin/yomlette/printer"
+	"github.com/pgavlin/yomlette/token"
 )
 
 func Test_Printer(t *testing.T) {
@@ -137,3 +140,67 @@ a:
 		p.PrintErrorMessage(src, true)
 	})
 }
+
+func TestPrintTokensProperties(t *testing.T) {
+	property := func(name string) printer.PrintFunc {
+		return func() *printer.Property {
+			return &printer.Property{Prefix: "<" + name + ">", Suffix: "</" + name + ">"}
+		}
+	}
+	p := printer.Printer{
+		MapKey:    property("key"),
+		String:    property("str"),
+		Comment:   property("comment"),
+		Tag:       property("tag"),
+		Template:  property("tpl"),
+		Directive: property("dir"),
+		Document:  property("doc"),
+		Escape:    strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace,
+	}
+	source := "%YAML 1.2\n---\n# a < b\na: !t {{ .x }}\nb: x&y\n...\n"
+	expect := "<dir>%</dir><str>YAML 1.2</str>\n" +
+		"<doc>---</doc>\n" +
+		"<comment># a &lt; b</comment>\n" +
+		"<key>a</key>:<tag> !t </tag><tpl>{{ .x }}</tpl>\n" +
+		"<key>b</key>:<str> x&amp;y</str>\n" +
+		"<doc>...</doc>"
+	if actual := p.PrintTokens(lexer.Tokenize(source)); actual != expect {
+		t.Fatalf("unexpected output: expect:[%s]\n actual:[%s]", expect, actual)
+	}
+}
+
+func TestPrintTokensStyles(t *testing.T) {
+	property := func(name string) printer.PrintFunc {
+		return func() *printer.Property {
+			return &printer.Property{Prefix: "<" + name + ">", Suffix: "</" + name + ">"}
+		}
+	}
+	p := printer.Printer{
+		Alias:    property("alias"),
+		Number:   property("num"),
+		Template: property("tpl"),
+		Styles: map[token.Type]printer.PrintFunc{
+			token.NullType:        property("null"),
+			token.IntegerType:     property("int"),
+			token.LiteralType:     property("lit"),
+			token.DoubleQuoteType: property("dq"),
+		},
+		TemplateStyles: map[printer.TemplateItemKind]printer.PrintFunc{
+			printer.TemplateKeyword: property("kw"),
+			printer.TemplateField:   property("field"),
+		},
+		LexTemplate: parser.LexTemplate,
+	}
+	source := "a: &x 1\nb: *x\nc: ~\nd: 0x1f\ne: {{ if .x }}\nf: |\n  x\ng: \"x{{ .y }}\"\n"
+	expect := "a: &x<int>1</int>\n" +
+		"b:<alias> *</alias><alias>x</alias>\n" +
+		"c:<null> ~</null>\n" +
+		"d:<num> 0x1f</num>\n" +
+		"e:<tpl> </tpl><tpl>{{</tpl><tpl> </tpl><kw>if</kw><tpl> </tpl><field>.x</field><tpl> </tpl><tpl>}}</tpl>\n" +
+		"f:<lit> |</lit>\n" +
+		"  x\n" +
+		"g:<dq> \"x</dq><tpl>{{</tpl><tpl> </tpl><field>.y</field><tpl> </tpl><tpl>}}</tpl><dq>\"</dq>"
+	if actual := p.PrintTokens(lexer.Tokenize(source)); actual != expect {
+		t.Fatalf("unexpected output: expect:[%s]\n actual:[%s]", expect, actual)
+	}
+}
@@ -0,0 +1,35 @@
+package printer
+
+// TemplateItemKind identifies the kind of an item inside a template action.
+type TemplateItemKind int
+
+const (
+	// TemplateText is text that is not part of any other kind of item, e.g. spaces and trim markers.
+	TemplateText TemplateItemKind = iota
+	// TemplateDelim is an action delimiter.
+	TemplateDelim
+	// TemplateKeyword is a keyword, e.g. if, range, or end.
+	TemplateKeyword
+	// TemplateField is a field or the cursor, e.g. .Name or the dot.
+	TemplateField
+	// TemplateVariable is a variable, e.g. $x.
+	TemplateVariable
+	// TemplateFunction is an identifier that names a function.
+	TemplateFunction
+	// TemplateString is a quoted or raw string.
+	TemplateString
+	// TemplateNumber is a number or character constant.
+	TemplateNumber
+	// TemplateConstant is a boolean constant or nil.
+	TemplateConstant
+	// TemplateOperator is a pipe, parenthesis, assignment, declaration, or other punctuation.
+	TemplateOperator
+	// TemplateComment is a comment, including its delimiters.
+	TemplateComment
+)
+
+// A TemplateItem is a run of the text of a template token.
+type TemplateItem struct {
+	Kind TemplateItemKind
+	Text string
+}
@@ -38,7 +38,9 @@ type Scanner struct {
 	indentNum              int
 	isFirstCharAtLine      bool
 	isAnchor               bool
+	isDirective            bool
 	isTemplate             bool
+	templatesDisabled      bool
 	startedFlowSequenceNum int
 	startedFlowMapNum      int
 	indentState            IndentState
@@ -67,6 +69,7 @@ func (s *Scanner) progressLine(ctx *Context) {
 	s.indentNum = 0
 	s.isFirstCharAtLine = true
 	s.isAnchor = false
+	s.isDirective = false
 	ctx.progress(1)
 }
 
@@ -227,106 +230,175 @@ func hexRunesToCode(b []rune) rune {
 	return rune(sum)
 }
 
-func (s *Scanner) decodeEscapeSequence(ctx *Context) (int, []rune) {
-	if ctx.idx+1 >= len(ctx.src) {
+// decodeEscapeSequence decodes the escape sequence that starts with the backslash at src[idx]. It returns the number of
+// characters that follow the backslash in the sequence and the characters it represents, or zero if the sequence is
+// not a valid escape sequence.
+func decodeEscapeSequence(src []rune, idx int) (int, []rune) {
+	if idx+1 >= len(src) {
 		return 0, nil
 	}
 
-	nextChar := ctx.src[ctx.idx+1]
+	nextChar := src[idx+1]
 	switch nextChar {
+	case '0':
+		return 1, []rune{'\x00'}
+	case 'a':
+		return 1, []rune{'\a'}
 	case 'b':
 		return 1, []rune{'\b'}
-	case 'e':
-		return 1, []rune{'\x1b'}
-	case 'f':
-		return 1, []rune{'\f'}
+	case 't', '\t':
+		return 1, []rune{'\t'}
 	case 'n':
 		return 1, []rune{'\n'}
 	case 'v':
 		return 1, []rune{'\v'}
-	case 'L': // LS (#x2028)
-		return 1, []rune{'\xE2', '\x80', '\xA8'}
+	case 'f':
+		return 1, []rune{'\f'}
+	case 'r':
+		return 1, []rune{'\r'}
+	case 'e':
+		return 1, []rune{'\x1b'}
+	case ' ', '/', '"', '\\':
+		return 1, []rune{nextChar}
 	case 'N': // NEL (#x85)
-		return 1, []rune{'\xC2', '\x85'}
-	case 'P': // PS (#x2029)
-		return 1, []rune{'\xE2', '\x80', '\xA9'}
+		return 1, []rune{'\u0085'}
 	case '_': // #xA0
-		return 1, []rune{'\xC2', '\xA0'}
-	case '"':
-		return 1, []rune{'"'}
-	case '\\':
-		return 1, []rune{'\\'}
+		return 1, []rune{'\u00a0'}
+	case 'L': // LS (#x2028)
+		return 1, []rune{'\u2028'}
+	case 'P': // PS (#x2029)
+		return 1, []rune{'\u2029'}
 	case 'x':
-		if ctx.idx+3 >= len(ctx.src) {
+		if idx+3 >= len(src) {
 			// TODO: need to return error
 			//err = xerrors.New("invalid escape character \\x")
 			return 0, nil
 		}
-		codeNum := hexRunesToCode(ctx.src[ctx.idx+2 : ctx.idx+4])
+		codeNum := hexRunesToCode(src[idx+2 : idx+4])
 		return 3, []rune{codeNum}
 	case 'u':
-		if ctx.idx+5 >= len(ctx.src) {
+		if idx+5 >= len(src) {
 			// TODO: need to return error
 			//err = xerrors.New("invalid escape character \\u")
 			return 0, nil
 		}
-		codeNum := hexRunesToCode(ctx.src[ctx.idx+2 : ctx.idx+6])
+		codeNum := hexRunesToCode(src[idx+2 : idx+6])
 		return 5, []rune{codeNum}
 	case 'U':
-		if ctx.idx+9 >= len(ctx.src) {
+		if idx+9 >= len(src) {
 			// TODO: need to return error
 			//err = xerrors.New("invalid escape character \\U")
 			return 0, nil
 		}
-		codeNum := hexRunesToCode(ctx.src[ctx.idx+2 : ctx.idx+10])
+		codeNum := hexRunesToCode(src[idx+2 : idx+10])
 		return 9, []rune{codeNum}
 	default:
 		return 0, nil
 	}
 }
 
+// doubleQuoteDecoder decodes the content of a double-quoted scalar: escape sequences are decoded, and line breaks are
+// folded into spaces along with the indentation that follows them.
+type doubleQuoteDecoder struct {
+	value           strings.Builder
+	isFirstLineChar bool
+}
+
+// decode decodes the character at src[idx] and returns the number of characters that follow it in an escape sequence.
+func (d *doubleQuoteDecoder) decode(src []rune, idx int) int {
+	switch c := src[idx]; {
+	case c == '\n' || c == '\r':
+		d.value.WriteRune(' ')
+		d.isFirstLineChar = true
+	case c == ' ' && d.isFirstLineChar:
+		// Ignore leading spaces
+	case c == '\\':
+		escapeLen, runes := decodeEscapeSequence(src, idx)
+		if escapeLen == 0 {
+			runes = []rune{'\\'}
+		}
+		for _, r := range runes {
+			d.value.WriteRune(r)
+		}
+		d.isFirstLineChar = false
+		return escapeLen
+	default:
+		d.value.WriteRune(c)
+		d.isFirstLineChar = false
+	}
+	return 0
+}
+
+// UnescapeDoubleQuoted returns the value of a double-quoted scalar given the text between its quotes. Escape sequences
+// are decoded and line breaks are folded as they are when the scanner scans the scalar.
+func UnescapeDoubleQuoted(text string) string {
+	src := []rune(text)
+	var d doubleQuoteDecoder
+	for i := 0; i < len(src); i++ {
+		i += d.decode(src, i)
+	}
+	return d.value.String()
+}
+
 func (s *Scanner) scanDoubleQuote(ctx *Context) (tk *token.Token, pos int) {
 	ctx.addOriginBuf('"')
 	ctx.progress(1)
 
 	length := 1
-	isFirstLineChar := false
-	var value strings.Builder
+	var d doubleQuoteDecoder
 	for ; ctx.idx < len(ctx.src); ctx.idx, length = ctx.idx+1, length+1 {
 		c := ctx.src[ctx.idx]
 		ctx.addOriginBuf(c)
 
-		switch {
-		case s.isNewLineChar(c):
-			value.WriteRune(' ')
-			isFirstLineChar = true
-		case c == ' ' && isFirstLineChar:
-			// Ignore leading spaces
-		case c == '\\':
-			escapeLen, runes := s.decodeEscapeSequence(ctx)
-			if escapeLen != 0 {
-				ctx.appendOriginBuf(ctx.src[ctx.idx+1 : ctx.idx+1+escapeLen]...)
-				ctx.idx, length = ctx.idx+escapeLen, length+escapeLen
-			} else {
-				runes = []rune{'\\'}
-			}
-
-			for _, r := range runes {
-				value.WriteRune(r)
-			}
-			isFirstLineChar = false
-		case c == '"':
-			tk = token.DoubleQuote(value.String(), string(ctx.obuf), s.pos())
+		if c == '"' {
+			tk = token.DoubleQuote(d.value.String(), string(ctx.obuf), s.pos())
 			pos = length
 			return
-		default:
-			value.WriteRune(c)
-			isFirstLineChar = false
+		}
+		if c == '{' && !s.templatesDisabled {
+			// A template action is template source: its quotes do not end the scalar, and its escape sequences are
+			// left for the template.
+			if end := templateEnd(ctx.src, ctx.idx); end >= 0 {
+				action := ctx.src[ctx.idx:end]
+				ctx.appendOriginBuf(action[1:]...)
+				d.value.WriteString(string(action))
+				d.isFirstLineChar = false
+				n := len(action) - 1
+				ctx.idx, length = ctx.idx+n, length+n
+				continue
+			}
+		}
+		if escapeLen := d.decode(ctx.src, ctx.idx); escapeLen != 0 {
+			ctx.appendOriginBuf(ctx.src[ctx.idx+1 : ctx.idx+1+escapeLen]...)
+			ctx.idx, length = ctx.idx+escapeLen, length+escapeLen
 		}
 	}
 	return
 }
 
+// templateEnd returns the index just past the right delimiter of the template action that starts at src[idx], or -1
+// if src[idx] does not start a closed action. Delimiters inside the action's string literals are skipped.
+func templateEnd(src []rune, idx int) int {
+	if idx+1 >= len(src) || src[idx] != '{' || src[idx+1] != '{' {
+		return -1
+	}
+	for i := idx + 2; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '}':
+			if i+1 < len(src) && src[i+1] == '}' {
+				return i + 2
+			}
+		case '"', '`':
+			for i++; i < len(src) && src[i] != c; i++ {
+				if c == '"' && src[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return -1
+}
+
 func (s *Scanner) scanQuote(ctx *Context, ch rune) (tk *token.Token, pos int) {
 	if ch == '\'' {
 		return s.scanSingleQuote(ctx)
@@ -348,8 +420,22 @@ func (s *Scanner) scanTemplateString(ctx *Context) {
 	}
 }
 
+// progressTemplate updates the position of the scanner to account for the template that starts at index start and
+// ends at the current index.
+func (s *Scanner) progressTemplate(ctx *Context, start int) {
+	for _, c := range ctx.src[start:ctx.idx] {
+		s.offset++
+		if c == '\n' {
+			s.line, s.column = s.line+1, 1
+		} else {
+			s.column++
+		}
+	}
+}
+
 func (s *Scanner) scanTemplate(ctx *Context) (tk *token.Token) {
 	pos := ctx.idx
+	defer s.progressTemplate(ctx, pos)
 
 	ctx.addOriginBuf('{')
 	ctx.addOriginBuf('{')
@@ -359,7 +445,9 @@ func (s *Scanner) scanTemplate(ctx *Context) (tk *token.Token) {
 		c := ctx.currentChar()
 		switch c {
 		case '}':
-			if ctx.repeatNum('}') == 2 {
+			// The action ends at the first right delimiter, so that a template that closes a flow mapping, e.g.
+			// `{a: {{ .b }}}`, leaves the mapping's closing brace alone.
+			if ctx.repeatNum('}') >= 2 {
 				ctx.addOriginBuf('}')
 				ctx.addOriginBuf('}')
 				ctx.progress(2)
@@ -531,7 +619,7 @@ func (s *Scanner) scan(ctx *Context) (pos int) {
 		}
 		switch c {
 		case '{':
-			if ctx.repeatNum('{') == 2 {
+			if ctx.repeatNum('{') == 2 && !s.templatesDisabled {
 				ctx.addBufferedTokenIfExists()
 				ctx.addToken(s.scanTemplate(ctx))
 				pos = ctx.idx
@@ -627,6 +715,10 @@ func (s *Scanner) scan(ctx *Context) (pos int) {
 			}
 		case ':':
 			nc := ctx.nextChar()
+			if s.isDirective {
+				// part of a directive's parameters, e.g. the prefix of a TAG directive
+				break
+			}
 			if s.startedFlowMapNum > 0 || nc == ' ' || s.isNewLineChar(nc) || ctx.isNextEOS() {
 				// mapping value
 				tk := ctx.bufferedToken()
@@ -664,6 +756,7 @@ func (s *Scanner) scan(ctx *Context) (pos int) {
 			if !ctx.existsBuffer() && s.indentNum == 0 {
 				ctx.addToken(token.Directive(s.pos()))
 				s.progressColumn(ctx, 1)
+				s.isDirective = true
 				return
 			}
 		case '?':
@@ -752,6 +845,12 @@ func (s *Scanner) Init(text string) {
 	s.isFirstCharAtLine = true
 }
 
+// DisableTemplates disables the scanning of template actions, so `{{` starts nested flow mappings as it does in plain
+// YAML. It must be called after Init.
+func (s *Scanner) DisableTemplates() {
+	s.templatesDisabled = true
+}
+
 // Scan scans the next token and returns the token collection. The source end is indicated by io.EOF.
 func (s *Scanner) Scan() (token.Tokens, error) {
 	if s.sourcePos >= len(s.source) {
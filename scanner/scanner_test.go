@@ -34,12 +34,21 @@ func TestSingleQuote(t *testing.T) {
 
 func TestDoubleQuote(t *testing.T) {
 	cases := map[string]string{
-		`"foo"`:     `foo`,
-		`"\"foo"`:   `"foo`,
-		`"foo\""`:   `foo"`,
-		`"'foo'"`:   `'foo'`,
-		`"f\"oo"`:   `f"oo`,
-		`"f\x22oo"`: `f"oo`,
+		`"foo"`:                  `foo`,
+		`"\"foo"`:                `"foo`,
+		`"foo\""`:                `foo"`,
+		`"'foo'"`:                `'foo'`,
+		`"f\"oo"`:                `f"oo`,
+		`"f\x22oo"`:              `f"oo`,
+		`"\0\a\b\t\n\v\f\r\e"`:   "\x00\a\b\t\n\v\f\r\x1b",
+		"\"a\\\tb\"":             "a\tb",
+		`"\ \/\\"`:               ` /\`,
+		`"\N\_\L\P"`:             "\u0085\u00a0\u2028\u2029",
+		`"\u00e9\U0001F600"`:     "é\U0001F600",
+		`"\q"`:                   `\q`,
+		`"{{ default "x" .y }}"`: `{{ default "x" .y }}`,
+		`"\t{{ "}}\"" }}\t"`:     "\t{{ \"}}\\\"\" }}\t",
+		`"{{ .a "`:               `{{ .a `,
 	}
 	for input, expected := range cases {
 		t.Run(input, func(t *testing.T) {
@@ -0,0 +1,255 @@
+package stdtemplate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// An Issue describes a template that could not be lifted because its actions are not structurally aligned with its
+// YAML.
+type Issue struct {
+	// Name is the name of the template.
+	Name string
+	// Err is the error reported by the parser. Its position refers to the text of the template's parse tree.
+	Err error
+}
+
+func (i *Issue) Error() string {
+	return fmt.Sprintf("template %q: %v", i.Name, i.Err)
+}
+
+// Lifted holds the result of lifting text/template templates.
+type Lifted struct {
+	// File holds the templates that were lifted. Associated templates are lifted into {{define}} actions that precede
+	// the body of the lifted template.
+	File *ast.File
+	// Issues lists the templates that could not be lifted, ordered by name.
+	Issues []*Issue
+}
+
+// Lift converts a text/template Template and its associated templates into a yomlette file. The text of each
+// template's parse tree is parsed as YAML. Control actions whose surrounding line breaks were trimmed, as in
+//
+//	ports:
+//	  {{- range .ports }}
+//	  - {{ . }}
+//	  {{- end }}
+//
+// are moved back onto lines of their own. Templates that still do not parse, and templates with actions that produce
+// indented text rather than YAML nodes, such as
+//
+//	spec:
+//	  {{- toYaml .spec | nindent 2 }}
+//
+// are left out of the file and reported. funcs must hold the functions that the templates call other than the builtin
+// functions.
+func Lift(t *template.Template, funcs template.FuncMap) (*Lifted, error) {
+	templates := t.Templates()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+
+	var lifted Lifted
+	var sb strings.Builder
+	body := ""
+	for _, tmpl := range templates {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+		text := treeText(tmpl.Tree.Root)
+		if tmpl.Name() != t.Name() {
+			text = defineText(tmpl.Name(), text)
+		}
+		f, err := parser.ParseBytesFuncs([]byte(text), parser.ParseComments, funcs)
+		if err == nil {
+			err = checkAlignment(f)
+		}
+		if err != nil {
+			lifted.Issues = append(lifted.Issues, &Issue{Name: tmpl.Name(), Err: err})
+			continue
+		}
+		if tmpl.Name() == t.Name() {
+			body = text
+		} else {
+			sb.WriteString(text)
+		}
+	}
+	sb.WriteString(body)
+
+	f, err := parser.ParseBytesFuncs([]byte(sb.String()), parser.ParseComments, funcs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to lift template")
+	}
+	f.Name = t.Name()
+	lifted.File = f
+	return &lifted, nil
+}
+
+// checkAlignment reports the first action in a file that directly follows a mapping key, e.g. the action in
+// "spec:{{toYaml .spec | nindent 2}}". Such actions are the remains of actions that produced indented text on the lines
+// that follow the key; they do not produce the key's value.
+func checkAlignment(f *ast.File) error {
+	for _, n := range ast.FilterFile(ast.InterpolatedStringType, f) {
+		str := n.(*ast.InterpolatedStringNode)
+		if str.Style != token.StringType {
+			continue
+		}
+		parts := str.Parts.Nodes
+		for i := 0; i < len(parts)-1; i++ {
+			if s, ok := parts[i].(*ast.StringNode); ok && strings.HasSuffix(s.Value, ":") {
+				return errors.ErrSyntax("action is not structurally aligned: it follows a mapping key on the key's line", parts[i+1].GetToken())
+			}
+		}
+	}
+	return nil
+}
+
+// defineText returns the text of a {{define}} action for a template. The action and its {{end}} are placed on lines of
+// their own so that the template's body is parsed as YAML.
+func defineText(name, body string) string {
+	if !strings.HasPrefix(body, "\n") {
+		body = "\n" + body
+	}
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return "{{define " + strconv.Quote(name) + "}}" + body + "{{end}}\n"
+}
+
+// The kinds of the pieces of the text of a parse tree.
+const (
+	textPiece    = iota // literal text
+	actionPiece         // an action other than those below
+	controlPiece        // a {{break}}, {{continue}}, or comment
+	branchPiece         // an {{if}}, {{range}}, or {{with}} action
+	elsePiece           // an {{else}} action
+	endPiece            // an {{end}} action
+)
+
+// A piece is a piece of the text of a parse tree.
+type piece struct {
+	kind int
+	text string
+}
+
+// treeText returns the text of a parse tree. Unlike the tree's String method, treeText places control actions that
+// delimit lines of YAML, i.e. control actions that are followed by a line break, on lines of their own.
+func treeText(root *parse.ListNode) string {
+	pieces := appendPieces(nil, root)
+
+	type frame struct {
+		standalone bool
+		indent     int
+	}
+	var stack []frame
+
+	var sb strings.Builder
+	lineStart, lineHasText := 0, false
+	write := func(s string, text bool) {
+		sb.WriteString(s)
+		if i := strings.LastIndex(s, "\n"); i >= 0 {
+			lineStart, lineHasText = sb.Len()-len(s)+i+1, false
+			s = s[i+1:]
+		}
+		if text && strings.Trim(s, " ") != "" {
+			lineHasText = true
+		}
+	}
+
+	for i, p := range pieces {
+		if p.kind == textPiece || p.kind == actionPiece {
+			write(p.text, true)
+			continue
+		}
+
+		// {{else}} and {{end}} are placed like the action that starts their branch.
+		standalone, indent := false, 0
+		switch p.kind {
+		case elsePiece, endPiece:
+			top := stack[len(stack)-1]
+			standalone, indent = top.standalone, top.indent
+		default:
+			next := nextText(pieces[i+1:])
+			standalone = next == "" || strings.HasPrefix(next, "\n")
+			if lineHasText {
+				indent = nextIndent(next)
+			} else {
+				indent = sb.Len() - lineStart
+			}
+		}
+		if standalone && lineHasText {
+			write("\n"+strings.Repeat(" ", indent), true)
+		}
+		write(p.text, false)
+
+		switch p.kind {
+		case branchPiece:
+			stack = append(stack, frame{standalone: standalone, indent: indent})
+		case endPiece:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return sb.String()
+}
+
+// appendPieces appends the pieces of the text of a list of parse nodes.
+func appendPieces(pieces []piece, list *parse.ListNode) []piece {
+	if list == nil {
+		return pieces
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.TextNode:
+			pieces = append(pieces, piece{kind: textPiece, text: string(n.Text)})
+		case *parse.IfNode:
+			pieces = appendBranch(pieces, "if", &n.BranchNode)
+		case *parse.RangeNode:
+			pieces = appendBranch(pieces, "range", &n.BranchNode)
+		case *parse.WithNode:
+			pieces = appendBranch(pieces, "with", &n.BranchNode)
+		case *parse.BreakNode, *parse.ContinueNode, *parse.CommentNode:
+			pieces = append(pieces, piece{kind: controlPiece, text: n.String()})
+		default:
+			pieces = append(pieces, piece{kind: actionPiece, text: n.String()})
+		}
+	}
+	return pieces
+}
+
+// appendBranch appends the pieces of an {{if}}, {{range}}, or {{with}} action.
+func appendBranch(pieces []piece, keyword string, b *parse.BranchNode) []piece {
+	pieces = append(pieces, piece{kind: branchPiece, text: "{{" + keyword + " " + b.Pipe.String() + "}}"})
+	pieces = appendPieces(pieces, b.List)
+	if b.ElseList != nil {
+		pieces = append(pieces, piece{kind: elsePiece, text: "{{else}}"})
+		pieces = appendPieces(pieces, b.ElseList)
+	}
+	return append(pieces, piece{kind: endPiece, text: "{{end}}"})
+}
+
+// nextText returns the first non-empty text in a list of pieces, or the empty string if there is none.
+func nextText(pieces []piece) string {
+	for _, p := range pieces {
+		if p.kind == textPiece && p.text != "" {
+			return p.text
+		}
+	}
+	return ""
+}
+
+// nextIndent returns the indentation of the line that follows the first line break in text.
+func nextIndent(text string) int {
+	i := strings.Index(text, "\n")
+	if i < 0 {
+		return 0
+	}
+	line := text[i+1:]
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
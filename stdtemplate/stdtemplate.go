@@ -0,0 +1,76 @@
+// Package stdtemplate converts between yomlette templates and the standard library's text/template package.
+//
+// Template converts a parsed file into a *template.Template, whose parse trees mirror the file's template nodes, so
+// that existing function maps and tooling can execute it. The standard library executes the template as text: values
+// are formatted with fmt rather than as YAML nodes, so the output matches the executor's only for templates that
+// produce scalars.
+//
+// Lift converts the parse trees of a *template.Template into a yomlette file. Templates whose actions are not
+// structurally aligned with their YAML cannot be lifted; these are reported rather than failing the whole conversion.
+package stdtemplate
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pgavlin/yomlette/ast"
+	"github.com/pgavlin/yomlette/internal/errors"
+	"github.com/pgavlin/yomlette/token"
+)
+
+// Source returns the template source of a parsed file. The source is reconstructed from the file's tokens, and
+// differs from the original text at most in whitespace at the ends of lines and in YAML comments, which are only
+// present if the file was parsed with parser.ParseComments.
+func Source(f *ast.File) string {
+	var sb strings.Builder
+	line := 1
+	for tk := firstToken(f); tk != nil; tk = tk.Next {
+		origin := tk.Origin
+		// The scanner does not attribute every line break to a token, e.g. the line breaks before a document header.
+		// Restore them so that such tokens start at their original line and column.
+		if lines := tk.Position.Line - line; lines > 0 && !strings.Contains(origin, "\n") {
+			origin = strings.TrimLeft(origin, spaceChars)
+			sb.WriteString(strings.Repeat("\n", lines))
+			sb.WriteString(strings.Repeat(" ", tk.Position.Column-1))
+			line += lines
+		}
+		sb.WriteString(origin)
+		line += strings.Count(origin, "\n")
+	}
+	return sb.String()
+}
+
+// Template converts a parsed file into a text/template Template named name. The templates defined by the file are
+// associated with the result. funcs must hold the functions that the file's templates call other than the builtin
+// functions.
+func Template(name string, f *ast.File, funcs template.FuncMap) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs).Parse(Source(f))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert template")
+	}
+	return t, nil
+}
+
+const spaceChars = " \t\r\n"
+
+// firstToken returns the first token of a file, or nil if the file has no tokens.
+func firstToken(f *ast.File) *token.Token {
+	var tk *token.Token
+	for _, doc := range f.Docs {
+		switch {
+		case doc.Start != nil:
+			tk = doc.Start
+		case doc.Body != nil:
+			tk = doc.Body.GetToken()
+		default:
+			tk = doc.End
+		}
+		if tk != nil {
+			break
+		}
+	}
+	for tk != nil && tk.Prev != nil {
+		tk = tk.Prev
+	}
+	return tk
+}
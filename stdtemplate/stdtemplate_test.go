@@ -0,0 +1,125 @@
+package stdtemplate_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/pgavlin/yomlette/executor"
+	"github.com/pgavlin/yomlette/parser"
+	"github.com/pgavlin/yomlette/stdtemplate"
+)
+
+var data = map[string]interface{}{
+	"name":  "web",
+	"ports": []int{80, 443},
+	"debug": false,
+}
+
+func TestSource(t *testing.T) {
+	tests := []string{
+		"name: {{ .name }}\nports:\n  {{ range .ports }}\n  - {{ . }}\n  {{ end }}",
+		"# doc\na: 1 # x\n\nb: |\n  x {{ .name }}\n\n  y\nc: 'q'",
+		"{{ define \"x\" }}\n{{/* c */}}\na: 1\n{{ end }}\n---\nb: [{{ range 3 }}{{ . }}{{ end }}]\n...\n---\nc:\nd: {e: f}",
+		"{{- if .debug -}}\nx: 1\n{{- end }}",
+	}
+	for _, source := range tests {
+		t.Run(source, func(t *testing.T) {
+			f, err := parser.ParseBytes([]byte(source), parser.ParseComments)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if actual := stdtemplate.Source(f); actual != source {
+				t.Fatalf("expected %q but got %q", source, actual)
+			}
+		})
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	source := `{{ define "labels" }}
+app: {{ upper .name }}
+{{ end }}
+metadata:
+  labels:
+    {{ template "labels" . }}
+ports:
+  {{ range .ports }}
+  {{ if eq . 443 }}{{ break }}{{ end }}
+  - port: {{ . }}
+  {{ end }}
+debug: {{ if .debug }}yes{{ else }}no{{ end }}
+`
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	f, err := parser.ParseBytesFuncs([]byte(source), 0, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	tmpl, err := stdtemplate.Template("test", f, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if tmpl.Lookup("labels") == nil {
+		t.Fatalf("expected the template to define labels")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := "\nmetadata:\n  labels:\n    \napp: WEB\n\nports:\n  \n  \n  - port: 80\n  \n  \ndebug: no"
+	if actual := buf.String(); actual != expect {
+		t.Fatalf("expected %q but got %q", expect, actual)
+	}
+}
+
+func TestLift(t *testing.T) {
+	source := `{{ define "labels" }}
+app: {{ .name }}
+{{ end }}
+{{ define "spec" }}
+spec:
+  {{- toYaml .spec | nindent 2 }}
+{{ end }}
+metadata:
+  labels:
+    {{ template "labels" . }}
+ports:
+  {{- range .ports }}
+  {{- if eq . 80 }}{{ continue }}{{ end }}
+  - {{ . }}
+  {{- end }}
+debug: {{ if .debug }}yes{{ else }}no{{ end }}
+`
+	funcs := template.FuncMap{"toYaml": func(v interface{}) string { return "" }, "nindent": func(n int, s string) string { return s }}
+	tmpl, err := template.New("test").Funcs(funcs).Parse(source)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	lifted, err := stdtemplate.Lift(tmpl, funcs)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	var issues []string
+	for _, issue := range lifted.Issues {
+		issues = append(issues, issue.Name)
+	}
+	if !reflect.DeepEqual(issues, []string{"spec"}) {
+		t.Fatalf("expected an issue for spec but got %v", lifted.Issues)
+	}
+	if msg := lifted.Issues[0].Error(); !strings.Contains(msg, "not structurally aligned") {
+		t.Fatalf("unexpected issue %q", msg)
+	}
+
+	var buf bytes.Buffer
+	if _, err := (&executor.Executor{}).Render(&buf, lifted.File, data); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expect := "metadata:\n  labels:\n    app: web\nports:\n  - 443\ndebug: no\n"
+	if actual := buf.String(); actual != expect {
+		t.Fatalf("expected %q but got %q", expect, actual)
+	}
+}
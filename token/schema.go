@@ -0,0 +1,154 @@
+package token
+
+import (
+	"regexp"
+)
+
+// Schema identifies a set of rules for resolving the types of plain scalars.
+type Schema int
+
+const (
+	// DefaultSchema resolves plain scalars using the rules of New, which mix those of YAML 1.1 and YAML 1.2.
+	DefaultSchema Schema = iota
+	// FailsafeSchema is the YAML 1.2 failsafe schema. All plain scalars are strings.
+	FailsafeSchema
+	// JSONSchema is the YAML 1.2 JSON schema. Only null, true, false, and JSON numbers are resolved to non-strings.
+	JSONSchema
+	// CoreSchema is the YAML 1.2 core schema.
+	CoreSchema
+	// YAML11Schema is the YAML 1.1 type repository, including yes/no/on/off booleans, 0-prefixed octal integers, and
+	// base 60 numbers. Plain scalars that are timestamps are resolved as timestamps by the parser, as Resolve only
+	// returns the types of scalar tokens.
+	YAML11Schema
+)
+
+// String returns the name of the schema.
+func (s Schema) String() string {
+	switch s {
+	case DefaultSchema:
+		return "default"
+	case FailsafeSchema:
+		return "failsafe"
+	case JSONSchema:
+		return "json"
+	case CoreSchema:
+		return "core"
+	case YAML11Schema:
+		return "yaml 1.1"
+	}
+	return ""
+}
+
+// SchemaForVersion returns the schema for the given YAML version, e.g. the version in a `%YAML 1.1` directive. If the
+// version is not known, SchemaForVersion returns false.
+func SchemaForVersion(version string) (Schema, bool) {
+	switch version {
+	case "1.1":
+		return YAML11Schema, true
+	case "1.2":
+		return CoreSchema, true
+	}
+	return DefaultSchema, false
+}
+
+type schemaRule struct {
+	typ     Type
+	pattern *regexp.Regexp
+}
+
+func rule(typ Type, pattern string) schemaRule {
+	return schemaRule{typ: typ, pattern: regexp.MustCompile("^(?:" + pattern + ")$")}
+}
+
+// Rules are tried in order, so integer rules must precede float rules.
+var (
+	jsonRules = []schemaRule{
+		rule(NullType, `null`),
+		rule(BoolType, `true|false`),
+		rule(IntegerType, `-?(?:0|[1-9][0-9]*)`),
+		rule(FloatType, `-?(?:0|[1-9][0-9]*)(?:\.[0-9]*)?(?:[eE][-+]?[0-9]+)?`),
+	}
+	coreRules = []schemaRule{
+		rule(NullType, `null|Null|NULL|~`),
+		rule(BoolType, `true|True|TRUE|false|False|FALSE`),
+		rule(IntegerType, `[-+]?[0-9]+`),
+		rule(OctetIntegerType, `0o[0-7]+`),
+		rule(HexIntegerType, `0x[0-9a-fA-F]+`),
+		rule(FloatType, `[-+]?(?:\.[0-9]+|[0-9]+(?:\.[0-9]*)?)(?:[eE][-+]?[0-9]+)?`),
+		rule(InfinityType, `[-+]?\.(?:inf|Inf|INF)`),
+		rule(NanType, `\.(?:nan|NaN|NAN)`),
+	}
+	yaml11Rules = []schemaRule{
+		rule(NullType, `~|null|Null|NULL`),
+		rule(BoolType, `y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF`),
+		rule(BinaryIntegerType, `[-+]?0b[01_]+`),
+		rule(OctetIntegerType, `[-+]?0[0-7_]+`),
+		rule(IntegerType, `[-+]?(?:0|[1-9][0-9_]*)`),
+		rule(HexIntegerType, `[-+]?0x[0-9a-fA-F_]+`),
+		rule(IntegerType, `[-+]?[1-9][0-9_]*(?::[0-5]?[0-9])+`),
+		rule(FloatType, `[-+]?(?:[0-9][0-9_]*\.[0-9_]*|\.[0-9_]+)(?:[eE][-+][0-9]+)?`),
+		rule(FloatType, `[-+]?[0-9][0-9_]*(?::[0-5]?[0-9])+\.[0-9_]*`),
+		rule(InfinityType, `[-+]?\.(?:inf|Inf|INF)`),
+		rule(NanType, `\.(?:nan|NaN|NAN)`),
+	}
+)
+
+// Resolve returns the type of a plain scalar with the given value under the schema.
+func (s Schema) Resolve(value string) Type {
+	var rules []schemaRule
+	switch s {
+	case DefaultSchema:
+		return resolveDefault(value)
+	case FailsafeSchema:
+		return StringType
+	case JSONSchema:
+		rules = jsonRules
+	case CoreSchema:
+		rules = coreRules
+	case YAML11Schema:
+		rules = yaml11Rules
+	}
+	for _, r := range rules {
+		if r.pattern.MatchString(value) {
+			return r.typ
+		}
+	}
+	return StringType
+}
+
+func resolveDefault(value string) Type {
+	if fn := reservedKeywordMap[value]; fn != nil {
+		return fn(value, value, nil).Type
+	}
+	if stat := getNumberStat(value); stat.isNum {
+		switch stat.typ {
+		case numTypeFloat:
+			return FloatType
+		case numTypeBinary:
+			return BinaryIntegerType
+		case numTypeOctet:
+			return OctetIntegerType
+		case numTypeHex:
+			return HexIntegerType
+		}
+		return IntegerType
+	}
+	return StringType
+}
+
+// NewWithSchema creates a reserved keyword token, a number token, or a string token for a plain scalar using the given
+// schema to resolve its type.
+func NewWithSchema(value string, org string, pos *Position, schema Schema) *Token {
+	typ := schema.Resolve(value)
+	if typ == StringType {
+		return String(value, org, pos)
+	}
+	return &Token{
+		Type:          typ,
+		CharacterType: CharacterTypeMiscellaneous,
+		Indicator:     NotIndicator,
+		Value:         value,
+		Origin:        org,
+		Position:      pos,
+	}
+}
@@ -633,34 +633,78 @@ func LiteralBlockHeader(value string) string {
 	}
 }
 
-// New create reserved keyword token or number token and other string token
-func New(value string, org string, pos *Position) *Token {
-	fn := reservedKeywordMap[value]
-	if fn != nil {
-		return fn(value, org, pos)
+// BlockScalarValue returns the value of a block scalar with the given header and content. The content is the text of
+// the scalar's lines without the block's indentation. Folded scalars are folded, and the header's chomping indicator
+// determines the line breaks that end the value.
+func BlockScalarValue(header, content string) string {
+	lines := strings.Split(content, "\n")
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
 	}
-	if stat := getNumberStat(value); stat.isNum {
-		tk := &Token{
-			Type:          IntegerType,
-			CharacterType: CharacterTypeMiscellaneous,
-			Indicator:     NotIndicator,
-			Value:         value,
-			Origin:        org,
-			Position:      pos,
+	// The number of line breaks that follow the last non-empty line.
+	breaks := len(lines) - end
+	if end == 0 {
+		breaks = len(lines) - 1
+	}
+	lines = lines[:end]
+
+	var sb strings.Builder
+	if strings.HasPrefix(header, ">") {
+		foldLines(&sb, lines)
+	} else {
+		sb.WriteString(strings.Join(lines, "\n"))
+	}
+
+	switch {
+	case strings.Contains(header, "-") || breaks == 0:
+	case strings.Contains(header, "+"):
+		sb.WriteString(strings.Repeat("\n", breaks))
+	case len(lines) != 0:
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// foldLines writes the folded text of the lines of a folded block scalar. A line break between two lines of text is
+// folded into a space unless either line is more indented; empty lines between lines of text are kept.
+func foldLines(sb *strings.Builder, lines []string) {
+	moreIndented := func(line string) bool {
+		return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+	}
+
+	i := 0
+	for i < len(lines) && lines[i] == "" {
+		sb.WriteString("\n")
+		i++
+	}
+	for i < len(lines) {
+		line := lines[i]
+		sb.WriteString(line)
+
+		next := i + 1
+		for next < len(lines) && lines[next] == "" {
+			next++
 		}
-		switch stat.typ {
-		case numTypeFloat:
-			tk.Type = FloatType
-		case numTypeBinary:
-			tk.Type = BinaryIntegerType
-		case numTypeOctet:
-			tk.Type = OctetIntegerType
-		case numTypeHex:
-			tk.Type = HexIntegerType
+		if next == len(lines) {
+			break
 		}
-		return tk
+		empty := next - i - 1
+		switch {
+		case moreIndented(line) || moreIndented(lines[next]):
+			sb.WriteString(strings.Repeat("\n", empty+1))
+		case empty == 0:
+			sb.WriteByte(' ')
+		default:
+			sb.WriteString(strings.Repeat("\n", empty))
+		}
+		i = next
 	}
-	return String(value, org, pos)
+}
+
+// New create reserved keyword token or number token and other string token
+func New(value string, org string, pos *Position) *Token {
+	return NewWithSchema(value, org, pos, DefaultSchema)
 }
 
 // Position type for position in YAML document
@@ -116,3 +116,59 @@ func TestIsNeedQuoted(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockScalarValue(t *testing.T) {
+	tests := []struct {
+		header  string
+		content string
+		expect  string
+	}{
+		{"|", "a\n  b\n", "a\n  b\n"},
+		{"|-", "a\nb\n\n", "a\nb"},
+		{"|+", "a\nb\n\n", "a\nb\n\n"},
+		{"|", "a\n\n\n", "a\n"},
+		{">", "a\nb\n\nc\n", "a b\nc\n"},
+		{">-", "a\n  b\nc", "a\n  b\nc"},
+		{">+", "\na\nb\n\n", "\na b\n\n"},
+		{"|", "", ""},
+	}
+	for _, test := range tests {
+		if actual := token.BlockScalarValue(test.header, test.content); actual != test.expect {
+			t.Fatalf("%s %q: expected %q but got %q", test.header, test.content, test.expect, actual)
+		}
+	}
+}
+
+func TestSchemaResolve(t *testing.T) {
+	tests := []struct {
+		value  string
+		expect [4]token.Type // failsafe, json, core, yaml 1.1
+	}{
+		{"null", [4]token.Type{token.StringType, token.NullType, token.NullType, token.NullType}},
+		{"~", [4]token.Type{token.StringType, token.StringType, token.NullType, token.NullType}},
+		{"True", [4]token.Type{token.StringType, token.StringType, token.BoolType, token.BoolType}},
+		{"yes", [4]token.Type{token.StringType, token.StringType, token.StringType, token.BoolType}},
+		{"off", [4]token.Type{token.StringType, token.StringType, token.StringType, token.BoolType}},
+		{"0", [4]token.Type{token.StringType, token.IntegerType, token.IntegerType, token.IntegerType}},
+		{"012", [4]token.Type{token.StringType, token.StringType, token.IntegerType, token.OctetIntegerType}},
+		{"0o12", [4]token.Type{token.StringType, token.StringType, token.OctetIntegerType, token.StringType}},
+		{"0x1F", [4]token.Type{token.StringType, token.StringType, token.HexIntegerType, token.HexIntegerType}},
+		{"0b101", [4]token.Type{token.StringType, token.StringType, token.StringType, token.BinaryIntegerType}},
+		{"1_000", [4]token.Type{token.StringType, token.StringType, token.StringType, token.IntegerType}},
+		{"190:20:30", [4]token.Type{token.StringType, token.StringType, token.StringType, token.IntegerType}},
+		{"1.5", [4]token.Type{token.StringType, token.FloatType, token.FloatType, token.FloatType}},
+		{"1e3", [4]token.Type{token.StringType, token.FloatType, token.FloatType, token.StringType}},
+		{"190:20:30.15", [4]token.Type{token.StringType, token.StringType, token.StringType, token.FloatType}},
+		{"+.inf", [4]token.Type{token.StringType, token.StringType, token.InfinityType, token.InfinityType}},
+		{".NaN", [4]token.Type{token.StringType, token.StringType, token.NanType, token.NanType}},
+		{"hello", [4]token.Type{token.StringType, token.StringType, token.StringType, token.StringType}},
+	}
+	schemas := []token.Schema{token.FailsafeSchema, token.JSONSchema, token.CoreSchema, token.YAML11Schema}
+	for _, test := range tests {
+		for i, schema := range schemas {
+			if actual := schema.Resolve(test.value); actual != test.expect[i] {
+				t.Fatalf("%s: %s: expected %s but got %s", schema, test.value, test.expect[i], actual)
+			}
+		}
+	}
+}